@@ -0,0 +1,37 @@
+package godex
+
+import "github.com/activadee/godex/internal/codexexec"
+
+// CachedBinary describes a Codex CLI binary stored in the SDK's download cache.
+type CachedBinary = codexexec.CachedBinary
+
+// ListCachedBinaries returns the Codex binaries cached under cacheDir, which follows the
+// <release>/<triple>/<exe> layout used by the SDK. Pass an empty cacheDir to inspect the
+// default location ($GODEX_CLI_CACHE, then the user cache directory, skipping any that is not
+// writable, as downloads do).
+func ListCachedBinaries(cacheDir string) ([]CachedBinary, error) {
+	return codexexec.ListCachedBinaries(cacheDir)
+}
+
+// ClearCLICache deletes the Codex binaries cached under opts.CLICacheDir (or the default
+// location when empty) and returns the number of bytes freed. Only the <release>/<triple>
+// binaries listed by ListCachedBinaries are removed; unrelated files and binaries that another
+// process is currently downloading are kept. On Windows, a binary that is currently executing
+// is left in place.
+func ClearCLICache(opts CodexOptions) (int64, error) {
+	return codexexec.ClearCache(opts.CLICacheDir)
+}
+
+// VerifyCachedBinary checks that the Codex binary cached for the current platform exists and
+// matches the configured checksum (opts.CLIChecksum or GODEX_CLI_CHECKSUM), without ever
+// downloading. It honours CLICacheDir, CLIReleaseTag and CLISignaturePublicKey, resolving a
+// "latest" release tag to the newest release already cached. Use it in CI to assert the
+// integrity of a pre-populated cache.
+func VerifyCachedBinary(opts CodexOptions) error {
+	return codexexec.VerifyCachedBinary(codexexec.RunnerOptions{
+		CacheDir:           opts.CLICacheDir,
+		ReleaseTag:         opts.CLIReleaseTag,
+		ChecksumHex:        opts.CLIChecksum,
+		SignaturePublicKey: opts.CLISignaturePublicKey,
+	})
+}
@@ -1,5 +1,7 @@
 package godex
 
+import "strings"
+
 // StreamItemStage indicates which phase of the lifecycle produced a callback.
 type StreamItemStage string
 
@@ -19,6 +21,10 @@ type StreamMessageEvent struct {
 type StreamReasoningEvent struct {
 	Stage     StreamItemStage
 	Reasoning ReasoningItem
+	// Delta is the reasoning text added since the previous callback for the same item, for
+	// rendering a live "thinking" indicator while the item is started or updated. It is empty
+	// at StreamItemStageCompleted, where Reasoning.Text holds the full text.
+	Delta string
 }
 
 // StreamCommandEvent describes a callback payload for command execution items.
@@ -40,7 +46,8 @@ type StreamFileChangeEvent struct {
 	Change FileUpdateChange
 }
 
-// StreamWebSearchEvent describes a callback payload for web search items.
+// StreamWebSearchEvent describes a callback payload for web search items. Search.Results holds
+// the pages found once the CLI reports them.
 type StreamWebSearchEvent struct {
 	Stage  StreamItemStage
 	Search WebSearchItem
@@ -64,16 +71,38 @@ type StreamErrorItemEvent struct {
 	Error ErrorItem
 }
 
+// StreamUnknownItemEvent describes a callback payload for items of a type the SDK does not
+// recognize.
+type StreamUnknownItemEvent struct {
+	Stage StreamItemStage
+	Item  UnknownItem
+}
+
 // StreamCallbacks enumerates optional hooks invoked when streaming events are delivered.
+//
+// Callbacks run synchronously on the goroutine reading the CLI's output, in event order. An
+// event's callbacks have returned before that event is sent on the Events channel, and all
+// callbacks have returned before Wait unblocks or the Events channel is closed. A callback that
+// blocks therefore stalls the turn.
+//
+// The callbacks ending in Err can abort the turn: a non-nil error cancels the run, kills the
+// CLI, skips the remaining callbacks for that event and is returned by Wait (and Run) as is.
 type StreamCallbacks struct {
 	// OnEvent fires for every event before any type-specific callback.
 	OnEvent func(ThreadEvent)
+	// OnEventErr fires for every event right after OnEvent and can abort the turn.
+	OnEventErr func(ThreadEvent) error
 
 	OnThreadStarted func(ThreadStartedEvent)
 	OnTurnStarted   func(TurnStartedEvent)
 	OnTurnCompleted func(TurnCompletedEvent)
 	OnTurnFailed    func(TurnFailedEvent)
 	OnThreadError   func(ThreadErrorEvent)
+	// OnUsage fires with the turn's token usage when turn.completed arrives, after
+	// OnTurnCompleted.
+	OnUsage func(Usage)
+	// OnRateLimit fires when the CLI reports it is backing off because of a rate limit.
+	OnRateLimit func(RateLimitEvent)
 
 	OnMessage    func(StreamMessageEvent)
 	OnReasoning  func(StreamReasoningEvent)
@@ -84,16 +113,73 @@ type StreamCallbacks struct {
 	OnToolCall   func(StreamToolCallEvent)
 	OnTodoList   func(StreamTodoListEvent)
 	OnErrorItem  func(StreamErrorItemEvent)
+
+	// OnCommandErr fires for command execution items right after OnCommand and can abort the
+	// turn, for example when a forbidden command shows up.
+	OnCommandErr func(StreamCommandEvent) error
+
+	// OnUnknownItem fires for items whose type this SDK version does not recognize. Without
+	// it such items are skipped by the type-specific callbacks.
+	OnUnknownItem func(StreamUnknownItemEvent)
+
+	// OnItemDone fires exactly once per item when it reaches the completed stage, after the
+	// type-specific callback. Items are deduplicated by ID within a turn, so repeated
+	// completion events for the same item are ignored.
+	OnItemDone func(ThreadItem)
+
+	// OnStderr fires for each line the Codex CLI writes to stderr (progress and warnings)
+	// while the turn runs. It is called from a separate goroutine than the event callbacks.
+	OnStderr func(line []byte)
+}
+
+// callbackState tracks per-turn state needed to dispatch callbacks.
+type callbackState struct {
+	// doneIDs records the items already reported through OnItemDone.
+	doneIDs map[string]struct{}
+	// reasoningText holds the last reasoning text seen per item, for computing deltas.
+	reasoningText map[string]string
+}
+
+func newCallbackState() *callbackState {
+	return &callbackState{
+		doneIDs:       make(map[string]struct{}),
+		reasoningText: make(map[string]string),
+	}
+}
+
+// reasoningDelta returns the text item adds over the previous update of the same item. The
+// CLI may resend the cumulative text or only the new fragment; both yield the new fragment.
+func (s *callbackState) reasoningDelta(stage StreamItemStage, item ReasoningItem) string {
+	if stage == StreamItemStageCompleted {
+		delete(s.reasoningText, item.ID)
+		return ""
+	}
+	previous := s.reasoningText[item.ID]
+	delta := item.Text
+	if strings.HasPrefix(item.Text, previous) {
+		delta = item.Text[len(previous):]
+		s.reasoningText[item.ID] = item.Text
+	} else {
+		s.reasoningText[item.ID] = previous + item.Text
+	}
+	return delta
 }
 
-func (c *StreamCallbacks) handle(event ThreadEvent) {
+// handle dispatches event to the configured callbacks using the turn's state. It returns the
+// first error from an aborting callback, after which no further callbacks run for event.
+func (c *StreamCallbacks) handle(event ThreadEvent, state *callbackState) error {
 	if c == nil {
-		return
+		return nil
 	}
 
 	if c.OnEvent != nil {
 		c.OnEvent(event)
 	}
+	if c.OnEventErr != nil {
+		if err := c.OnEventErr(event); err != nil {
+			return err
+		}
+	}
 
 	switch e := event.(type) {
 	case ThreadStartedEvent:
@@ -108,6 +194,9 @@ func (c *StreamCallbacks) handle(event ThreadEvent) {
 		if c.OnTurnCompleted != nil {
 			c.OnTurnCompleted(e)
 		}
+		if c.OnUsage != nil {
+			c.OnUsage(e.Usage)
+		}
 	case TurnFailedEvent:
 		if c.OnTurnFailed != nil {
 			c.OnTurnFailed(e)
@@ -116,19 +205,41 @@ func (c *StreamCallbacks) handle(event ThreadEvent) {
 		if c.OnThreadError != nil {
 			c.OnThreadError(e)
 		}
+	case RateLimitEvent:
+		if c.OnRateLimit != nil {
+			c.OnRateLimit(e)
+		}
 	case ItemStartedEvent:
-		c.handleItem(StreamItemStageStarted, e.Item)
+		return c.handleItem(StreamItemStageStarted, e.Item, state)
 	case ItemUpdatedEvent:
-		c.handleItem(StreamItemStageUpdated, e.Item)
+		return c.handleItem(StreamItemStageUpdated, e.Item, state)
 	case ItemCompletedEvent:
-		c.handleItem(StreamItemStageCompleted, e.Item)
+		if err := c.handleItem(StreamItemStageCompleted, e.Item, state); err != nil {
+			return err
+		}
+		c.handleItemDone(e.Item, state.doneIDs)
 	}
+	return nil
 }
 
-func (c *StreamCallbacks) handleItem(stage StreamItemStage, item ThreadItem) {
-	if c == nil || item == nil {
+func (c *StreamCallbacks) handleItemDone(item ThreadItem, doneIDs map[string]struct{}) {
+	if c.OnItemDone == nil || item == nil {
 		return
 	}
+	// Items without an ID cannot be deduplicated and are always reported.
+	if id := item.itemID(); id != "" {
+		if _, seen := doneIDs[id]; seen {
+			return
+		}
+		doneIDs[id] = struct{}{}
+	}
+	c.OnItemDone(item)
+}
+
+func (c *StreamCallbacks) handleItem(stage StreamItemStage, item ThreadItem, state *callbackState) error {
+	if c == nil || item == nil {
+		return nil
+	}
 
 	switch v := item.(type) {
 	case AgentMessageItem:
@@ -137,11 +248,15 @@ func (c *StreamCallbacks) handleItem(stage StreamItemStage, item ThreadItem) {
 		}
 	case ReasoningItem:
 		if c.OnReasoning != nil {
-			c.OnReasoning(StreamReasoningEvent{Stage: stage, Reasoning: v})
+			c.OnReasoning(StreamReasoningEvent{Stage: stage, Reasoning: v, Delta: state.reasoningDelta(stage, v)})
 		}
 	case CommandExecutionItem:
+		event := StreamCommandEvent{Stage: stage, Command: v}
 		if c.OnCommand != nil {
-			c.OnCommand(StreamCommandEvent{Stage: stage, Command: v})
+			c.OnCommand(event)
+		}
+		if c.OnCommandErr != nil {
+			return c.OnCommandErr(event)
 		}
 	case FileChangeItem:
 		if c.OnPatch != nil {
@@ -172,5 +287,10 @@ func (c *StreamCallbacks) handleItem(stage StreamItemStage, item ThreadItem) {
 		if c.OnErrorItem != nil {
 			c.OnErrorItem(StreamErrorItemEvent{Stage: stage, Error: v})
 		}
+	case UnknownItem:
+		if c.OnUnknownItem != nil {
+			c.OnUnknownItem(StreamUnknownItemEvent{Stage: stage, Item: v})
+		}
 	}
+	return nil
 }
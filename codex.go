@@ -1,21 +1,47 @@
 package godex
 
-import "github.com/activadee/godex/internal/codexexec"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/activadee/godex/internal/codexexec"
+)
+
+// cliVersionPattern matches a semantic version such as 0.55.0 or 1.2.3-beta.1 within the
+// output of `codex --version`.
+var cliVersionPattern = regexp.MustCompile(`\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?`)
 
 // Codex is the entrypoint for interacting with the Codex agent via the CLI.
 type Codex struct {
 	exec    execRunner
 	options CodexOptions
+
+	versionMu sync.Mutex
+	version   string
 }
 
 // New constructs a Codex SDK instance. The Codex binary is discovered automatically unless
 // CodexOptions.CodexPathOverride is provided.
 func New(options CodexOptions) (*Codex, error) {
 	exec, err := codexexec.New(codexexec.RunnerOptions{
-		PathOverride: options.CodexPathOverride,
-		CacheDir:     options.CLICacheDir,
-		ReleaseTag:   options.CLIReleaseTag,
-		ChecksumHex:  options.CLIChecksum,
+		PathOverride:       options.CodexPathOverride,
+		CacheDir:           options.CLICacheDir,
+		ReleaseTag:         options.CLIReleaseTag,
+		ChecksumHex:        options.CLIChecksum,
+		SignaturePublicKey: options.CLISignaturePublicKey,
+		DownloadBaseURL:    options.CLIDownloadBaseURL,
+		AssetURLFunc:       options.AssetURLFunc,
+		ReleaseMetadataURL: options.CLIReleaseMetadataURL,
+		ForceDownload:      options.ForceDownload,
+		VerifyBinaryRuns:   options.VerifyBinaryRuns,
+		HTTPClient:         options.DownloadHTTPClient,
+		DownloadProgress:   options.DownloadProgress,
+		StartupTimeout:     options.StartupTimeout,
+		Logger:             options.Logger,
+		LogLevel:           options.BundleLogLevel,
 	})
 	if err != nil {
 		return nil, err
@@ -26,6 +52,67 @@ func New(options CodexOptions) (*Codex, error) {
 	}, nil
 }
 
+// RefreshCLI discards the cached Codex binary and downloads it again, verifying the configured
+// checksum. Later turns use the refreshed binary. It returns an error when the SDK was
+// configured with CodexOptions.CodexPathOverride.
+func (c *Codex) RefreshCLI(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	refresher, ok := c.exec.(interface {
+		Refresh(context.Context) error
+	})
+	if !ok {
+		return errors.New("codex runner does not support refreshing the CLI")
+	}
+	return refresher.Refresh(ctx)
+}
+
+// BundleInfo reports the effective Codex CLI bundle configuration: the cache directory,
+// release tag and checksum after option, environment and default precedence, and the binary
+// path in use.
+type BundleInfo = codexexec.BundleInfo
+
+// BundleInfo returns the bundle configuration c resolved, which is useful in diagnostics to
+// explain which CLI build was downloaded and from where.
+func (c *Codex) BundleInfo() BundleInfo {
+	reporter, ok := c.exec.(interface{ BundleInfo() codexexec.BundleInfo })
+	if !ok {
+		return BundleInfo{}
+	}
+	return reporter.BundleInfo()
+}
+
+// CLIVersion runs `codex --version` and returns the semantic version it reports, for example
+// "0.55.0". The result is cached for the lifetime of c; failures are not cached.
+func (c *Codex) CLIVersion(ctx context.Context) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+	if c.version != "" {
+		return c.version, nil
+	}
+
+	versioner, ok := c.exec.(interface {
+		Version(context.Context) (string, error)
+	})
+	if !ok {
+		return "", errors.New("codex runner does not support reporting the CLI version")
+	}
+	output, err := versioner.Version(ctx)
+	if err != nil {
+		return "", err
+	}
+	version := cliVersionPattern.FindString(output)
+	if version == "" {
+		return "", fmt.Errorf("parse codex CLI version: no semantic version in %q", output)
+	}
+	c.version = version
+	return version, nil
+}
+
 // StartThread opens a new thread with the agent.
 func (c *Codex) StartThread(options ThreadOptions) *Thread {
 	return newThread(c.exec, c.options, options, "")
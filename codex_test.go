@@ -0,0 +1,58 @@
+package godex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/activadee/godex/internal/codexexec"
+)
+
+func newFakeBinaryCodex(t *testing.T) *Codex {
+	t.Helper()
+	runner, err := codexexec.New(codexexec.RunnerOptions{PathOverride: buildFakeCodexBinary(t)})
+	if err != nil {
+		t.Fatalf("codexexec.New returned error: %v", err)
+	}
+	return &Codex{exec: runner}
+}
+
+func TestCodexCLIVersionParsesAndCaches(t *testing.T) {
+	codex := newFakeBinaryCodex(t)
+	counter := filepath.Join(t.TempDir(), "calls")
+	t.Setenv("CODEX_FAKE_VERSION", "codex-cli 0.55.0-alpha.2")
+	t.Setenv("CODEX_FAKE_VERSION_COUNTER", counter)
+
+	for i := 0; i < 2; i++ {
+		version, err := codex.CLIVersion(context.Background())
+		if err != nil {
+			t.Fatalf("CLIVersion returned error: %v", err)
+		}
+		if version != "0.55.0-alpha.2" {
+			t.Fatalf("expected version 0.55.0-alpha.2, got %q", version)
+		}
+	}
+
+	calls, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatalf("read counter: %v", err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("expected the CLI to be invoked once, got %d", len(calls))
+	}
+}
+
+func TestCodexCLIVersionRejectsMalformedOutput(t *testing.T) {
+	codex := newFakeBinaryCodex(t)
+	t.Setenv("CODEX_FAKE_VERSION", "codex-cli development build")
+
+	_, err := codex.CLIVersion(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for output without a version")
+	}
+	if !strings.Contains(err.Error(), "no semantic version") || !strings.Contains(err.Error(), "development build") {
+		t.Fatalf("expected a descriptive parse error, got %v", err)
+	}
+}
@@ -1,147 +1,239 @@
 package godex
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 )
 
-// decodeThreadEvent converts a JSON line produced by the Codex CLI into a strongly typed event.
+// eventDecoder decodes CLI output lines. With strict set, fields the SDK does not model are
+// reported as errors instead of being ignored.
+type eventDecoder struct {
+	strict bool
+	// unmarshalFunc replaces json.Unmarshal when set.
+	unmarshalFunc UnmarshalFunc
+}
+
+func newEventDecoder(options CodexOptions) eventDecoder {
+	return eventDecoder{strict: options.StrictDecode, unmarshalFunc: options.Decoder}
+}
+
+// lenient decodes data into v with the configured unmarshal function, ignoring unknown fields.
+// It is used for envelopes that only peek at the type.
+func (d eventDecoder) lenient(data []byte, v any) error {
+	if d.unmarshalFunc != nil {
+		return d.unmarshalFunc(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// unmarshal decodes the full payload of a known event or item into v. Strict decoding always
+// goes through encoding/json, which is the only decoder that can reject unknown fields.
+func (d eventDecoder) unmarshal(data []byte, v any) error {
+	if !d.strict {
+		return d.lenient(data, v)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("strict: %w", err)
+	}
+	return nil
+}
+
+// decodeThreadEvent converts a JSON line produced by the Codex CLI into a strongly typed event
+// using the default lenient decoder.
 func decodeThreadEvent(data []byte) (ThreadEvent, error) {
+	return eventDecoder{}.decodeThreadEvent(data)
+}
+
+// decodeThreadItem converts raw JSON into a specific ThreadItem implementation using the
+// default lenient decoder.
+func decodeThreadItem(data []byte) (ThreadItem, error) {
+	return eventDecoder{}.decodeThreadItem(data)
+}
+
+// decodeThreadEvent converts a JSON line produced by the Codex CLI into a strongly typed event.
+// The event keeps a copy of data, available through Raw.
+func (d eventDecoder) decodeThreadEvent(data []byte) (ThreadEvent, error) {
 	var base struct {
 		Type ThreadEventType `json:"type"`
 	}
-	if err := json.Unmarshal(data, &base); err != nil {
+	if err := d.lenient(data, &base); err != nil {
 		return nil, fmt.Errorf("decode event envelope: %w", err)
 	}
+	raw := rawLine{raw: append([]byte(nil), data...)}
 
 	switch base.Type {
 	case ThreadEventTypeThreadStarted:
 		var event ThreadStartedEvent
-		if err := json.Unmarshal(data, &event); err != nil {
+		if err := d.unmarshal(data, &event); err != nil {
 			return nil, fmt.Errorf("decode thread.started event: %w", err)
 		}
+		event.rawLine = raw
 		return event, nil
 	case ThreadEventTypeTurnStarted:
 		var event TurnStartedEvent
-		if err := json.Unmarshal(data, &event); err != nil {
+		if err := d.unmarshal(data, &event); err != nil {
 			return nil, fmt.Errorf("decode turn.started event: %w", err)
 		}
+		event.rawLine = raw
 		return event, nil
 	case ThreadEventTypeTurnCompleted:
 		var event TurnCompletedEvent
-		if err := json.Unmarshal(data, &event); err != nil {
+		if err := d.unmarshal(data, &event); err != nil {
 			return nil, fmt.Errorf("decode turn.completed event: %w", err)
 		}
+		event.rawLine = raw
 		return event, nil
 	case ThreadEventTypeTurnFailed:
 		var event TurnFailedEvent
-		if err := json.Unmarshal(data, &event); err != nil {
+		if err := d.unmarshal(data, &event); err != nil {
 			return nil, fmt.Errorf("decode turn.failed event: %w", err)
 		}
+		event.rawLine = raw
 		return event, nil
 	case ThreadEventTypeItemStarted:
-		return decodeItemEvent(data, ThreadEventTypeItemStarted)
+		return d.decodeItemEvent(data, ThreadEventTypeItemStarted, raw)
 	case ThreadEventTypeItemUpdated:
-		return decodeItemEvent(data, ThreadEventTypeItemUpdated)
+		return d.decodeItemEvent(data, ThreadEventTypeItemUpdated, raw)
 	case ThreadEventTypeItemCompleted:
-		return decodeItemEvent(data, ThreadEventTypeItemCompleted)
+		return d.decodeItemEvent(data, ThreadEventTypeItemCompleted, raw)
 	case ThreadEventTypeError:
 		var event ThreadErrorEvent
-		if err := json.Unmarshal(data, &event); err != nil {
+		if err := d.unmarshal(data, &event); err != nil {
 			return nil, fmt.Errorf("decode error event: %w", err)
 		}
+		event.rawLine = raw
+		return event, nil
+	case ThreadEventTypeRateLimit:
+		var event RateLimitEvent
+		if err := d.unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("decode rate_limit event: %w", err)
+		}
+		event.rawLine = raw
 		return event, nil
 	default:
-		return nil, fmt.Errorf("unknown event type %q", base.Type)
+		return UnknownEvent{Type: base.Type, Payload: raw.raw}, nil
 	}
 }
 
-func decodeItemEvent(data []byte, eventType ThreadEventType) (ThreadEvent, error) {
+func (d eventDecoder) decodeItemEvent(data []byte, eventType ThreadEventType, raw rawLine) (ThreadEvent, error) {
 	var envelope struct {
 		Type ThreadEventType `json:"type"`
 		Item json.RawMessage `json:"item"`
 	}
-	if err := json.Unmarshal(data, &envelope); err != nil {
+	if err := d.unmarshal(data, &envelope); err != nil {
 		return nil, fmt.Errorf("decode %s envelope: %w", eventType, err)
 	}
 
-	item, err := decodeThreadItem(envelope.Item)
+	item, err := d.decodeThreadItem(envelope.Item)
 	if err != nil {
 		return nil, fmt.Errorf("decode %s item: %w", eventType, err)
 	}
 
 	switch eventType {
 	case ThreadEventTypeItemStarted:
-		return ItemStartedEvent{Type: eventType, Item: item}, nil
+		return ItemStartedEvent{rawLine: raw, Type: eventType, Item: item}, nil
 	case ThreadEventTypeItemUpdated:
-		return ItemUpdatedEvent{Type: eventType, Item: item}, nil
+		return ItemUpdatedEvent{rawLine: raw, Type: eventType, Item: item}, nil
 	case ThreadEventTypeItemCompleted:
-		return ItemCompletedEvent{Type: eventType, Item: item}, nil
+		return ItemCompletedEvent{rawLine: raw, Type: eventType, Item: item}, nil
 	default:
 		return nil, errors.New("invalid item event type")
 	}
 }
 
 // decodeThreadItem converts raw JSON into a specific ThreadItem implementation.
-func decodeThreadItem(data []byte) (ThreadItem, error) {
+func (d eventDecoder) decodeThreadItem(data []byte) (ThreadItem, error) {
 	var base struct {
+		ID   string         `json:"id"`
 		Type ThreadItemType `json:"type"`
 	}
-	if err := json.Unmarshal(data, &base); err != nil {
+	if err := d.lenient(data, &base); err != nil {
 		return nil, fmt.Errorf("decode item envelope: %w", err)
 	}
 
 	switch base.Type {
 	case ThreadItemTypeAgentMessage:
 		var item AgentMessageItem
-		if err := json.Unmarshal(data, &item); err != nil {
+		if err := d.unmarshal(data, &item); err != nil {
 			return nil, fmt.Errorf("decode agent message item: %w", err)
 		}
 		return item, nil
 	case ThreadItemTypeReasoning:
 		var item ReasoningItem
-		if err := json.Unmarshal(data, &item); err != nil {
+		if err := d.unmarshal(data, &item); err != nil {
 			return nil, fmt.Errorf("decode reasoning item: %w", err)
 		}
 		return item, nil
 	case ThreadItemTypeCommandExecution:
 		var item CommandExecutionItem
-		if err := json.Unmarshal(data, &item); err != nil {
+		if err := d.unmarshal(data, &item); err != nil {
 			return nil, fmt.Errorf("decode command execution item: %w", err)
 		}
 		return item, nil
 	case ThreadItemTypeFileChange:
 		var item FileChangeItem
-		if err := json.Unmarshal(data, &item); err != nil {
+		if err := d.unmarshal(data, &item); err != nil {
 			return nil, fmt.Errorf("decode file change item: %w", err)
 		}
 		return item, nil
 	case ThreadItemTypeMcpToolCall:
-		var item McpToolCallItem
-		if err := json.Unmarshal(data, &item); err != nil {
-			return nil, fmt.Errorf("decode MCP tool call item: %w", err)
-		}
-		return item, nil
+		return d.decodeMcpToolCallItem(data)
 	case ThreadItemTypeWebSearch:
 		var item WebSearchItem
-		if err := json.Unmarshal(data, &item); err != nil {
+		if err := d.unmarshal(data, &item); err != nil {
 			return nil, fmt.Errorf("decode web search item: %w", err)
 		}
 		return item, nil
 	case ThreadItemTypeTodoList:
 		var item TodoListItem
-		if err := json.Unmarshal(data, &item); err != nil {
+		if err := d.unmarshal(data, &item); err != nil {
 			return nil, fmt.Errorf("decode todo list item: %w", err)
 		}
 		return item, nil
 	case ThreadItemTypeError:
 		var item ErrorItem
-		if err := json.Unmarshal(data, &item); err != nil {
+		if err := d.unmarshal(data, &item); err != nil {
 			return nil, fmt.Errorf("decode error item: %w", err)
 		}
 		return item, nil
+	case ThreadItemTypeStructuredOutput:
+		var item StructuredOutputItem
+		if err := d.unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("decode structured output item: %w", err)
+		}
+		return item, nil
 	default:
-		return nil, fmt.Errorf("unknown item type %q", base.Type)
+		return UnknownItem{ID: base.ID, Type: base.Type, Payload: append(json.RawMessage(nil), data...)}, nil
+	}
+}
+
+// decodeMcpToolCallItem decodes an MCP tool call item. The CLI reports a failure either as a
+// plain string or as an object with a message, so both shapes are accepted for Error.
+func (d eventDecoder) decodeMcpToolCallItem(data []byte) (ThreadItem, error) {
+	var payload struct {
+		McpToolCallItem
+		Error json.RawMessage `json:"error"`
+	}
+	if err := d.unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("decode MCP tool call item: %w", err)
+	}
+	item := payload.McpToolCallItem
+	if len(payload.Error) > 0 && string(payload.Error) != "null" {
+		var message string
+		if err := d.lenient(payload.Error, &message); err != nil {
+			var object struct {
+				Message string `json:"message"`
+			}
+			if err := d.unmarshal(payload.Error, &object); err != nil {
+				return nil, fmt.Errorf("decode MCP tool call error: %w", err)
+			}
+			message = object.Message
+		}
+		item.Error = message
 	}
+	return item, nil
 }
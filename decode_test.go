@@ -1,7 +1,11 @@
 package godex
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -36,6 +40,62 @@ func TestDecodeThreadEventItemCompleted(t *testing.T) {
 	}
 }
 
+func TestDecodeThreadEventFileChangeContent(t *testing.T) {
+	raw := []byte(`{"type":"item.completed","item":{"id":"item_2","type":"file_change","status":"completed","changes":[` +
+		`{"path":"main.go","kind":"update","old_content":"package a\n","new_content":"package b\n"},` +
+		`{"path":"new.go","kind":"add","new_content":""},` +
+		`{"path":"gone.go","kind":"delete"}]}}`)
+	event, err := decodeThreadEvent(raw)
+	if err != nil {
+		t.Fatalf("decodeThreadEvent returned error: %v", err)
+	}
+	completed, ok := event.(ItemCompletedEvent)
+	if !ok {
+		t.Fatalf("expected ItemCompletedEvent, got %T", event)
+	}
+	item, ok := completed.Item.(FileChangeItem)
+	if !ok {
+		t.Fatalf("expected FileChangeItem, got %T", completed.Item)
+	}
+	if len(item.Changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(item.Changes))
+	}
+
+	updated := item.Changes[0]
+	if updated.OldContent == nil || *updated.OldContent != "package a\n" {
+		t.Fatalf("unexpected old content %v", updated.OldContent)
+	}
+	if updated.NewContent == nil || *updated.NewContent != "package b\n" {
+		t.Fatalf("unexpected new content %v", updated.NewContent)
+	}
+	added := item.Changes[1]
+	if added.OldContent != nil || added.NewContent == nil || *added.NewContent != "" {
+		t.Fatalf("expected only empty new content for added file, got old=%v new=%v", added.OldContent, added.NewContent)
+	}
+	deleted := item.Changes[2]
+	if deleted.OldContent != nil || deleted.NewContent != nil {
+		t.Fatalf("expected no content for change without it, got old=%v new=%v", deleted.OldContent, deleted.NewContent)
+	}
+}
+
+func TestDecodeThreadEventUnknownType(t *testing.T) {
+	raw := []byte(`{"type":"turn.delta","delta":{"text":"partial"}}`)
+	event, err := decodeThreadEvent(raw)
+	if err != nil {
+		t.Fatalf("decodeThreadEvent returned error: %v", err)
+	}
+	unknown, ok := event.(UnknownEvent)
+	if !ok {
+		t.Fatalf("expected UnknownEvent, got %T", event)
+	}
+	if unknown.EventType() != "turn.delta" {
+		t.Fatalf("unexpected event type %q", unknown.EventType())
+	}
+	if string(unknown.Payload) != string(raw) {
+		t.Fatalf("payload = %s, want %s", unknown.Payload, raw)
+	}
+}
+
 func TestDecodeThreadEventThreadStarted(t *testing.T) {
 	raw := []byte(`{"type":"thread.started","thread_id":"thread_123"}`)
 	event, err := decodeThreadEvent(raw)
@@ -51,10 +111,126 @@ func TestDecodeThreadEventThreadStarted(t *testing.T) {
 	}
 }
 
+func TestDecodeCommandExecutionOutputStreams(t *testing.T) {
+	raw := []byte(`{"id":"cmd_1","type":"command_execution","command":"go test ./...","aggregated_output":"ok\nFAIL\n","stdout":"ok\n","stderr":"FAIL\n","exit_code":1,"duration_ms":1250,"status":"failed"}`)
+
+	item, err := decodeThreadItem(raw)
+	if err != nil {
+		t.Fatalf("decodeThreadItem returned error: %v", err)
+	}
+	command, ok := item.(CommandExecutionItem)
+	if !ok {
+		t.Fatalf("expected CommandExecutionItem, got %T", item)
+	}
+	if command.Stdout != "ok\n" || command.Stderr != "FAIL\n" {
+		t.Fatalf("unexpected streams stdout=%q stderr=%q", command.Stdout, command.Stderr)
+	}
+	if command.ExitCode == nil || *command.ExitCode != 1 || command.DurationMs == nil || *command.DurationMs != 1250 {
+		t.Fatalf("unexpected exit code or duration: %+v", command)
+	}
+
+	encoded, err := json.Marshal(command)
+	if err != nil {
+		t.Fatalf("marshal command: %v", err)
+	}
+	roundTripped, err := decodeThreadItem(encoded)
+	if err != nil {
+		t.Fatalf("decode round-tripped command: %v", err)
+	}
+	if !reflect.DeepEqual(roundTripped, item) {
+		t.Fatalf("round trip changed the item: %+v, want %+v", roundTripped, item)
+	}
+
+	aggregated := CommandExecutionItem{AggregatedOutput: "boom", ExitCode: command.ExitCode, Status: CommandExecutionStatusFailed}
+	if stdout, stderr := aggregated.OutputStreams(); stdout != "" || stderr != "boom" {
+		t.Fatalf("expected failed aggregated output on stderr, got stdout=%q stderr=%q", stdout, stderr)
+	}
+	aggregated = CommandExecutionItem{AggregatedOutput: "done", Status: CommandExecutionStatusCompleted}
+	if stdout, stderr := aggregated.OutputStreams(); stdout != "done" || stderr != "" {
+		t.Fatalf("expected successful aggregated output on stdout, got stdout=%q stderr=%q", stdout, stderr)
+	}
+}
+
+func TestDecodeMcpToolCallArgumentsAndResult(t *testing.T) {
+	raw := []byte(`{
+  "id": "item_4",
+  "type": "mcp_tool_call",
+  "server": "github",
+  "tool": "search_issues",
+  "arguments": {"query": "is:open label:bug", "per_page": 5},
+  "result": {"content": [{"type": "text", "text": "3 issues"}], "structured_content": null},
+  "status": "completed"
+}`)
+	item, err := decodeThreadItem(raw)
+	if err != nil {
+		t.Fatalf("decodeThreadItem returned error: %v", err)
+	}
+	call, ok := item.(McpToolCallItem)
+	if !ok {
+		t.Fatalf("expected McpToolCallItem, got %T", item)
+	}
+	var args map[string]any
+	if err := json.Unmarshal(call.Arguments, &args); err != nil || args["query"] != "is:open label:bug" {
+		t.Fatalf("unexpected arguments %s (%v)", call.Arguments, err)
+	}
+	if !bytes.Contains(call.Result, []byte(`"3 issues"`)) {
+		t.Fatalf("unexpected result %s", call.Result)
+	}
+	if call.Error != "" {
+		t.Fatalf("expected no error, got %q", call.Error)
+	}
+
+	item, err = decodeThreadItem([]byte(`{"id":"item_5","type":"mcp_tool_call","server":"github","tool":"search_issues","status":"in_progress"}`))
+	if err != nil {
+		t.Fatalf("decodeThreadItem returned error: %v", err)
+	}
+	call = item.(McpToolCallItem)
+	if call.Arguments != nil || call.Result != nil || call.Error != "" {
+		t.Fatalf("expected omitted fields to stay empty, got %+v", call)
+	}
+
+	for _, payload := range []string{`"rate limited"`, `{"message":"rate limited"}`} {
+		item, err = decodeThreadItem([]byte(`{"id":"item_6","type":"mcp_tool_call","server":"github","tool":"search_issues","status":"failed","error":` + payload + `}`))
+		if err != nil {
+			t.Fatalf("decodeThreadItem(%s) returned error: %v", payload, err)
+		}
+		if call := item.(McpToolCallItem); call.Error != "rate limited" {
+			t.Fatalf("expected error message from %s, got %q", payload, call.Error)
+		}
+	}
+}
+
+func TestDecodeThreadEventPreservesRawLine(t *testing.T) {
+	lines := []string{
+		`{"type":"thread.started","thread_id":"thread_1"}`,
+		`{"type":"item.completed","item":{"id":"item_1","type":"agent_message","text":"hi"},"extra":true}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":2}}`,
+	}
+	for _, line := range lines {
+		data := []byte(line)
+		event, err := decodeThreadEvent(data)
+		if err != nil {
+			t.Fatalf("decodeThreadEvent(%s) returned error: %v", line, err)
+		}
+		// The decoder must not alias the caller's buffer, which the line reader reuses.
+		copy(data, bytes.Repeat([]byte{'x'}, len(data)))
+		if got := string(event.Raw()); got != line {
+			t.Fatalf("expected Raw to return %s, got %s", line, got)
+		}
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("marshal %T: %v", event, err)
+		}
+		if bytes.Contains(encoded, []byte("raw")) {
+			t.Fatalf("expected the raw line to stay out of the JSON encoding, got %s", encoded)
+		}
+	}
+}
+
 func TestCreateOutputSchemaFile(t *testing.T) {
 	path, cleanup, err := createOutputSchemaFile(map[string]any{
 		"type": "object",
-	})
+	}, 0, nil)
 	if err != nil {
 		t.Fatalf("createOutputSchemaFile returned error: %v", err)
 	}
@@ -71,7 +247,179 @@ func TestCreateOutputSchemaFile(t *testing.T) {
 }
 
 func TestCreateOutputSchemaFileRejectsNonObject(t *testing.T) {
-	if _, _, err := createOutputSchemaFile([]string{"not", "object"}); err == nil {
+	if _, _, err := createOutputSchemaFile([]string{"not", "object"}, 0, nil); err == nil {
 		t.Fatal("expected error for non-object schema but received none")
 	}
 }
+
+func TestCreateOutputSchemaFileRejectsOversizedSchema(t *testing.T) {
+	schema := map[string]any{
+		"type":        "object",
+		"description": strings.Repeat("x", 512),
+	}
+
+	if _, _, err := createOutputSchemaFile(schema, 256, nil); !errors.Is(err, ErrSchemaTooLarge) {
+		t.Fatalf("expected ErrSchemaTooLarge, got %v", err)
+	}
+
+	path, cleanup, err := createOutputSchemaFile(schema, -1, nil)
+	if err != nil {
+		t.Fatalf("expected negative limit to disable the check, got %v", err)
+	}
+	defer cleanup()
+	if path == "" {
+		t.Fatal("expected schema file path")
+	}
+}
+
+func TestDecodeThreadEventTurnCompletedFinishReason(t *testing.T) {
+	raw := []byte(`{"type":"turn.completed","usage":{"input_tokens":3,"cached_input_tokens":0,"output_tokens":9},"finish_reason":"length"}`)
+	event, err := decodeThreadEvent(raw)
+	if err != nil {
+		t.Fatalf("decodeThreadEvent returned error: %v", err)
+	}
+	completed, ok := event.(TurnCompletedEvent)
+	if !ok {
+		t.Fatalf("expected TurnCompletedEvent, got %T", event)
+	}
+	if completed.FinishReason != FinishReasonLength {
+		t.Fatalf("unexpected finish reason %q", completed.FinishReason)
+	}
+}
+
+func TestUsageCacheHitRatio(t *testing.T) {
+	cases := []struct {
+		name  string
+		usage Usage
+		want  float64
+	}{
+		{name: "no input tokens", usage: Usage{CachedInputTokens: 5}, want: 0},
+		{name: "no cache hits", usage: Usage{InputTokens: 200}, want: 0},
+		{name: "partial cache", usage: Usage{InputTokens: 200, CachedInputTokens: 50}, want: 0.25},
+		{name: "fully cached", usage: Usage{InputTokens: 80, CachedInputTokens: 80}, want: 1},
+	}
+	for _, tc := range cases {
+		if got := tc.usage.CacheHitRatio(); got != tc.want {
+			t.Fatalf("%s: expected ratio %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestUsageTotalTokensAndString(t *testing.T) {
+	usage := Usage{InputTokens: 1200, CachedInputTokens: 300, OutputTokens: 450, ReasoningTokens: 200}
+	if got := usage.TotalTokens(); got != 1650 {
+		t.Fatalf("expected 1650 total tokens, got %d", got)
+	}
+	if got, want := usage.String(), "1650 tokens (1200 input, 300 cached; 450 output, 200 reasoning)"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	usage.ReasoningTokens = 0
+	if got, want := usage.String(), "1650 tokens (1200 input, 300 cached; 450 output)"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if got := (Usage{}).TotalTokens(); got != 0 {
+		t.Fatalf("expected zero usage to total 0, got %d", got)
+	}
+}
+
+func TestDecodeTurnCompletedReasoningTokens(t *testing.T) {
+	event, err := decodeThreadEvent([]byte(`{"type":"turn.completed","usage":{"input_tokens":10,"cached_input_tokens":4,"output_tokens":8,"reasoning_output_tokens":5}}`))
+	if err != nil {
+		t.Fatalf("decodeThreadEvent returned error: %v", err)
+	}
+	usage := event.(TurnCompletedEvent).Usage
+	if usage.ReasoningTokens != 5 || usage.TotalTokens() != 18 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	event, err = decodeThreadEvent([]byte(`{"type":"turn.completed","usage":{"input_tokens":10,"cached_input_tokens":4,"output_tokens":8}}`))
+	if err != nil {
+		t.Fatalf("decodeThreadEvent returned error: %v", err)
+	}
+	if usage := event.(TurnCompletedEvent).Usage; usage.ReasoningTokens != 0 {
+		t.Fatalf("expected no reasoning tokens when omitted, got %d", usage.ReasoningTokens)
+	}
+}
+
+func TestUsageDelta(t *testing.T) {
+	prev := Usage{InputTokens: 1000, CachedInputTokens: 200, OutputTokens: 300, ReasoningTokens: 50}
+	curr := Usage{InputTokens: 1800, CachedInputTokens: 900, OutputTokens: 420, ReasoningTokens: 80}
+
+	want := Usage{InputTokens: 800, CachedInputTokens: 700, OutputTokens: 120, ReasoningTokens: 30}
+	if got := UsageDelta(prev, curr); got != want {
+		t.Fatalf("expected delta %+v, got %+v", want, got)
+	}
+	if got := prev.add(UsageDelta(prev, curr)); got != curr {
+		t.Fatalf("expected prev plus delta to equal curr, got %+v", got)
+	}
+	if got := UsageDelta(curr, curr); got != (Usage{}) {
+		t.Fatalf("expected zero delta for equal snapshots, got %+v", got)
+	}
+}
+
+func TestEventDecoderStrictRejectsUnknownFields(t *testing.T) {
+	raw := []byte(`{"type":"item.completed","item":{"id":"cmd_1","type":"command_execution","command":"ls","aggregated_output":"","status":"completed","pid":42}}`)
+
+	event, err := eventDecoder{}.decodeThreadEvent(raw)
+	if err != nil {
+		t.Fatalf("lenient decode returned error: %v", err)
+	}
+	if _, ok := event.(ItemCompletedEvent).Item.(CommandExecutionItem); !ok {
+		t.Fatalf("expected command execution item, got %#v", event)
+	}
+
+	_, err = eventDecoder{strict: true}.decodeThreadEvent(raw)
+	if err == nil {
+		t.Fatal("expected strict decode to reject the unknown field")
+	}
+	for _, want := range []string{"item.completed", "command execution item", `"pid"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error to mention %s, got %v", want, err)
+		}
+	}
+
+	if _, err := (eventDecoder{strict: true}).decodeThreadEvent([]byte(`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`)); err != nil {
+		t.Fatalf("strict decode rejected a fully modelled event: %v", err)
+	}
+}
+
+func TestEventDecoderUsesCustomUnmarshalFunc(t *testing.T) {
+	lines := [][]byte{
+		[]byte(`{"type":"thread.started","thread_id":"thread_1"}`),
+		[]byte(`{"type":"item.completed","item":{"id":"tool_1","type":"mcp_tool_call","server":"docs","tool":"search","status":"failed","error":{"message":"boom"}}}`),
+		[]byte(`{"type":"turn.completed","usage":{"input_tokens":3,"cached_input_tokens":1,"output_tokens":2}}`),
+	}
+
+	calls := 0
+	custom := newEventDecoder(CodexOptions{Decoder: func(data []byte, v any) error {
+		calls++
+		return json.Unmarshal(data, v)
+	}})
+	for _, line := range lines {
+		want, err := decodeThreadEvent(line)
+		if err != nil {
+			t.Fatalf("decodeThreadEvent returned error: %v", err)
+		}
+		got, err := custom.decodeThreadEvent(line)
+		if err != nil {
+			t.Fatalf("custom decoder returned error: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("custom decoder produced %#v, want %#v", got, want)
+		}
+	}
+	if calls == 0 {
+		t.Fatal("expected the custom unmarshal function to be invoked")
+	}
+}
+
+func BenchmarkDecodeThreadEvent(b *testing.B) {
+	line := []byte(`{"type":"item.completed","item":{"id":"cmd_1","type":"command_execution","command":"go test ./...","aggregated_output":"ok  \tgithub.com/activadee/godex\t0.412s\n","exit_code":0,"status":"completed"}}`)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := decodeThreadEvent(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
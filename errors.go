@@ -0,0 +1,29 @@
+package godex
+
+import "github.com/activadee/godex/internal/codexexec"
+
+// ErrDiskFull reports that the SDK could not write a cached binary or temporary file because
+// the filesystem ran out of space. Use errors.Is to detect it.
+var ErrDiskFull = codexexec.ErrDiskFull
+
+// DiskFullError carries the path whose write failed with ENOSPC. Use errors.As to inspect it.
+type DiskFullError = codexexec.DiskFullError
+
+// CodexExecError reports that the Codex CLI exited with a non-zero status. It exposes the exit
+// code, captured stderr and the CLI arguments; use errors.As to inspect it.
+type CodexExecError = codexexec.ExecError
+
+// ErrSignatureInvalid reports that a downloaded Codex CLI release asset failed verification
+// against its detached signature when CodexOptions.CLISignaturePublicKey is set.
+var ErrSignatureInvalid = codexexec.ErrSignatureInvalid
+
+// ErrChecksumMismatch reports that a Codex CLI binary did not match CodexOptions.CLIChecksum.
+var ErrChecksumMismatch = codexexec.ErrChecksumMismatch
+
+// ErrBinaryNotCached is returned by VerifyCachedBinary when no Codex CLI binary is cached for
+// the current platform and release.
+var ErrBinaryNotCached = codexexec.ErrBinaryNotCached
+
+// ErrBinaryNotRunnable reports that a freshly downloaded Codex CLI binary failed to run
+// `codex --version` while CodexOptions.VerifyBinaryRuns is set.
+var ErrBinaryNotRunnable = codexexec.ErrBinaryNotRunnable
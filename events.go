@@ -1,10 +1,67 @@
 package godex
 
-// Usage captures token consumption metrics for a completed turn.
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Usage captures token consumption metrics for a completed turn. As reported by the CLI,
+// CachedInputTokens is the part of InputTokens served from the prompt cache and
+// ReasoningTokens the part of OutputTokens spent on reasoning.
 type Usage struct {
 	InputTokens       int `json:"input_tokens"`
 	CachedInputTokens int `json:"cached_input_tokens"`
 	OutputTokens      int `json:"output_tokens"`
+	// ReasoningTokens is zero when the CLI does not report it.
+	ReasoningTokens int `json:"reasoning_output_tokens,omitempty"`
+}
+
+// TotalTokens returns the tokens billed for the turn: input (cached input included) plus
+// output (reasoning included).
+func (u Usage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// String renders a compact summary such as
+// "1650 tokens (1200 input, 300 cached; 450 output, 200 reasoning)".
+func (u Usage) String() string {
+	s := fmt.Sprintf("%d tokens (%d input, %d cached; %d output", u.TotalTokens(), u.InputTokens, u.CachedInputTokens, u.OutputTokens)
+	if u.ReasoningTokens > 0 {
+		s += fmt.Sprintf(", %d reasoning", u.ReasoningTokens)
+	}
+	return s + ")"
+}
+
+// UsageDelta returns the usage added between two snapshots of a running total, such as
+// Thread.TotalUsage before and after a turn: every field of curr minus the same field of prev.
+// prev must be the earlier snapshot of the same total.
+func UsageDelta(prev, curr Usage) Usage {
+	return Usage{
+		InputTokens:       curr.InputTokens - prev.InputTokens,
+		CachedInputTokens: curr.CachedInputTokens - prev.CachedInputTokens,
+		OutputTokens:      curr.OutputTokens - prev.OutputTokens,
+		ReasoningTokens:   curr.ReasoningTokens - prev.ReasoningTokens,
+	}
+}
+
+// add returns the field-wise sum of u and other.
+func (u Usage) add(other Usage) Usage {
+	return Usage{
+		InputTokens:       u.InputTokens + other.InputTokens,
+		CachedInputTokens: u.CachedInputTokens + other.CachedInputTokens,
+		OutputTokens:      u.OutputTokens + other.OutputTokens,
+		ReasoningTokens:   u.ReasoningTokens + other.ReasoningTokens,
+	}
+}
+
+// CacheHitRatio returns the fraction of input tokens served from the prompt cache, or 0 when
+// no input tokens were reported.
+func (u Usage) CacheHitRatio() float64 {
+	if u.InputTokens <= 0 {
+		return 0
+	}
+	return float64(u.CachedInputTokens) / float64(u.InputTokens)
 }
 
 // ThreadError represents a fatal error emitted for the turn.
@@ -27,6 +84,21 @@ func (e *ThreadStreamError) Error() string {
 	return e.Message
 }
 
+// TurnFailedError reports that the Codex CLI ended a turn with a `turn.failed` event. It is
+// returned by Run/RunInputs and by RunStreamedResult.Wait after such an event; use errors.As
+// to inspect the CLI's error payload.
+type TurnFailedError struct {
+	ThreadError
+}
+
+// Error implements the error interface.
+func (e *TurnFailedError) Error() string {
+	if e == nil {
+		return ""
+	}
+	return e.Message
+}
+
 // ThreadEventType enumerates the JSON event types streamed by the Codex CLI.
 type ThreadEventType string
 
@@ -39,16 +111,30 @@ const (
 	ThreadEventTypeItemUpdated   ThreadEventType = "item.updated"
 	ThreadEventTypeItemCompleted ThreadEventType = "item.completed"
 	ThreadEventTypeError         ThreadEventType = "error"
+	ThreadEventTypeRateLimit     ThreadEventType = "rate_limit"
 )
 
 // ThreadEvent is the interface implemented by all event variants returned by the CLI.
 type ThreadEvent interface {
 	threadEvent()
 	EventType() ThreadEventType
+	// Raw returns the JSON line the event was decoded from, or nil for events constructed in
+	// code. Callers must not modify the returned slice.
+	Raw() []byte
 }
 
+// rawLine records the original JSON line of a decoded event. It is embedded in every event
+// type and ignored by encoding/json.
+type rawLine struct {
+	raw []byte
+}
+
+// Raw returns the JSON line the event was decoded from.
+func (r rawLine) Raw() []byte { return r.raw }
+
 // ThreadStartedEvent is emitted when a new thread is created.
 type ThreadStartedEvent struct {
+	rawLine
 	Type     ThreadEventType `json:"type"`
 	ThreadID string          `json:"thread_id"`
 }
@@ -58,16 +144,28 @@ func (e ThreadStartedEvent) EventType() ThreadEventType { return e.Type }
 
 // TurnStartedEvent marks the beginning of a new turn.
 type TurnStartedEvent struct {
+	rawLine
 	Type ThreadEventType `json:"type"`
 }
 
 func (TurnStartedEvent) threadEvent()                 {}
 func (e TurnStartedEvent) EventType() ThreadEventType { return e.Type }
 
+// Finish reasons reported on TurnCompletedEvent.FinishReason.
+const (
+	FinishReasonStop          = "stop"
+	FinishReasonLength        = "length"
+	FinishReasonContentFilter = "content_filter"
+)
+
 // TurnCompletedEvent indicates a successful completion of a turn.
 type TurnCompletedEvent struct {
+	rawLine
 	Type  ThreadEventType `json:"type"`
 	Usage Usage           `json:"usage"`
+	// FinishReason explains why the turn ended (for example FinishReasonLength when the
+	// response was truncated). Empty when the CLI omits it.
+	FinishReason string `json:"finish_reason,omitempty"`
 }
 
 func (TurnCompletedEvent) threadEvent()                 {}
@@ -75,8 +173,11 @@ func (e TurnCompletedEvent) EventType() ThreadEventType { return e.Type }
 
 // TurnFailedEvent signals that a turn ended due to a fatal error.
 type TurnFailedEvent struct {
+	rawLine
 	Type  ThreadEventType `json:"type"`
 	Error ThreadError     `json:"error"`
+	// Usage reports tokens consumed before the failure. Nil when the CLI omits it.
+	Usage *Usage `json:"usage,omitempty"`
 }
 
 func (TurnFailedEvent) threadEvent()                 {}
@@ -84,6 +185,7 @@ func (e TurnFailedEvent) EventType() ThreadEventType { return e.Type }
 
 // ItemStartedEvent emits when a thread item is created.
 type ItemStartedEvent struct {
+	rawLine
 	Type ThreadEventType `json:"type"`
 	Item ThreadItem      `json:"item"`
 }
@@ -93,6 +195,7 @@ func (e ItemStartedEvent) EventType() ThreadEventType { return e.Type }
 
 // ItemUpdatedEvent emits as an item transitions between intermediate states.
 type ItemUpdatedEvent struct {
+	rawLine
 	Type ThreadEventType `json:"type"`
 	Item ThreadItem      `json:"item"`
 }
@@ -102,6 +205,7 @@ func (e ItemUpdatedEvent) EventType() ThreadEventType { return e.Type }
 
 // ItemCompletedEvent signals an item reaching a terminal state.
 type ItemCompletedEvent struct {
+	rawLine
 	Type ThreadEventType `json:"type"`
 	Item ThreadItem      `json:"item"`
 }
@@ -109,8 +213,53 @@ type ItemCompletedEvent struct {
 func (ItemCompletedEvent) threadEvent()                 {}
 func (e ItemCompletedEvent) EventType() ThreadEventType { return e.Type }
 
+// RateLimitEvent reports that the CLI hit a rate limit and is backing off before retrying. The
+// turn keeps running; the event only informs callers, for example to show a waiting state.
+type RateLimitEvent struct {
+	rawLine
+	Type    ThreadEventType `json:"type"`
+	Message string          `json:"message,omitempty"`
+	// RetryAfterMs is how long the CLI waits before retrying, in milliseconds. Zero when the
+	// CLI does not say.
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+	// Attempt numbers the retry, starting at 1. Zero when the CLI omits it.
+	Attempt int `json:"attempt,omitempty"`
+}
+
+func (RateLimitEvent) threadEvent()                 {}
+func (e RateLimitEvent) EventType() ThreadEventType { return e.Type }
+
+// RetryAfter returns RetryAfterMs as a duration.
+func (e RateLimitEvent) RetryAfter() time.Duration {
+	return time.Duration(e.RetryAfterMs) * time.Millisecond
+}
+
+// UnknownEvent carries an event whose type this version of the SDK does not recognize, such
+// as one introduced by a newer CLI. It is delivered like any other event instead of failing
+// the stream, so callers can inspect or skip it.
+type UnknownEvent struct {
+	Type ThreadEventType
+	// Payload is the event's original JSON line.
+	Payload json.RawMessage
+}
+
+func (UnknownEvent) threadEvent()                 {}
+func (e UnknownEvent) EventType() ThreadEventType { return e.Type }
+
+// Raw returns Payload.
+func (e UnknownEvent) Raw() []byte { return e.Payload }
+
+// MarshalJSON returns the original payload so transcripts keep the event unchanged.
+func (e UnknownEvent) MarshalJSON() ([]byte, error) {
+	if len(e.Payload) == 0 {
+		return json.Marshal(map[string]ThreadEventType{"type": e.Type})
+	}
+	return e.Payload, nil
+}
+
 // ThreadErrorEvent is emitted when the stream itself experiences an unrecoverable error.
 type ThreadErrorEvent struct {
+	rawLine
 	Type    ThreadEventType `json:"type"`
 	Message string          `json:"message"`
 }
@@ -0,0 +1,87 @@
+package godex
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FingerprintTurn returns a stable SHA-256 fingerprint (hex encoded) of a turn's effective
+// request: the normalized prompt, the contents of each image, the output schema and the
+// options that change the response. Identical requests yield identical fingerprints, which
+// makes the value suitable as a cache key. Image files are hashed by content, so renaming a
+// file does not change the fingerprint while editing it does. Missing or unreadable images
+// return an error naming the file.
+func FingerprintTurn(prompt string, images []string, turnOptions *TurnOptions) (string, error) {
+	var opts TurnOptions
+	if turnOptions != nil {
+		opts = *turnOptions
+	}
+
+	h := sha256.New()
+	writeField(h, "prompt", []byte(normalizePrompt(prompt)))
+
+	for i, image := range images {
+		sum, err := hashFile(image)
+		if err != nil {
+			return "", fmt.Errorf("fingerprint image %d (%s): %w", i, image, err)
+		}
+		writeField(h, "image", sum)
+	}
+
+	if opts.OutputSchema != nil {
+		schema, err := json.Marshal(opts.OutputSchema)
+		if err != nil {
+			return "", fmt.Errorf("fingerprint output schema: %w", err)
+		}
+		writeField(h, "schema", schema)
+	}
+	if opts.OutputSchemaPath != "" {
+		sum, err := hashFile(opts.OutputSchemaPath)
+		if err != nil {
+			return "", fmt.Errorf("fingerprint output schema file %s: %w", opts.OutputSchemaPath, err)
+		}
+		writeField(h, "schema-file", sum)
+	}
+	if opts.AutoContinue {
+		writeField(h, "auto-continue", []byte(strconv.Itoa(opts.MaxContinuations)))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// normalizePrompt unifies line endings and trims surrounding whitespace so cosmetic
+// differences do not change the fingerprint.
+func normalizePrompt(prompt string) string {
+	return strings.TrimSpace(strings.ReplaceAll(prompt, "\r\n", "\n"))
+}
+
+// writeField writes a length-prefixed, labelled field so adjacent fields cannot collide.
+func writeField(h hash.Hash, label string, value []byte) {
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(value)))
+	_, _ = io.WriteString(h, label)
+	_, _ = h.Write(size[:])
+	_, _ = h.Write(value)
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
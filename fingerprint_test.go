@@ -0,0 +1,60 @@
+package godex
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprintTurnIsStable(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "diagram.png")
+	if err := os.WriteFile(image, []byte("png-bytes"), 0o600); err != nil {
+		t.Fatalf("write image: %v", err)
+	}
+	copied := filepath.Join(dir, "copy.png")
+	if err := os.WriteFile(copied, []byte("png-bytes"), 0o600); err != nil {
+		t.Fatalf("write image copy: %v", err)
+	}
+	opts := &TurnOptions{OutputSchema: map[string]any{"type": "object", "required": []string{"a"}}}
+
+	first, err := FingerprintTurn("Summarize\r\n", []string{image}, opts)
+	if err != nil {
+		t.Fatalf("FingerprintTurn returned error: %v", err)
+	}
+	second, err := FingerprintTurn("  Summarize", []string{copied}, opts)
+	if err != nil {
+		t.Fatalf("FingerprintTurn returned error: %v", err)
+	}
+	if first != second {
+		t.Fatalf("expected identical inputs to match: %s vs %s", first, second)
+	}
+
+	if err := os.WriteFile(copied, []byte("edited-bytes"), 0o600); err != nil {
+		t.Fatalf("rewrite image: %v", err)
+	}
+	changed, err := FingerprintTurn("Summarize", []string{copied}, opts)
+	if err != nil {
+		t.Fatalf("FingerprintTurn returned error: %v", err)
+	}
+	if changed == first {
+		t.Fatal("expected changed image contents to change the fingerprint")
+	}
+
+	noSchema, err := FingerprintTurn("Summarize", []string{image}, nil)
+	if err != nil {
+		t.Fatalf("FingerprintTurn returned error: %v", err)
+	}
+	if noSchema == first {
+		t.Fatal("expected schema to contribute to the fingerprint")
+	}
+}
+
+func TestFingerprintTurnReportsMissingImage(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.png")
+	_, err := FingerprintTurn("hello", []string{missing}, nil)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+}
@@ -2,7 +2,10 @@ module github.com/activadee/godex
 
 go 1.22
 
-require github.com/invopop/jsonschema v0.13.0
+require (
+	github.com/invopop/jsonschema v0.13.0
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+)
 
 require (
 	github.com/bahlo/generic-list-go v0.2.0 // indirect
@@ -8,7 +8,11 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+
+	"github.com/activadee/godex/internal/codexexec"
 )
 
 // InputSegment represents a piece of user-provided input sent to the Codex CLI.
@@ -22,7 +26,9 @@ type InputSegment struct {
 	// forwarded to the CLI via --image. Leave empty for text segments.
 	LocalImagePath string
 
-	cleanup func()
+	// imageURL is set by LazyURLImageSegment; the image is downloaded when the turn starts.
+	imageURL string
+	cleanup  func()
 }
 
 // TextSegment creates a textual input segment. Multiple text segments are
@@ -41,6 +47,10 @@ func LocalImageSegment(path string) InputSegment {
 const (
 	maxURLImageSizeBytes = 8 << 20 // 8 MiB safety limit for remote downloads
 	sniffBufferSize      = 512
+
+	// defaultImageDownloadConcurrency bounds parallel LazyURLImageSegment downloads when
+	// CodexOptions.ImageDownloadConcurrency is unset.
+	defaultImageDownloadConcurrency = 4
 )
 
 // URLImageSegment downloads an image from the provided URL into a temporary file and
@@ -50,6 +60,18 @@ func URLImageSegment(ctx context.Context, rawURL string) (InputSegment, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	return downloadImageSegment(ctx, rawURL, nil, "")
+}
+
+// LazyURLImageSegment references a remote image that is downloaded only when the turn starts,
+// using the turn's context. Downloads for a turn run in parallel, at most
+// CodexOptions.ImageDownloadConcurrency at a time, and the files are cleaned up when the run
+// finishes. Turns with lazy images bypass CodexOptions.ResponseCache.
+func LazyURLImageSegment(rawURL string) InputSegment {
+	return InputSegment{imageURL: rawURL}
+}
+
+func downloadImageSegment(ctx context.Context, rawURL string, budget *tempBudget, what string) (InputSegment, error) {
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
 	if err != nil {
@@ -97,7 +119,7 @@ func URLImageSegment(ctx context.Context, rawURL string) (InputSegment, error) {
 		}
 	}
 
-	path, cleanup, err := writeTempImageStream(ext, sniff[:n], limited, maxURLImageSizeBytes)
+	path, cleanup, err := writeTempImageStream(ext, sniff[:n], limited, maxURLImageSizeBytes, budget, what)
 	if err != nil {
 		return InputSegment{}, err
 	}
@@ -137,6 +159,93 @@ func BytesImageSegment(name string, data []byte) (InputSegment, error) {
 	return newTempImageSegment(data, ext)
 }
 
+// hasLazyImages reports whether any segment still needs to be downloaded.
+func hasLazyImages(segments []InputSegment) bool {
+	for _, segment := range segments {
+		if segment.imageURL != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveLazyImages downloads the images of LazyURLImageSegment entries, running at most
+// concurrency downloads at once, and returns the segments with those entries replaced by local
+// image segments. Downloaded bytes are reserved in budget as they are written, so a download
+// that would exceed it stops early. If any download fails, the remaining downloads are
+// cancelled, every file downloaded so far is removed and the first error is returned.
+func resolveLazyImages(ctx context.Context, segments []InputSegment, concurrency int, budget *tempBudget) ([]InputSegment, error) {
+	if !hasLazyImages(segments) {
+		return segments, nil
+	}
+	if concurrency <= 0 {
+		concurrency = defaultImageDownloadConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resolved := slices.Clone(segments)
+	slots := make(chan struct{}, concurrency)
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		errMu.Lock()
+		defer errMu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for i, segment := range segments {
+		if segment.imageURL == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, rawURL string) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				fail(ctx.Err())
+				return
+			}
+			defer func() { <-slots }()
+
+			downloaded, err := downloadImageSegment(ctx, rawURL, budget, fmt.Sprintf("image for input segment %d", i))
+			if err != nil {
+				fail(fmt.Errorf("input segment %d: %w", i, err))
+				return
+			}
+			resolved[i] = downloaded
+		}(i, segment.imageURL)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		for i, segment := range resolved {
+			if segments[i].imageURL != "" && segment.cleanup != nil {
+				segment.cleanup()
+			}
+		}
+		return nil, firstErr
+	}
+	return resolved, nil
+}
+
+// cleanupInputSegments removes the temporary files owned by segments.
+func cleanupInputSegments(segments []InputSegment) {
+	for _, segment := range segments {
+		if segment.cleanup != nil {
+			segment.cleanup()
+		}
+	}
+}
+
 type normalizedInput struct {
 	prompt  string
 	images  []string
@@ -209,7 +318,7 @@ func writeTempImageBytes(ext string, data []byte) (string, func(), error) {
 	})
 }
 
-func writeTempImageStream(ext string, head []byte, body io.Reader, maxSize int64) (string, func(), error) {
+func writeTempImageStream(ext string, head []byte, body io.Reader, maxSize int64, budget *tempBudget, what string) (string, func(), error) {
 	validator := func(total int64) error {
 		if total == 0 {
 			return fmt.Errorf("download image: empty response body")
@@ -221,15 +330,16 @@ func writeTempImageStream(ext string, head []byte, body io.Reader, maxSize int64
 	}
 
 	return writeTempImageFile(ext, func(f *os.File) (int64, error) {
+		w := budget.writer(f, what)
 		var total int64
 		if len(head) > 0 {
-			n, err := f.Write(head)
+			n, err := w.Write(head)
 			total += int64(n)
 			if err != nil {
 				return total, err
 			}
 		}
-		written, err := io.Copy(f, body)
+		written, err := io.Copy(w, body)
 		total += written
 		return total, err
 	}, validator)
@@ -248,7 +358,7 @@ func writeTempImageFile(ext string, writer func(*os.File) (int64, error), valida
 
 	file, err := os.CreateTemp("", pattern)
 	if err != nil {
-		return "", nil, fmt.Errorf("create temp image: %w", err)
+		return "", nil, codexexec.CheckDiskFull(os.TempDir(), fmt.Errorf("create temp image: %w", err))
 	}
 
 	path := file.Name()
@@ -260,7 +370,7 @@ func writeTempImageFile(ext string, writer func(*os.File) (int64, error), valida
 	if err != nil {
 		_ = file.Close()
 		cleanup()
-		return "", nil, fmt.Errorf("write temp image: %w", err)
+		return "", nil, codexexec.CheckDiskFull(path, fmt.Errorf("write temp image: %w", err))
 	}
 
 	for _, validate := range validators {
@@ -276,7 +386,7 @@ func writeTempImageFile(ext string, writer func(*os.File) (int64, error), valida
 
 	if err := file.Close(); err != nil {
 		cleanup()
-		return "", nil, fmt.Errorf("close temp image: %w", err)
+		return "", nil, codexexec.CheckDiskFull(path, fmt.Errorf("close temp image: %w", err))
 	}
 
 	return path, cleanup, nil
@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNormalizeInputUsesBaseWhenNoSegments(t *testing.T) {
@@ -186,3 +189,152 @@ func (zeroReader) Read(p []byte) (int, error) {
 	}
 	return len(p), nil
 }
+
+func TestLazyURLImageSegmentsRespectDownloadConcurrency(t *testing.T) {
+	imageData := decodeBase64(t, "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR4nGP4//8/AAX+Av7l/wAAAABJRU5ErkJggg==")
+
+	var inFlight, peak, requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			previous := peak.Load()
+			if current <= previous || peak.CompareAndSwap(previous, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(imageData)
+	}))
+	defer server.Close()
+
+	segments := []InputSegment{TextSegment("describe these")}
+	for i := 0; i < 8; i++ {
+		segments = append(segments, LazyURLImageSegment(fmt.Sprintf("%s/image-%d.png", server.URL, i)))
+	}
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+	thread := newThread(runner, CodexOptions{ImageDownloadConcurrency: 2}, ThreadOptions{}, "")
+	if _, err := thread.RunInputs(context.Background(), segments, nil); err != nil {
+		t.Fatalf("RunInputs returned error: %v", err)
+	}
+
+	if got := requests.Load(); got != 8 {
+		t.Fatalf("expected 8 downloads, got %d", got)
+	}
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("expected at most 2 concurrent downloads, observed %d", got)
+	}
+	images := runner.lastCall().Images
+	if len(images) != 8 {
+		t.Fatalf("expected 8 images passed to the CLI, got %v", images)
+	}
+	for _, path := range images {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected downloaded image %s to be cleaned up, got %v", path, err)
+		}
+	}
+}
+
+func TestLazyURLImageSegmentReportsFailedDownload(t *testing.T) {
+	imageData := decodeBase64(t, "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR4nGP4//8/AAX+Av7l/wAAAABJRU5ErkJggg==")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/missing.png") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(imageData)
+	}))
+	defer server.Close()
+
+	segments := []InputSegment{
+		LazyURLImageSegment(server.URL + "/ok.png"),
+		LazyURLImageSegment(server.URL + "/missing.png"),
+	}
+	_, err := resolveLazyImages(context.Background(), segments, 1, nil)
+	if err == nil || !strings.Contains(err.Error(), "input segment 1") {
+		t.Fatalf("expected the failed segment to be reported, got %v", err)
+	}
+}
+
+func TestMaxTempBytesAbortsBeforeLaunchingCLI(t *testing.T) {
+	png := decodeBase64(t, "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR4nGP4//8/AAX+Av7l/wAAAABJRU5ErkJggg==")
+	oversized := append(append([]byte(nil), png...), make([]byte, 4096)...)
+
+	newSegments := func() []InputSegment {
+		t.Helper()
+		segment, err := BytesImageSegment("large.png", oversized)
+		if err != nil {
+			t.Fatalf("BytesImageSegment returned error: %v", err)
+		}
+		return []InputSegment{TextSegment("describe"), segment}
+	}
+
+	runner := &fakeRunner{t: t}
+	thread := newThread(runner, CodexOptions{MaxTempBytes: 1024}, ThreadOptions{}, "")
+	segments := newSegments()
+	_, err := thread.RunInputs(context.Background(), segments, nil)
+	if !errors.Is(err, ErrTempBytesExceeded) {
+		t.Fatalf("expected ErrTempBytesExceeded, got %v", err)
+	}
+	if len(runner.calls) != 0 {
+		t.Fatalf("expected the CLI not to be launched, got %d calls", len(runner.calls))
+	}
+	if _, statErr := os.Stat(segments[1].LocalImagePath); !os.IsNotExist(statErr) {
+		t.Fatalf("expected the temp image to be removed, stat error: %v", statErr)
+	}
+
+	var started TurnLifecycleEvent
+	runner = &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+	thread = newThread(runner, CodexOptions{
+		MaxTempBytes: 1 << 20,
+		LifecycleHook: func(event TurnLifecycleEvent) {
+			if event.Phase == TurnPhaseStarted {
+				started = event
+			}
+		},
+	}, ThreadOptions{}, "")
+	if _, err := thread.RunInputs(context.Background(), newSegments(), nil); err != nil {
+		t.Fatalf("RunInputs returned error: %v", err)
+	}
+	if started.TempBytes != int64(len(oversized)) {
+		t.Fatalf("expected %d temp bytes on the started event, got %d", len(oversized), started.TempBytes)
+	}
+}
+
+func TestMaxTempBytesStopsLazyDownloadEarly(t *testing.T) {
+	png := decodeBase64(t, "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR4nGP4//8/AAX+Av7l/wAAAABJRU5ErkJggg==")
+	const imageSize = 4 << 20
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(png)
+		_, _ = io.CopyN(w, zeroReader{}, imageSize-int64(len(png)))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	const limit = 64 << 10
+	budget := newTempBudget(limit)
+	segments := []InputSegment{LazyURLImageSegment(server.URL + "/large.png")}
+	_, err := resolveLazyImages(context.Background(), segments, 1, budget)
+	if !errors.Is(err, ErrTempBytesExceeded) {
+		t.Fatalf("expected ErrTempBytesExceeded, got %v", err)
+	}
+	if used := budget.bytes(); used > limit {
+		t.Fatalf("expected at most %d bytes written before the download was stopped, got %d", limit, used)
+	}
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the partial download to be removed, found %v", entries)
+	}
+}
@@ -5,16 +5,23 @@ import (
 	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -27,25 +34,142 @@ const (
 
 const defaultCodexReleaseTag = "rust-v0.55.0"
 
+// maxExtractedBinaryBytes bounds an archive entry staged while looking for the binary, far
+// above any real Codex build, so a corrupt or hostile archive cannot fill the disk. Tests
+// override it.
+var maxExtractedBinaryBytes int64 = 1 << 30
+
+const defaultDownloadBaseURL = "https://github.com/openai/codex/releases/download"
+
 var ErrChecksumMismatch = errors.New("codex bundle checksum mismatch")
 
+// ErrBinaryNotRunnable reports that a freshly downloaded Codex binary failed to run
+// `codex --version`, for example because it was built for a different libc.
+var ErrBinaryNotRunnable = errors.New("codex binary does not run")
+
 type bundleConfig struct {
-	cacheDir    string
-	releaseTag  string
-	checksumHex string
+	cacheDir     string
+	releaseTag   string
+	checksumHex  string
+	assetURLFunc func(release, assetName string) string
+	// forceDownload ignores any cached binary and replaces it with a fresh download.
+	forceDownload bool
+	// httpClient performs the release download; nil uses a client with a two minute timeout.
+	httpClient *http.Client
+	// baseURL replaces the GitHub release download location; assets are fetched from
+	// <baseURL>/<release>/<asset>.
+	baseURL string
+	// releaseMetadataURL replaces the GitHub API endpoint queried to resolve "latest".
+	releaseMetadataURL string
+	// signaturePublicKey, when set, is the ed25519 public key (hex or base64) that must verify
+	// the detached "<asset>.sig" signature of every downloaded release asset.
+	signaturePublicKey string
+	// downloadAttempts bounds how many times a transient download failure is attempted;
+	// values below one mean a single attempt.
+	downloadAttempts int
+	// retryBaseDelay is the backoff before the first retry; it doubles on each later retry.
+	retryBaseDelay time.Duration
+	// progress, when set, receives the number of bytes downloaded and the total size (-1 when
+	// the server omits Content-Length).
+	progress func(downloaded, total int64)
+	// logger receives download and cache diagnostics; nil disables logging.
+	logger *slog.Logger
+	// verifyRuns runs `codex --version` on a freshly downloaded binary and discards the
+	// download when it fails.
+	verifyRuns bool
+}
+
+func (cfg bundleConfig) downloadClient() *http.Client {
+	if cfg.httpClient != nil {
+		return cfg.httpClient
+	}
+	return &http.Client{Timeout: 2 * time.Minute}
+}
+
+func (cfg bundleConfig) assetURL(release, assetName string) (string, error) {
+	if cfg.assetURLFunc != nil {
+		if url := cfg.assetURLFunc(release, assetName); url != "" {
+			return url, nil
+		}
+	}
+	base, err := cfg.downloadBaseURL()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/%s", base, release, assetName), nil
+}
+
+// downloadBaseURL returns the URL under which release assets are laid out as
+// <base>/<release>/<asset>, validating any configured override.
+func (cfg bundleConfig) downloadBaseURL() (string, error) {
+	value := strings.TrimSpace(cfg.baseURL)
+	if value == "" {
+		value = strings.TrimSpace(os.Getenv("GODEX_CLI_BASE_URL"))
+	}
+	if value == "" {
+		return defaultDownloadBaseURL, nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid CLI download base URL %q: %w", value, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid CLI download base URL %q: expected an absolute http(s) URL", value)
+	}
+	return strings.TrimRight(value, "/"), nil
 }
 
-func (cfg bundleConfig) cacheDirPath() (string, error) {
+// cacheDirCandidates lists the cache directories in order of preference: the configured
+// directory, $GODEX_CLI_CACHE, the user cache directory, the temp directory and, as a last
+// resort, .godex-cache in the working directory.
+func (cfg bundleConfig) cacheDirCandidates() []string {
+	var candidates []string
 	if dir := strings.TrimSpace(cfg.cacheDir); dir != "" {
-		return dir, nil
+		candidates = append(candidates, dir)
 	}
 	if override := strings.TrimSpace(os.Getenv("GODEX_CLI_CACHE")); override != "" {
-		return override, nil
+		candidates = append(candidates, override)
 	}
 	if dir, err := os.UserCacheDir(); err == nil && dir != "" {
-		return filepath.Join(dir, "godex", "codex"), nil
+		candidates = append(candidates, filepath.Join(dir, "godex", "codex"))
+	}
+	candidates = append(candidates, filepath.Join(os.TempDir(), "godex", "codex"))
+	if wd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, filepath.Join(wd, ".godex-cache", "codex"))
+	}
+	return candidates
+}
+
+// writableCacheDir returns the first cache directory candidate that can be created and
+// written to, so read-only home directories fall back early instead of failing mid-download.
+// Everything that reads the cache resolves it the same way, so it sees what downloads wrote.
+func (cfg bundleConfig) writableCacheDir(ctx context.Context) (string, error) {
+	var errs []error
+	for _, dir := range cfg.cacheDirCandidates() {
+		err := probeWritableDir(dir)
+		if err == nil {
+			if len(errs) > 0 {
+				cfg.log(ctx, slog.LevelWarn, "falling back to writable codex cache directory", "dir", dir, "error", errors.Join(errs...))
+			}
+			return dir, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", dir, err))
+	}
+	return "", fmt.Errorf("no writable CLI cache directory found: %w", errors.Join(errs...))
+}
+
+// probeWritableDir creates dir if needed and checks that a file can be written inside it.
+func probeWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
 	}
-	return filepath.Join(os.TempDir(), "godex", "codex"), nil
+	probe, err := os.CreateTemp(dir, ".write-probe-*")
+	if err != nil {
+		return err
+	}
+	name := probe.Name()
+	_ = probe.Close()
+	return os.Remove(name)
 }
 
 func (cfg bundleConfig) releaseTagName() string {
@@ -99,10 +223,11 @@ func (cfg bundleConfig) checksumRequired() bool {
 }
 
 func (cfg bundleConfig) requireBundledBinary() bool {
-	return cfg.releasePinned() || cfg.checksumRequired()
+	return cfg.releasePinned() || cfg.checksumRequired() || strings.TrimSpace(cfg.signaturePublicKey) != ""
 }
 
 var downloadBinaryFunc = downloadBinaryFromRelease
+var copyBinary = io.Copy
 var runtimeGOOS = runtime.GOOS
 var runtimeGOARCH = runtime.GOARCH
 
@@ -114,6 +239,48 @@ type targetInfo struct {
 	exeName    string
 }
 
+// Platform identifies a GOOS/GOARCH pair for which a Codex CLI build can be downloaded.
+type Platform struct {
+	GOOS   string
+	GOARCH string
+	// Triple is the Rust target triple used to name the release asset.
+	Triple string
+}
+
+// knownPlatforms lists the platforms with an upstream release asset. detectTarget also maps
+// FreeBSD, whose assets upstream does not publish, so self-hosted builds can be downloaded
+// through a mirror; it is deliberately not reported as supported.
+var knownPlatforms = []struct{ goos, goarch string }{
+	{"linux", "amd64"},
+	{"linux", "arm64"},
+	{"darwin", "amd64"},
+	{"darwin", "arm64"},
+	{"windows", "amd64"},
+	{"windows", "arm64"},
+}
+
+// SupportedPlatforms lists every platform with a downloadable Codex CLI build.
+func SupportedPlatforms() []Platform {
+	platforms := make([]Platform, 0, len(knownPlatforms))
+	for _, p := range knownPlatforms {
+		if info, ok := detectTarget(p.goos, p.goarch); ok {
+			platforms = append(platforms, Platform{GOOS: p.goos, GOARCH: p.goarch, Triple: info.triple})
+		}
+	}
+	return platforms
+}
+
+// IsPlatformSupported reports whether a Codex CLI build exists for goos/goarch.
+func IsPlatformSupported(goos, goarch string) bool {
+	for _, p := range knownPlatforms {
+		if p.goos == goos && p.goarch == goarch {
+			_, ok := detectTarget(goos, goarch)
+			return ok
+		}
+	}
+	return false
+}
+
 func detectTarget(goos, goarch string) (targetInfo, bool) {
 	switch goos {
 	case "linux":
@@ -173,48 +340,81 @@ func detectTarget(goos, goarch string) (targetInfo, bool) {
 				exeName:    "codex.exe",
 			}, true
 		}
+	case "freebsd":
+		switch goarch {
+		case "amd64":
+			return targetInfo{
+				triple:     "x86_64-unknown-freebsd",
+				assetName:  "codex-x86_64-unknown-freebsd.tar.gz",
+				archive:    archiveTarGz,
+				binaryName: "codex-x86_64-unknown-freebsd",
+				exeName:    "codex",
+			}, true
+		case "arm64":
+			return targetInfo{
+				triple:     "aarch64-unknown-freebsd",
+				assetName:  "codex-aarch64-unknown-freebsd.tar.gz",
+				archive:    archiveTarGz,
+				binaryName: "codex-aarch64-unknown-freebsd",
+				exeName:    "codex",
+			}, true
+		}
 	}
 	return targetInfo{}, false
 }
 
-func ensureBundledBinary(cfg bundleConfig) (string, error) {
+func ensureBundledBinary(ctx context.Context, cfg bundleConfig) (string, error) {
 	info, ok := detectTarget(runtimeGOOS, runtimeGOARCH)
 	if !ok {
 		return "", fmt.Errorf("unsupported platform: %s/%s", runtimeGOOS, runtimeGOARCH)
 	}
 
-	cacheDir, err := cfg.cacheDirPath()
+	cacheDir, err := cfg.writableCacheDir(ctx)
 	if err != nil {
 		return "", err
 	}
 
-	release := cfg.releaseTagName()
+	release := resolveReleaseTag(ctx, cfg, cacheDir, info)
 	checksumHex, err := cfg.checksumValue()
 	if err != nil {
 		return "", fmt.Errorf("resolve checksum: %w", err)
 	}
+	signatureKey, err := cfg.signatureKey()
+	if err != nil {
+		return "", err
+	}
 	targetDir := filepath.Join(cacheDir, release, info.triple)
 	if err := os.MkdirAll(targetDir, 0o755); err != nil {
-		return "", fmt.Errorf("create bundle directory: %w", err)
+		return "", CheckDiskFull(targetDir, fmt.Errorf("create bundle directory: %w", err))
 	}
 
 	destPath := filepath.Join(targetDir, info.exeName)
-	if statErr := ensureBinaryState(destPath); statErr == nil {
-		if checksumHex == "" {
-			return destPath, nil
+	// A forced download skips the cache entirely; writeBinary atomically replaces the file.
+	if !cfg.forceDownload {
+		if cached, err := usableCachedBinary(destPath, checksumHex, signatureKey); err != nil || cached {
+			if cached {
+				cfg.log(ctx, slog.LevelDebug, "using cached codex binary", "path", destPath)
+			}
+			return destPath, err
 		}
-		if err := verifyChecksum(destPath, checksumHex); err == nil {
-			return destPath, nil
-		} else if errors.Is(err, ErrChecksumMismatch) {
-			_ = os.Remove(destPath)
-		} else {
-			return "", fmt.Errorf("verify cached binary: %w", err)
+	}
+
+	// Only one downloader runs per release/triple; others wait and reuse its result.
+	unlock, err := acquireDownloadLock(ctx, destPath+".lock")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if !cfg.forceDownload {
+		if cached, err := usableCachedBinary(destPath, checksumHex, signatureKey); err != nil || cached {
+			return destPath, err
 		}
-	} else if !errors.Is(statErr, os.ErrNotExist) {
-		return "", fmt.Errorf("stat bundled binary: %w", statErr)
 	}
 
-	if err := downloadBinaryFunc(info, release, destPath); err != nil {
+	cfg.log(ctx, slog.LevelInfo, "downloading codex CLI", "release", release, "asset", info.assetName)
+	if err := downloadWithRetry(ctx, cfg, info, release, destPath); err != nil {
+		cfg.log(ctx, slog.LevelError, "codex CLI download failed", "release", release, "error", err)
 		return "", err
 	}
 	if checksumHex != "" {
@@ -222,40 +422,231 @@ func ensureBundledBinary(cfg bundleConfig) (string, error) {
 			_ = os.Remove(destPath)
 			return "", fmt.Errorf("verify downloaded binary: %w", err)
 		}
+		cfg.log(ctx, slog.LevelDebug, "verified codex binary checksum", "path", destPath)
+	}
+	if cfg.verifyRuns {
+		if err := checkBinaryRuns(ctx, destPath); err != nil {
+			_ = os.Remove(destPath)
+			_ = os.Remove(destPath + signatureRecordSuffix)
+			cfg.log(ctx, slog.LevelError, "downloaded codex binary does not run", "path", destPath, "error", err)
+			return "", err
+		}
 	}
+	cfg.log(ctx, slog.LevelInfo, "downloaded codex CLI", "release", release, "path", destPath)
 	return destPath, nil
 }
 
+// checkBinaryRuns runs `<path> --version` and reports ErrBinaryNotRunnable when it fails.
+func checkBinaryRuns(ctx context.Context, path string) error {
+	output, err := commandFactory(ctx, path, "--version").CombinedOutput()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if detail := strings.TrimSpace(string(output)); detail != "" {
+			return fmt.Errorf("%w: %s --version: %v: %s", ErrBinaryNotRunnable, path, err, detail)
+		}
+		return fmt.Errorf("%w: %s --version: %v", ErrBinaryNotRunnable, path, err)
+	}
+	return nil
+}
+
+// downloadWithRetry calls downloadBinaryFunc, retrying transient failures (timeouts, dropped
+// connections and 5xx responses) with exponential backoff up to cfg.downloadAttempts times.
+func downloadWithRetry(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+	attempts := cfg.downloadAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := cfg.retryBaseDelay
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = downloadBinaryFunc(ctx, cfg, info, release, destPath)
+		if err == nil || attempt >= attempts || ctx.Err() != nil || !isTransientDownloadError(err) {
+			return err
+		}
+		cfg.log(ctx, slog.LevelDebug, "retrying codex CLI download", "attempt", attempt, "delay", delay, "error", err)
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			delay *= 2
+		}
+	}
+}
+
+// downloadStatusError reports a non-200 response from the release server.
+type downloadStatusError struct {
+	StatusCode int
+}
+
+func (e *downloadStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
+}
+
+func isTransientDownloadError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var statusErr *downloadStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	// *url.Error implements net.Error for every client failure, including TLS and DNS errors
+	// that will not heal on retry, so only timeouts and dropped connections count.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED)
+}
+
+// usableCachedBinary reports whether destPath holds a cached binary matching checksumHex and,
+// when signatureKey is set, a signature record proving it came from a verified asset. A
+// cached binary with a mismatching checksum is removed so it can be downloaded again; one
+// without a valid signature record is left for the download to replace.
+func usableCachedBinary(destPath, checksumHex string, signatureKey ed25519.PublicKey) (bool, error) {
+	statErr := ensureBinaryState(destPath)
+	if errors.Is(statErr, os.ErrNotExist) {
+		return false, nil
+	}
+	if statErr != nil {
+		return false, fmt.Errorf("stat bundled binary: %w", statErr)
+	}
+	if signatureKey != nil {
+		verified, err := hasSignatureRecord(destPath, signatureKey)
+		if err != nil || !verified {
+			return false, err
+		}
+	}
+	if checksumHex == "" {
+		return true, nil
+	}
+	err := verifyChecksum(destPath, checksumHex)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrChecksumMismatch):
+		_ = os.Remove(destPath)
+		return false, nil
+	default:
+		return false, fmt.Errorf("verify cached binary: %w", err)
+	}
+}
+
 func ensureBinaryState(path string) error {
 	_, err := os.Stat(path)
 	return err
 }
 
-func downloadBinaryFromRelease(info targetInfo, release, destPath string) error {
-	url := fmt.Sprintf("https://github.com/openai/codex/releases/download/%s/%s", release, info.assetName)
+func downloadBinaryFromRelease(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+	signatureKey, err := cfg.signatureKey()
+	if err != nil {
+		return err
+	}
+	url, err := cfg.assetURL(release, info.assetName)
+	if err != nil {
+		return err
+	}
 
-	client := &http.Client{Timeout: 2 * time.Minute}
-	resp, err := client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create download request: %w", err)
+	}
+	resp, err := cfg.downloadClient().Do(req)
 	if err != nil {
 		return fmt.Errorf("download codex binary: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download codex binary: unexpected status %d", resp.StatusCode)
+		return fmt.Errorf("download codex asset %s from %s: %w", info.assetName, url, &downloadStatusError{StatusCode: resp.StatusCode})
+	}
+
+	var body io.Reader = resp.Body
+	var progress *progressReader
+	if cfg.progress != nil {
+		progress = &progressReader{r: resp.Body, total: resp.ContentLength, report: cfg.progress}
+		body = progress
+	}
+
+	// A signed asset is buffered so it can be verified before anything is extracted.
+	var archiveDigest string
+	if signatureKey != nil {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("read release asset: %w", err)
+		}
+		if err := verifySignature(ctx, cfg, signatureKey, info, release, data); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		archiveDigest = hex.EncodeToString(sum[:])
+		body = bytes.NewReader(data)
 	}
 
 	switch info.archive {
 	case archiveTarGz:
-		return extractTarGzBinary(resp.Body, info, destPath)
+		if err := extractTarGzBinary(body, info, destPath); err != nil {
+			return err
+		}
 	case archiveZip:
-		data, err := io.ReadAll(resp.Body)
+		data, err := io.ReadAll(body)
 		if err != nil {
 			return fmt.Errorf("read zip archive: %w", err)
 		}
-		return extractZipBinary(data, info, destPath)
+		if err := extractZipBinary(data, info, destPath); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unsupported archive for %s", info.assetName)
 	}
+	if progress != nil {
+		progress.finish()
+	}
+	if signatureKey != nil {
+		return writeSignatureRecord(destPath, signatureKey, archiveDigest)
+	}
+	return nil
+}
+
+// progressReportInterval is the minimum number of bytes between progress reports.
+const progressReportInterval = 256 * 1024
+
+// progressReader reports download progress every progressReportInterval bytes and at EOF.
+type progressReader struct {
+	r        io.Reader
+	total    int64
+	read     int64
+	reported int64
+	report   func(downloaded, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.read-p.reported >= progressReportInterval || (err == io.EOF && p.read != p.reported) {
+		p.reported = p.read
+		p.report(p.read, p.total)
+	}
+	return n, err
+}
+
+// finish drains any trailing archive bytes the extractor did not consume so the final report
+// covers the whole download.
+func (p *progressReader) finish() {
+	_, _ = io.Copy(io.Discard, p)
+	if p.read != p.reported {
+		p.reported = p.read
+		p.report(p.read, p.total)
+	}
 }
 
 func extractTarGzBinary(r io.Reader, info targetInfo, destPath string) error {
@@ -265,6 +656,11 @@ func extractTarGzBinary(r io.Reader, info targetInfo, destPath string) error {
 	}
 	defer gz.Close()
 
+	// Entries that do not match by name are staged next to destPath when executable so that
+	// an archive containing exactly one executable can still be extracted.
+	staging := destPath + ".fallback"
+	defer os.Remove(staging)
+	var executables int
 	tr := tar.NewReader(gz)
 	for {
 		header, err := tr.Next()
@@ -277,10 +673,28 @@ func extractTarGzBinary(r io.Reader, info targetInfo, destPath string) error {
 		if header.Typeflag != tar.TypeReg {
 			continue
 		}
-		if filepath.Base(header.Name) != info.binaryName {
+		if matchesBinaryName(header.Name, info) {
+			return writeBinary(tr, destPath)
+		}
+		if header.FileInfo().Mode().Perm()&0o111 == 0 {
 			continue
 		}
-		return writeBinary(tr, destPath)
+		executables++
+		if executables == 1 {
+			limited := &io.LimitedReader{R: tr, N: maxExtractedBinaryBytes + 1}
+			if err := writeBinary(limited, staging); err != nil {
+				return fmt.Errorf("stage tar entry: %w", err)
+			}
+			if limited.N == 0 {
+				return fmt.Errorf("tar entry %s exceeds %d bytes", header.Name, maxExtractedBinaryBytes)
+			}
+		}
+	}
+	if executables == 1 {
+		if err := os.Rename(staging, destPath); err != nil {
+			return fmt.Errorf("rename binary: %w", err)
+		}
+		return nil
 	}
 	return fmt.Errorf("binary %s not found in archive", info.binaryName)
 }
@@ -291,43 +705,71 @@ func extractZipBinary(data []byte, info targetInfo, destPath string) error {
 	if err != nil {
 		return fmt.Errorf("open zip: %w", err)
 	}
+
+	var executables []*zip.File
 	for _, file := range zr.File {
-		if filepath.Base(file.Name) != info.binaryName {
+		if !file.Mode().IsRegular() {
 			continue
 		}
-		rc, err := file.Open()
-		if err != nil {
-			return fmt.Errorf("open zip entry: %w", err)
+		if matchesBinaryName(file.Name, info) {
+			return extractZipEntry(file, destPath)
 		}
-		err = writeBinary(rc, destPath)
-		rc.Close()
-		return err
+		if file.Mode().Perm()&0o111 != 0 || strings.EqualFold(path.Ext(file.Name), ".exe") {
+			executables = append(executables, file)
+		}
+	}
+	if len(executables) == 1 {
+		return extractZipEntry(executables[0], destPath)
 	}
 	return fmt.Errorf("binary %s not found in archive", info.binaryName)
 }
 
+func extractZipEntry(file *zip.File, destPath string) error {
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("open zip entry: %w", err)
+	}
+	defer rc.Close()
+	return writeBinary(rc, destPath)
+}
+
+// matchesBinaryName reports whether an archive entry, possibly nested under directories, is
+// the Codex binary for the target.
+func matchesBinaryName(name string, info targetInfo) bool {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	return base == info.binaryName || base == info.exeName
+}
+
 func verifyChecksum(path, expectedHex string) error {
+	actual, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if actual != expectedHex {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expectedHex, actual)
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex encoded SHA-256 digest of the file at path.
+func fileSHA256(path string) (string, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("open binary for checksum: %w", err)
+		return "", fmt.Errorf("open binary for checksum: %w", err)
 	}
 	defer file.Close()
 
 	hasher := sha256.New()
 	if _, err := io.Copy(hasher, file); err != nil {
-		return fmt.Errorf("hash binary: %w", err)
+		return "", fmt.Errorf("hash binary: %w", err)
 	}
-	actual := hex.EncodeToString(hasher.Sum(nil))
-	if actual != expectedHex {
-		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expectedHex, actual)
-	}
-	return nil
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
 func writeBinary(r io.Reader, destPath string) error {
 	tmpFile, err := os.CreateTemp(filepath.Dir(destPath), filepath.Base(destPath)+".tmp-*")
 	if err != nil {
-		return fmt.Errorf("create temp binary: %w", err)
+		return CheckDiskFull(destPath, fmt.Errorf("create temp binary: %w", err))
 	}
 	if err := tmpFile.Chmod(0o700); err != nil {
 		tmpFile.Close()
@@ -341,11 +783,11 @@ func writeBinary(r io.Reader, destPath string) error {
 		_ = os.Remove(tmpPath)
 	}()
 
-	if _, err := io.Copy(f, r); err != nil {
-		return fmt.Errorf("write binary: %w", err)
+	if _, err := copyBinary(f, r); err != nil {
+		return CheckDiskFull(destPath, fmt.Errorf("write binary: %w", err))
 	}
 	if err := f.Close(); err != nil {
-		return fmt.Errorf("close binary: %w", err)
+		return CheckDiskFull(destPath, fmt.Errorf("close binary: %w", err))
 	}
 
 	if err := os.Rename(tmpPath, destPath); err != nil {
@@ -1,15 +1,33 @@
 package codexexec
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestDetectTargetSupportsKnownCombinations(t *testing.T) {
@@ -24,6 +42,8 @@ func TestDetectTargetSupportsKnownCombinations(t *testing.T) {
 		{"darwin", "arm64", "aarch64-apple-darwin"},
 		{"windows", "amd64", "x86_64-pc-windows-msvc"},
 		{"windows", "arm64", "aarch64-pc-windows-msvc"},
+		{"freebsd", "amd64", "x86_64-unknown-freebsd"},
+		{"freebsd", "arm64", "aarch64-unknown-freebsd"},
 	}
 
 	for _, tc := range cases {
@@ -54,7 +74,7 @@ func TestEnsureBundledBinaryDownloadsWhenMissing(t *testing.T) {
 
 	var called bool
 	originalDownloader := downloadBinaryFunc
-	downloadBinaryFunc = func(info targetInfo, release, destPath string) error {
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
 		called = true
 		if err := os.WriteFile(destPath, []byte("binary"), 0o700); err != nil {
 			return err
@@ -63,7 +83,7 @@ func TestEnsureBundledBinaryDownloadsWhenMissing(t *testing.T) {
 	}
 	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
 
-	path, err := ensureBundledBinary(cfg)
+	path, err := ensureBundledBinary(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("ensureBundledBinary returned error: %v", err)
 	}
@@ -97,13 +117,13 @@ func TestEnsureBundledBinarySkipsDownloadWhenPresent(t *testing.T) {
 	}
 
 	originalDownloader := downloadBinaryFunc
-	downloadBinaryFunc = func(info targetInfo, release, destPath string) error {
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
 		t.Fatalf("downloader should not be called when binary exists")
 		return nil
 	}
 	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
 
-	path, err := ensureBundledBinary(cfg)
+	path, err := ensureBundledBinary(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("ensureBundledBinary returned error: %v", err)
 	}
@@ -118,12 +138,38 @@ func TestBundleCacheDirPrefersOptionOverEnv(t *testing.T) {
 	explicit := filepath.Join(t.TempDir(), "explicit-cache")
 	cfg := bundleConfig{cacheDir: explicit}
 
-	got, err := cfg.cacheDirPath()
+	got, err := cfg.writableCacheDir(context.Background())
 	if err != nil {
-		t.Fatalf("cacheDirPath returned error: %v", err)
+		t.Fatalf("writableCacheDir returned error: %v", err)
 	}
 	if got != explicit {
-		t.Fatalf("cacheDirPath=%s, want %s", got, explicit)
+		t.Fatalf("writableCacheDir=%s, want %s", got, explicit)
+	}
+}
+
+func TestWritableCacheDirFallsBackFromUnwritablePrimary(t *testing.T) {
+	// A directory below a regular file can never be created, even when running as root.
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, []byte("x"), 0o600); err != nil {
+		t.Fatalf("write blocker: %v", err)
+	}
+	fallback := filepath.Join(t.TempDir(), "env-cache")
+	t.Setenv("GODEX_CLI_CACHE", fallback)
+
+	cfg := bundleConfig{cacheDir: filepath.Join(blocker, "cache")}
+	got, err := cfg.writableCacheDir(context.Background())
+	if err != nil {
+		t.Fatalf("writableCacheDir returned error: %v", err)
+	}
+	if got != fallback {
+		t.Fatalf("writableCacheDir=%s, want %s", got, fallback)
+	}
+	entries, err := os.ReadDir(fallback)
+	if err != nil {
+		t.Fatalf("read fallback dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the write probe to be cleaned up, found %d entries", len(entries))
 	}
 }
 
@@ -140,13 +186,13 @@ func TestEnsureBundledBinaryUsesProvidedReleaseTag(t *testing.T) {
 
 	var releaseUsed string
 	originalDownloader := downloadBinaryFunc
-	downloadBinaryFunc = func(info targetInfo, release, destPath string) error {
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
 		releaseUsed = release
 		return os.WriteFile(destPath, []byte("binary"), 0o700)
 	}
 	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
 
-	if _, err := ensureBundledBinary(cfg); err != nil {
+	if _, err := ensureBundledBinary(context.Background(), cfg); err != nil {
 		t.Fatalf("ensureBundledBinary returned error: %v", err)
 	}
 	if releaseUsed != "custom-release" {
@@ -168,12 +214,12 @@ func TestEnsureBundledBinaryVerifiesChecksums(t *testing.T) {
 	})
 
 	originalDownloader := downloadBinaryFunc
-	downloadBinaryFunc = func(info targetInfo, release, destPath string) error {
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
 		return os.WriteFile(destPath, []byte("binary"), 0o700)
 	}
 	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
 
-	if _, err := ensureBundledBinary(cfg); err != nil {
+	if _, err := ensureBundledBinary(context.Background(), cfg); err != nil {
 		t.Fatalf("ensureBundledBinary returned error: %v", err)
 	}
 }
@@ -192,16 +238,55 @@ func TestEnsureBundledBinaryFailsOnChecksumMismatch(t *testing.T) {
 	})
 
 	originalDownloader := downloadBinaryFunc
-	downloadBinaryFunc = func(info targetInfo, release, destPath string) error {
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
 		return os.WriteFile(destPath, []byte("binary"), 0o700)
 	}
 	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
 
-	if _, err := ensureBundledBinary(cfg); err == nil || !errors.Is(err, ErrChecksumMismatch) {
+	if _, err := ensureBundledBinary(context.Background(), cfg); err == nil || !errors.Is(err, ErrChecksumMismatch) {
 		t.Fatalf("expected checksum mismatch error, got %v", err)
 	}
 }
 
+func TestEnsureBundledBinaryDiscardsDownloadThatDoesNotRun(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := bundleConfig{cacheDir: tmp, verifyRuns: true}
+
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		return os.WriteFile(destPath, []byte("binary"), 0o700)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+	useHelperProcess(t, "exit-code")
+
+	_, err := ensureBundledBinary(context.Background(), cfg)
+	if !errors.Is(err, ErrBinaryNotRunnable) {
+		t.Fatalf("expected ErrBinaryNotRunnable, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "authentication failed") {
+		t.Fatalf("expected the binary's output in the error, got %v", err)
+	}
+	destPath := filepath.Join(tmp, defaultCodexReleaseTag, "x86_64-unknown-linux-musl", "codex")
+	if _, statErr := os.Stat(destPath); !errors.Is(statErr, os.ErrNotExist) {
+		t.Fatalf("expected the unusable download to be removed, got %v", statErr)
+	}
+
+	useHelperProcess(t, "")
+	path, err := ensureBundledBinary(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("ensureBundledBinary returned error for a runnable binary: %v", err)
+	}
+	if path != destPath {
+		t.Fatalf("expected %s, got %s", destPath, path)
+	}
+}
+
 func TestEnsureBundledBinaryRedownloadsWhenCachedChecksumMismatch(t *testing.T) {
 	tmp := t.TempDir()
 	cfg := bundleConfig{
@@ -228,13 +313,13 @@ func TestEnsureBundledBinaryRedownloadsWhenCachedChecksumMismatch(t *testing.T)
 
 	var downloads int
 	originalDownloader := downloadBinaryFunc
-	downloadBinaryFunc = func(info targetInfo, release, destPath string) error {
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
 		downloads++
 		return os.WriteFile(destPath, []byte("new"), 0o700)
 	}
 	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
 
-	path, err := ensureBundledBinary(cfg)
+	path, err := ensureBundledBinary(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("ensureBundledBinary returned error: %v", err)
 	}
@@ -261,7 +346,7 @@ func TestFindCodexPathFallsBackToSystemBinary(t *testing.T) {
 	})
 
 	originalDownloader := downloadBinaryFunc
-	downloadBinaryFunc = func(info targetInfo, release, destPath string) error {
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
 		return fmt.Errorf("simulated download failure")
 	}
 	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
@@ -278,10 +363,13 @@ func TestFindCodexPathFallsBackToSystemBinary(t *testing.T) {
 	originalPath := os.Getenv("PATH")
 	t.Setenv("PATH", tempBinDir+string(os.PathListSeparator)+originalPath)
 
-	path, err := findCodexPath(bundleConfig{})
+	path, bundled, err := findCodexPath(context.Background(), bundleConfig{})
 	if err != nil {
 		t.Fatalf("findCodexPath returned error: %v", err)
 	}
+	if bundled {
+		t.Fatal("expected a PATH fallback not to be reported as bundled")
+	}
 	if !strings.HasPrefix(path, tempBinDir) {
 		t.Fatalf("expected fallback path within %s, got %s", tempBinDir, path)
 	}
@@ -298,7 +386,7 @@ func TestFindCodexPathReturnsErrorWhenChecksumConfigured(t *testing.T) {
 	})
 
 	originalDownloader := downloadBinaryFunc
-	downloadBinaryFunc = func(info targetInfo, release, destPath string) error {
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
 		return os.WriteFile(destPath, []byte("binary"), 0o700)
 	}
 	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
@@ -314,7 +402,7 @@ func TestFindCodexPathReturnsErrorWhenChecksumConfigured(t *testing.T) {
 	originalPath := os.Getenv("PATH")
 	t.Setenv("PATH", tempBinDir+string(os.PathListSeparator)+originalPath)
 
-	_, err := findCodexPath(cfg)
+	_, _, err := findCodexPath(context.Background(), cfg)
 	if err == nil {
 		t.Fatalf("expected checksum error")
 	}
@@ -334,7 +422,7 @@ func TestFindCodexPathReturnsErrorWhenReleasePinned(t *testing.T) {
 	})
 
 	originalDownloader := downloadBinaryFunc
-	downloadBinaryFunc = func(info targetInfo, release, destPath string) error {
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
 		return fmt.Errorf("simulated download failure")
 	}
 	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
@@ -350,7 +438,7 @@ func TestFindCodexPathReturnsErrorWhenReleasePinned(t *testing.T) {
 	originalPath := os.Getenv("PATH")
 	t.Setenv("PATH", tempBinDir+string(os.PathListSeparator)+originalPath)
 
-	_, err := findCodexPath(cfg)
+	_, _, err := findCodexPath(context.Background(), cfg)
 	if err == nil {
 		t.Fatalf("expected error due to pinned release")
 	}
@@ -363,3 +451,637 @@ func sha256Hex(data []byte) string {
 	sum := sha256.Sum256(data)
 	return hex.EncodeToString(sum[:])
 }
+
+func TestEnsureBundledBinaryReportsDiskFull(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := bundleConfig{cacheDir: tmp}
+
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		return writeBinary(strings.NewReader("binary"), destPath)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	originalCopy := copyBinary
+	copyBinary = func(dst io.Writer, src io.Reader) (int64, error) {
+		return 0, &os.PathError{Op: "write", Path: "codex.tmp", Err: syscall.ENOSPC}
+	}
+	t.Cleanup(func() { copyBinary = originalCopy })
+
+	_, err := ensureBundledBinary(context.Background(), cfg)
+	if !errors.Is(err, ErrDiskFull) {
+		t.Fatalf("expected ErrDiskFull, got %v", err)
+	}
+	var diskErr *DiskFullError
+	if !errors.As(err, &diskErr) {
+		t.Fatalf("expected DiskFullError, got %T", err)
+	}
+	info, _ := detectTarget(runtimeGOOS, runtimeGOARCH)
+	want := filepath.Join(tmp, cfg.releaseTagName(), info.triple, info.exeName)
+	if diskErr.Path != want {
+		t.Fatalf("expected path %s, got %s", want, diskErr.Path)
+	}
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("expected error to unwrap to ENOSPC, got %v", err)
+	}
+}
+
+func TestDownloadBinaryFromReleaseUsesAssetURLFunc(t *testing.T) {
+	info, _ := detectTarget("linux", "amd64")
+	archive := tarGzArchive(t, archiveEntry{name: info.binaryName, contents: "mirrored", mode: 0o755})
+
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	cfg := bundleConfig{
+		assetURLFunc: func(release, assetName string) string {
+			return server.URL + "/flat/" + release + "-" + assetName
+		},
+	}
+	destPath := filepath.Join(t.TempDir(), info.exeName)
+	if err := downloadBinaryFromRelease(context.Background(), cfg, info, "rust-v1", destPath); err != nil {
+		t.Fatalf("downloadBinaryFromRelease returned error: %v", err)
+	}
+
+	if want := "/flat/rust-v1-" + info.assetName; requested != want {
+		t.Fatalf("expected request path %s, got %s", want, requested)
+	}
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("read binary: %v", err)
+	}
+	if string(data) != "mirrored" {
+		t.Fatalf("unexpected binary contents %q", data)
+	}
+}
+
+type rerouteTransport struct {
+	target   *url.URL
+	requests int
+}
+
+func (rt *rerouteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests++
+	rerouted := req.Clone(req.Context())
+	rerouted.URL.Scheme = rt.target.Scheme
+	rerouted.URL.Host = rt.target.Host
+	return http.DefaultTransport.RoundTrip(rerouted)
+}
+
+func TestDownloadBinaryFromReleaseUsesConfiguredHTTPClient(t *testing.T) {
+	info, _ := detectTarget("linux", "amd64")
+	archive := tarGzArchive(t, archiveEntry{name: info.binaryName, contents: "proxied", mode: 0o755})
+
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parse server url: %v", err)
+	}
+	transport := &rerouteTransport{target: target}
+	cfg := bundleConfig{httpClient: &http.Client{Transport: transport}}
+
+	destPath := filepath.Join(t.TempDir(), info.exeName)
+	if err := downloadBinaryFromRelease(context.Background(), cfg, info, "rust-v1", destPath); err != nil {
+		t.Fatalf("downloadBinaryFromRelease returned error: %v", err)
+	}
+
+	if transport.requests != 1 {
+		t.Fatalf("expected download through the configured client, got %d requests", transport.requests)
+	}
+	if want := "/openai/codex/releases/download/rust-v1/" + info.assetName; requested != want {
+		t.Fatalf("expected request path %s, got %s", want, requested)
+	}
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("read binary: %v", err)
+	}
+	if string(data) != "proxied" {
+		t.Fatalf("unexpected binary contents %q", data)
+	}
+}
+
+func TestDownloadBinaryFromReleaseReportsProgress(t *testing.T) {
+	info, _ := detectTarget("linux", "amd64")
+	payload := make([]byte, 3*progressReportInterval)
+	if _, err := rand.Read(payload); err != nil {
+		t.Fatalf("generate payload: %v", err)
+	}
+	archive := tarGzArchive(t, archiveEntry{name: info.binaryName, contents: string(payload), mode: 0o755})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	type report struct{ downloaded, total int64 }
+	var reports []report
+	cfg := bundleConfig{
+		assetURLFunc: func(release, assetName string) string { return server.URL + "/" + assetName },
+		progress: func(downloaded, total int64) {
+			reports = append(reports, report{downloaded, total})
+		},
+	}
+	destPath := filepath.Join(t.TempDir(), info.exeName)
+	if err := downloadBinaryFromRelease(context.Background(), cfg, info, "rust-v1", destPath); err != nil {
+		t.Fatalf("downloadBinaryFromRelease returned error: %v", err)
+	}
+
+	if len(reports) < 2 {
+		t.Fatalf("expected periodic progress reports, got %v", reports)
+	}
+	size := int64(len(archive))
+	if last := reports[len(reports)-1]; last.downloaded != size || last.total != size {
+		t.Fatalf("expected final report %d/%d, got %d/%d", size, size, last.downloaded, last.total)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].downloaded <= reports[i-1].downloaded {
+			t.Fatalf("expected increasing progress, got %v", reports)
+		}
+	}
+	if int64(len(reports)) > size/progressReportInterval+1 {
+		t.Fatalf("expected reports at intervals, got %d reports for %d bytes", len(reports), size)
+	}
+}
+
+func TestExtractTarGzBinaryFindsNestedBinary(t *testing.T) {
+	info, _ := detectTarget("linux", "amd64")
+
+	cases := map[string][]archiveEntry{
+		"nested binary name": {
+			{name: info.binaryName + "/README.md", contents: "docs", mode: 0o644},
+			{name: info.binaryName + "/" + info.binaryName, contents: "codex", mode: 0o755},
+		},
+		"nested exe name": {
+			{name: "dist/LICENSE", contents: "license", mode: 0o644},
+			{name: "dist/bin/" + info.exeName, contents: "codex", mode: 0o755},
+		},
+		"single executable": {
+			{name: "release/notes.txt", contents: "notes", mode: 0o644},
+			{name: "release/bin/codex-cli", contents: "codex", mode: 0o755},
+		},
+	}
+
+	for name, entries := range cases {
+		t.Run(name, func(t *testing.T) {
+			destPath := filepath.Join(t.TempDir(), info.exeName)
+			if err := extractTarGzBinary(bytes.NewReader(tarGzArchive(t, entries...)), info, destPath); err != nil {
+				t.Fatalf("extractTarGzBinary returned error: %v", err)
+			}
+			data, err := os.ReadFile(destPath)
+			if err != nil {
+				t.Fatalf("read binary: %v", err)
+			}
+			if string(data) != "codex" {
+				t.Fatalf("unexpected binary contents %q", data)
+			}
+		})
+	}
+}
+
+func TestExtractTarGzBinaryRejectsAmbiguousExecutables(t *testing.T) {
+	info, _ := detectTarget("linux", "amd64")
+	archive := tarGzArchive(t,
+		archiveEntry{name: "bin/one", contents: "one", mode: 0o755},
+		archiveEntry{name: "bin/two", contents: "two", mode: 0o755},
+	)
+
+	destPath := filepath.Join(t.TempDir(), info.exeName)
+	if err := extractTarGzBinary(bytes.NewReader(archive), info, destPath); err == nil {
+		t.Fatal("expected error when archive has multiple candidate executables")
+	}
+}
+
+func TestExtractTarGzBinaryBoundsFallbackEntry(t *testing.T) {
+	original := maxExtractedBinaryBytes
+	maxExtractedBinaryBytes = 16
+	t.Cleanup(func() { maxExtractedBinaryBytes = original })
+
+	info, _ := detectTarget("linux", "amd64")
+	archive := tarGzArchive(t, archiveEntry{name: "bin/codex-cli", contents: strings.Repeat("x", 64), mode: 0o755})
+
+	dir := t.TempDir()
+	destPath := filepath.Join(dir, info.exeName)
+	err := extractTarGzBinary(bytes.NewReader(archive), info, destPath)
+	if err == nil || !strings.Contains(err.Error(), "exceeds 16 bytes") {
+		t.Fatalf("expected the oversized entry to be rejected, got %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected nothing left behind, found %v", entries)
+	}
+}
+
+func TestExtractZipBinaryFindsNestedBinary(t *testing.T) {
+	info, _ := detectTarget("windows", "amd64")
+	archive := zipArchive(t,
+		archiveEntry{name: "codex/README.md", contents: "docs", mode: 0o644},
+		archiveEntry{name: "codex/bin/" + info.exeName, contents: "codex", mode: 0o644},
+	)
+
+	destPath := filepath.Join(t.TempDir(), info.exeName)
+	if err := extractZipBinary(archive, info, destPath); err != nil {
+		t.Fatalf("extractZipBinary returned error: %v", err)
+	}
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("read binary: %v", err)
+	}
+	if string(data) != "codex" {
+		t.Fatalf("unexpected binary contents %q", data)
+	}
+}
+
+func TestBundleAssetURLDefaultsToGitHub(t *testing.T) {
+	t.Setenv("GODEX_CLI_BASE_URL", "")
+	got, err := bundleConfig{}.assetURL("rust-v1", "codex.tar.gz")
+	if err != nil {
+		t.Fatalf("assetURL returned error: %v", err)
+	}
+	if want := "https://github.com/openai/codex/releases/download/rust-v1/codex.tar.gz"; got != want {
+		t.Fatalf("assetURL=%s, want %s", got, want)
+	}
+}
+
+func TestDownloadBinaryFromReleaseUsesBaseURL(t *testing.T) {
+	info, _ := detectTarget("linux", "amd64")
+	archive := tarGzArchive(t, archiveEntry{name: info.binaryName, contents: "mirror", mode: 0o755})
+
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+
+	cfg := bundleConfig{baseURL: server.URL + "/codex-mirror/"}
+	destPath := filepath.Join(t.TempDir(), info.exeName)
+	if err := downloadBinaryFromRelease(context.Background(), cfg, info, "rust-v1", destPath); err != nil {
+		t.Fatalf("downloadBinaryFromRelease returned error: %v", err)
+	}
+	if want := "/codex-mirror/rust-v1/" + info.assetName; requested != want {
+		t.Fatalf("expected request path %s, got %s", want, requested)
+	}
+}
+
+func TestBundleBaseURLFallsBackToEnvAndValidates(t *testing.T) {
+	t.Setenv("GODEX_CLI_BASE_URL", "https://mirror.example.com/codex")
+	got, err := bundleConfig{}.assetURL("rust-v1", "codex.tar.gz")
+	if err != nil {
+		t.Fatalf("assetURL returned error: %v", err)
+	}
+	if want := "https://mirror.example.com/codex/rust-v1/codex.tar.gz"; got != want {
+		t.Fatalf("assetURL=%s, want %s", got, want)
+	}
+
+	for _, invalid := range []string{"mirror.example.com", "ftp://mirror.example.com", "https://"} {
+		if _, err := (bundleConfig{baseURL: invalid}).assetURL("rust-v1", "codex.tar.gz"); err == nil {
+			t.Fatalf("expected error for base URL %q", invalid)
+		}
+	}
+	if _, err := New(RunnerOptions{DownloadBaseURL: "not a url"}); err == nil {
+		t.Fatal("expected New to reject an invalid base URL")
+	}
+}
+
+type archiveEntry struct {
+	name     string
+	contents string
+	mode     int64
+}
+
+func tarGzArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: entry.mode, Size: int64(len(entry.contents)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(entry.contents)); err != nil {
+			t.Fatalf("write tar entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		header.SetMode(os.FileMode(entry.mode))
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("create zip entry: %v", err)
+		}
+		if _, err := w.Write([]byte(entry.contents)); err != nil {
+			t.Fatalf("write zip entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestEnsureBundledBinaryForceDownloadReplacesCache(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := bundleConfig{
+		cacheDir:      tmp,
+		checksumHex:   sha256Hex([]byte("fresh")),
+		forceDownload: true,
+	}
+
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	info, _ := detectTarget(runtimeGOOS, runtimeGOARCH)
+	targetDir := filepath.Join(tmp, cfg.releaseTagName(), info.triple)
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	destPath := filepath.Join(targetDir, info.exeName)
+	// The cached binary matches the checksum, so only the force flag triggers a download.
+	if err := os.WriteFile(destPath, []byte("fresh"), 0o700); err != nil {
+		t.Fatalf("write cache: %v", err)
+	}
+
+	var downloads int
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		downloads++
+		return writeBinary(strings.NewReader("fresh"), destPath)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	path, err := ensureBundledBinary(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("ensureBundledBinary returned error: %v", err)
+	}
+	if downloads != 1 {
+		t.Fatalf("expected forced download, got %d downloads", downloads)
+	}
+	if path != destPath {
+		t.Fatalf("expected %s, got %s", destPath, path)
+	}
+
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		return writeBinary(strings.NewReader("corrupt"), destPath)
+	}
+	if _, err := ensureBundledBinary(context.Background(), cfg); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected checksum verification after forced download, got %v", err)
+	}
+}
+
+func TestEnsureBundledBinaryRetriesTransientFailures(t *testing.T) {
+	tmpCache := t.TempDir()
+
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	failures := []error{
+		fmt.Errorf("download codex binary: %w", &downloadStatusError{StatusCode: http.StatusBadGateway}),
+		fmt.Errorf("download codex binary: %w", syscall.ECONNRESET),
+	}
+	calls := 0
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		calls++
+		if calls <= len(failures) {
+			return failures[calls-1]
+		}
+		return os.WriteFile(destPath, []byte("binary"), 0o755)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	cfg := bundleConfig{cacheDir: tmpCache, downloadAttempts: 3}
+	if _, err := ensureBundledBinary(context.Background(), cfg); err != nil {
+		t.Fatalf("ensureBundledBinary returned error: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 download attempts, got %d", calls)
+	}
+}
+
+func TestEnsureBundledBinaryDoesNotRetryNotFound(t *testing.T) {
+	tmpCache := t.TempDir()
+
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	calls := 0
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		calls++
+		return fmt.Errorf("download codex binary: %w", &downloadStatusError{StatusCode: http.StatusNotFound})
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	cfg := bundleConfig{cacheDir: tmpCache, downloadAttempts: 3}
+	if _, err := ensureBundledBinary(context.Background(), cfg); err == nil {
+		t.Fatal("expected error for missing release asset")
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single attempt for 404, got %d", calls)
+	}
+}
+
+func TestDownloadWithRetryDoesNotRetryTLSFailures(t *testing.T) {
+	// The default client does not trust the test server's self-signed certificate.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach the handler")
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	defer server.Close()
+
+	calls := 0
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		calls++
+		return downloadBinaryFromRelease(ctx, cfg, info, release, destPath)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	info, _ := detectTarget("linux", "amd64")
+	cfg := bundleConfig{
+		downloadAttempts: 3,
+		assetURLFunc:     func(release, assetName string) string { return server.URL + "/" + release + "/" + assetName },
+	}
+	err := downloadWithRetry(context.Background(), cfg, info, "rust-v1", filepath.Join(t.TempDir(), info.exeName))
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) {
+		t.Fatalf("expected a certificate verification error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single attempt for a TLS failure, got %d", calls)
+	}
+}
+
+func TestIsTransientDownloadErrorClassifiesNetworkErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", &url.Error{Op: "Get", URL: "https://x", Err: &net.DNSError{Err: "timeout", IsTimeout: true}}, true},
+		{"dns not found", &url.Error{Op: "Get", URL: "https://x", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}, false},
+		{"unsupported scheme", &url.Error{Op: "Get", URL: "ftp://x", Err: errors.New("unsupported protocol scheme")}, false},
+		{"connection refused", &url.Error{Op: "Get", URL: "https://x", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, true},
+		{"server error", &downloadStatusError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"canceled", &url.Error{Op: "Get", URL: "https://x", Err: context.Canceled}, false},
+	}
+	for _, tc := range cases {
+		if got := isTransientDownloadError(tc.err); got != tc.want {
+			t.Errorf("%s: expected transient=%v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestEnsureBundledBinaryDownloadsOnceForConcurrentCallers(t *testing.T) {
+	tmpCache := t.TempDir()
+
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	var calls atomic.Int32
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return writeBinary(strings.NewReader("binary"), destPath)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	const callers = 5
+	var wg sync.WaitGroup
+	paths := make([]string, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = ensureBundledBinary(context.Background(), bundleConfig{cacheDir: tmpCache})
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("caller %d returned error: %v", i, errs[i])
+		}
+		if paths[i] != paths[0] {
+			t.Fatalf("expected callers to share %s, got %s", paths[0], paths[i])
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected a single download, got %d", got)
+	}
+	if _, err := os.Stat(paths[0] + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected lock file to be released, got %v", err)
+	}
+}
+
+func TestAcquireDownloadLockRemovesStaleLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "codex.lock")
+	if err := os.WriteFile(lockPath, []byte("123\n"), 0o600); err != nil {
+		t.Fatalf("write lock: %v", err)
+	}
+	stale := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(lockPath, stale, stale); err != nil {
+		t.Fatalf("age lock: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	unlock, err := acquireDownloadLock(ctx, lockPath)
+	if err != nil {
+		t.Fatalf("acquireDownloadLock returned error: %v", err)
+	}
+	unlock()
+
+	if staleLockAge >= defaultStartupTimeout {
+		t.Fatalf("stale lock age %s must stay below the startup timeout %s", staleLockAge, defaultStartupTimeout)
+	}
+}
+
+func TestDownloadLockReleaseKeepsTakenOverLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "codex.lock")
+	unlock, err := acquireDownloadLock(context.Background(), lockPath)
+	if err != nil {
+		t.Fatalf("acquireDownloadLock returned error: %v", err)
+	}
+	// Simulate another process taking over the lock after it was judged stale.
+	if err := os.WriteFile(lockPath, []byte("999 other-token\n"), 0o600); err != nil {
+		t.Fatalf("replace lock: %v", err)
+	}
+	unlock()
+
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		t.Fatalf("expected the new holder's lock to survive, got %v", err)
+	}
+	if string(data) != "999 other-token\n" {
+		t.Fatalf("unexpected lock contents %q", data)
+	}
+}
+
+func TestDownloadBinaryFromReleaseNamesMissingAsset(t *testing.T) {
+	info, _ := detectTarget("freebsd", "amd64")
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	cfg := bundleConfig{
+		assetURLFunc: func(release, assetName string) string { return server.URL + "/" + release + "/" + assetName },
+	}
+	err := downloadBinaryFromRelease(context.Background(), cfg, info, "rust-v1", filepath.Join(t.TempDir(), info.exeName))
+	if err == nil {
+		t.Fatal("expected error for missing asset")
+	}
+	if !strings.Contains(err.Error(), "codex-x86_64-unknown-freebsd.tar.gz") || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected error naming the missing asset, got %v", err)
+	}
+}
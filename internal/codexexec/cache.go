@@ -0,0 +1,145 @@
+package codexexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// CachedBinary describes a Codex binary stored in the cache directory.
+type CachedBinary struct {
+	// Release is the release tag the binary was downloaded from.
+	Release string
+	// Triple is the target triple of the binary.
+	Triple string
+	Path   string
+	Size   int64
+}
+
+// ListCachedBinaries scans cacheDir, laid out as <release>/<triple>/<exe>, and returns every
+// cached binary sorted by release and triple. An empty cacheDir resolves the default cache
+// location the way downloads do, skipping candidates that are not writable. A missing cache
+// directory yields an empty list.
+func ListCachedBinaries(cacheDir string) ([]CachedBinary, error) {
+	dir, err := resolveCacheDirArg(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cache directory: %w", err)
+	}
+
+	var binaries []CachedBinary
+	for _, release := range releases {
+		if !release.IsDir() {
+			continue
+		}
+		triples, err := os.ReadDir(filepath.Join(dir, release.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read release directory: %w", err)
+		}
+		for _, triple := range triples {
+			if !triple.IsDir() {
+				continue
+			}
+			for _, exeName := range []string{"codex", "codex.exe"} {
+				path := filepath.Join(dir, release.Name(), triple.Name(), exeName)
+				info, err := os.Stat(path)
+				if err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+				binaries = append(binaries, CachedBinary{
+					Release: release.Name(),
+					Triple:  triple.Name(),
+					Path:    path,
+					Size:    info.Size(),
+				})
+			}
+		}
+	}
+
+	sort.Slice(binaries, func(i, j int) bool {
+		if binaries[i].Release != binaries[j].Release {
+			return binaries[i].Release < binaries[j].Release
+		}
+		return binaries[i].Triple < binaries[j].Triple
+	})
+	return binaries, nil
+}
+
+// ClearCache removes the cached Codex binaries found by ListCachedBinaries under cacheDir and
+// returns the number of bytes freed. An empty cacheDir resolves the default cache location.
+// Only <release>/<triple>/{codex,codex.exe} entries and their signature records are deleted;
+// other files are left alone, as is any binary whose download lock is currently held. Release
+// and triple directories are removed once empty. On Windows, binaries that are currently
+// executing cannot be deleted and are left in place.
+func ClearCache(cacheDir string) (int64, error) {
+	dir, err := resolveCacheDirArg(cacheDir)
+	if err != nil {
+		return 0, err
+	}
+	dir = filepath.Clean(dir)
+	if dir == filepath.Dir(dir) {
+		return 0, fmt.Errorf("refusing to clear filesystem root %q as the CLI cache", dir)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return 0, fmt.Errorf("CLI cache directory %q: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return 0, fmt.Errorf("CLI cache path %q is not a directory", dir)
+	}
+
+	binaries, err := ListCachedBinaries(dir)
+	if err != nil {
+		return 0, fmt.Errorf("clear CLI cache: %w", err)
+	}
+
+	var freed int64
+	for _, binary := range binaries {
+		// A held lock means another process is downloading or verifying this binary.
+		if downloadLockHeld(binary.Path + ".lock") {
+			continue
+		}
+		if err := os.Remove(binary.Path); err != nil {
+			// Windows refuses to delete an executable that is running; keep it.
+			if runtime.GOOS == "windows" && errors.Is(err, fs.ErrPermission) {
+				continue
+			}
+			return freed, fmt.Errorf("clear CLI cache: remove cached binary: %w", err)
+		}
+		freed += binary.Size
+		if record, err := os.Stat(binary.Path + signatureRecordSuffix); err == nil {
+			if os.Remove(binary.Path+signatureRecordSuffix) == nil {
+				freed += record.Size()
+			}
+		}
+
+		// Remove the triple and release directories if nothing else is left in them.
+		tripleDir := filepath.Dir(binary.Path)
+		_ = os.Remove(tripleDir)
+		_ = os.Remove(filepath.Dir(tripleDir))
+	}
+	return freed, nil
+}
+
+// resolveCacheDirArg returns cacheDir as given, or the default cache directory downloads use
+// when it is empty. An explicit directory is never swapped for a fallback, so clearing it can
+// only touch what the caller named.
+func resolveCacheDirArg(cacheDir string) (string, error) {
+	if dir := strings.TrimSpace(cacheDir); dir != "" {
+		return dir, nil
+	}
+	return bundleConfig{}.writableCacheDir(context.Background())
+}
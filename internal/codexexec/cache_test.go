@@ -0,0 +1,151 @@
+package codexexec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListCachedBinariesReportsReleases(t *testing.T) {
+	tmp := t.TempDir()
+	seed := func(release, triple, exe, contents string) string {
+		dir := filepath.Join(tmp, release, triple)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		path := filepath.Join(dir, exe)
+		if err := os.WriteFile(path, []byte(contents), 0o700); err != nil {
+			t.Fatalf("write binary: %v", err)
+		}
+		return path
+	}
+
+	oldPath := seed("rust-v0.50.0", "x86_64-unknown-linux-musl", "codex", "old")
+	newPath := seed("rust-v0.55.0", "x86_64-pc-windows-msvc", "codex.exe", "newer")
+	// Partial downloads and empty triples are ignored.
+	seed("rust-v0.55.0", "x86_64-pc-windows-msvc", "codex.exe.tmp-123", "partial")
+	if err := os.MkdirAll(filepath.Join(tmp, "rust-v0.56.0", "aarch64-apple-darwin"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	binaries, err := ListCachedBinaries(tmp)
+	if err != nil {
+		t.Fatalf("ListCachedBinaries returned error: %v", err)
+	}
+	expected := []CachedBinary{
+		{Release: "rust-v0.50.0", Triple: "x86_64-unknown-linux-musl", Path: oldPath, Size: 3},
+		{Release: "rust-v0.55.0", Triple: "x86_64-pc-windows-msvc", Path: newPath, Size: 5},
+	}
+	if len(binaries) != len(expected) {
+		t.Fatalf("expected %d binaries, got %+v", len(expected), binaries)
+	}
+	for i, want := range expected {
+		if binaries[i] != want {
+			t.Fatalf("binary %d: expected %+v, got %+v", i, want, binaries[i])
+		}
+	}
+}
+
+func TestListCachedBinariesMissingDirectory(t *testing.T) {
+	binaries, err := ListCachedBinaries(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("ListCachedBinaries returned error: %v", err)
+	}
+	if len(binaries) != 0 {
+		t.Fatalf("expected no binaries, got %+v", binaries)
+	}
+}
+
+func TestClearCacheRemovesCachedBinaries(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(rel, contents string) string {
+		path := filepath.Join(tmp, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o700); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		return path
+	}
+	write("rust-v0.50.0/x86_64-unknown-linux-musl/codex", "old binary")
+	write("rust-v0.50.0/x86_64-unknown-linux-musl/codex"+signatureRecordSuffix, "record")
+	// A download in progress holds the lock; its binary, lock and temp file must survive.
+	busy := write("rust-v0.55.0/x86_64-unknown-linux-musl/codex", "new")
+	busyLock := write("rust-v0.55.0/x86_64-unknown-linux-musl/codex.lock", "1 token\n")
+	busyTemp := write("rust-v0.55.0/x86_64-unknown-linux-musl/codex.tmp-123", "partial")
+	unrelated := write("notes.txt", "keep me")
+
+	freed, err := ClearCache(tmp)
+	if err != nil {
+		t.Fatalf("ClearCache returned error: %v", err)
+	}
+	if want := int64(len("old binary") + len("record")); freed != want {
+		t.Fatalf("expected %d bytes freed, got %d", want, freed)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "rust-v0.50.0")); !os.IsNotExist(err) {
+		t.Fatalf("expected the emptied release directory to be removed, got %v", err)
+	}
+	for _, path := range []string{busy, busyLock, busyTemp, unrelated} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %s to be kept: %v", path, err)
+		}
+	}
+}
+
+func TestClearCacheRejectsBogusPath(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "does-not-exist")
+	if _, err := ClearCache(missing); err == nil || !strings.Contains(err.Error(), missing) {
+		t.Fatalf("expected error naming the missing cache dir, got %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "cache-file")
+	if err := os.WriteFile(file, []byte("x"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := ClearCache(file); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Fatalf("expected not-a-directory error, got %v", err)
+	}
+}
+
+func TestDefaultCacheSkipsUnwritableCandidates(t *testing.T) {
+	// A directory below a regular file can never be created, even when running as root.
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, []byte("x"), 0o600); err != nil {
+		t.Fatalf("write blocker: %v", err)
+	}
+	t.Setenv("GODEX_CLI_CACHE", filepath.Join(blocker, "cache"))
+	home := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", home)
+	t.Setenv("HOME", home)
+	t.Setenv("LocalAppData", home)
+	userCache, err := os.UserCacheDir()
+	if err != nil {
+		t.Skipf("no user cache directory: %v", err)
+	}
+
+	path := filepath.Join(userCache, "godex", "codex", "rust-v0.55.0", "x86_64-unknown-linux-musl", "codex")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("binary"), 0o700); err != nil {
+		t.Fatalf("write binary: %v", err)
+	}
+
+	binaries, err := ListCachedBinaries("")
+	if err != nil {
+		t.Fatalf("ListCachedBinaries returned error: %v", err)
+	}
+	if len(binaries) != 1 || binaries[0].Path != path {
+		t.Fatalf("expected the binary in the writable fallback, got %+v", binaries)
+	}
+
+	freed, err := ClearCache("")
+	if err != nil {
+		t.Fatalf("ClearCache returned error: %v", err)
+	}
+	if freed != int64(len("binary")) {
+		t.Fatalf("expected %d bytes freed, got %d", len("binary"), freed)
+	}
+}
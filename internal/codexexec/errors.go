@@ -0,0 +1,66 @@
+package codexexec
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// ErrDiskFull reports that a write failed because the target filesystem ran out of space.
+var ErrDiskFull = errors.New("disk full")
+
+// DiskFullError records the path whose write failed with ENOSPC. It matches ErrDiskFull via
+// errors.Is and unwraps to the underlying filesystem error.
+type DiskFullError struct {
+	Path string
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *DiskFullError) Error() string {
+	if e == nil {
+		return ""
+	}
+	return fmt.Sprintf("disk full writing %s: %v", e.Path, e.Err)
+}
+
+// Unwrap exposes the underlying filesystem error.
+func (e *DiskFullError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrDiskFull.
+func (e *DiskFullError) Is(target error) bool {
+	return target == ErrDiskFull
+}
+
+// CheckDiskFull converts ENOSPC failures into a *DiskFullError for path and returns any other
+// error unchanged.
+func CheckDiskFull(path string, err error) error {
+	if err == nil || !errors.Is(err, syscall.ENOSPC) {
+		return err
+	}
+	var diskErr *DiskFullError
+	if errors.As(err, &diskErr) {
+		return err
+	}
+	return &DiskFullError{Path: path, Err: err}
+}
+
+// ExecError reports that the codex process exited with a non-zero status.
+type ExecError struct {
+	// ExitCode is the process exit status.
+	ExitCode int
+	// Stderr holds everything the process wrote to stderr.
+	Stderr string
+	// Args are the command-line arguments passed to the codex binary.
+	Args []string
+}
+
+// Error implements the error interface.
+func (e *ExecError) Error() string {
+	if e == nil {
+		return ""
+	}
+	return fmt.Sprintf("codex exec failed with code %d: %s", e.ExitCode, e.Stderr)
+}
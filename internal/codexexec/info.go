@@ -0,0 +1,45 @@
+package codexexec
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// BundleInfo reports the bundle configuration a Runner resolved after applying option,
+// environment and default precedence.
+type BundleInfo struct {
+	// CacheDir is the cache directory holding the bundled binary, or the directory downloads
+	// would use when the binary did not come from the cache.
+	CacheDir string
+	// ReleaseTag is the release the bundled binary was taken from; "latest" is reported as
+	// the tag it resolved to. For other binaries it is the configured tag.
+	ReleaseTag string
+	// Checksum is the normalized SHA-256 checksum enforced on downloads, empty when none.
+	Checksum string
+	// BinaryPath is the Codex executable used for every run.
+	BinaryPath string
+	// Bundled reports whether BinaryPath lives in the download cache rather than being a
+	// path override or a `codex` found on PATH.
+	Bundled bool
+}
+
+// BundleInfo returns the effective bundle configuration used by r.
+func (r *Runner) BundleInfo() BundleInfo {
+	r.mu.RLock()
+	path, bundled := r.executablePath, r.bundled
+	r.mu.RUnlock()
+
+	info := BundleInfo{BinaryPath: path, Bundled: bundled}
+	// An unparsable checksum is never enforced, so it is reported as empty.
+	info.Checksum, _ = r.bootstrap.checksumValue()
+	if bundled {
+		// Bundled binaries live at <cacheDir>/<release>/<triple>/<exe>.
+		releaseDir := filepath.Dir(filepath.Dir(path))
+		info.ReleaseTag = filepath.Base(releaseDir)
+		info.CacheDir = filepath.Dir(releaseDir)
+		return info
+	}
+	info.CacheDir, _ = r.bootstrap.writableCacheDir(context.Background())
+	info.ReleaseTag = r.bootstrap.releaseTagName()
+	return info
+}
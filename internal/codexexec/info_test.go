@@ -0,0 +1,71 @@
+package codexexec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunnerBundleInfoReportsResolvedConfiguration(t *testing.T) {
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		return os.WriteFile(destPath, []byte("binary"), 0o700)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	sum := sha256.Sum256([]byte("binary"))
+	checksum := hex.EncodeToString(sum[:])
+	cacheDir := t.TempDir()
+	t.Setenv("GODEX_CLI_CACHE", t.TempDir())
+	t.Setenv("GODEX_CLI_RELEASE_TAG", "rust-v-env")
+	t.Setenv("GODEX_CLI_CHECKSUM", strings.ToUpper(checksum))
+
+	runner, err := New(RunnerOptions{CacheDir: cacheDir, ReleaseTag: "rust-v-option"})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	info := runner.BundleInfo()
+	expected := BundleInfo{
+		CacheDir:   cacheDir,
+		ReleaseTag: "rust-v-option",
+		Checksum:   checksum,
+		BinaryPath: filepath.Join(cacheDir, "rust-v-option", "x86_64-unknown-linux-musl", "codex"),
+		Bundled:    true,
+	}
+	if info != expected {
+		t.Fatalf("expected %+v, got %+v", expected, info)
+	}
+}
+
+func TestRunnerBundleInfoWithPathOverride(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "codex")
+	if err := os.WriteFile(binary, []byte("binary"), 0o700); err != nil {
+		t.Fatalf("write binary: %v", err)
+	}
+	envCache := t.TempDir()
+	t.Setenv("GODEX_CLI_CACHE", envCache)
+	t.Setenv("GODEX_CLI_RELEASE_TAG", "rust-v-env")
+	t.Setenv("GODEX_CLI_CHECKSUM", "")
+
+	runner, err := New(RunnerOptions{PathOverride: binary})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	info := runner.BundleInfo()
+	expected := BundleInfo{CacheDir: envCache, ReleaseTag: "rust-v-env", BinaryPath: binary}
+	if info != expected {
+		t.Fatalf("expected %+v, got %+v", expected, info)
+	}
+}
@@ -0,0 +1,138 @@
+package codexexec
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// lockPollInterval is how often a waiting downloader checks whether the lock was released.
+	lockPollInterval = 50 * time.Millisecond
+	// lockRefreshInterval is how often the holder touches the lock file to show it is alive.
+	lockRefreshInterval = 10 * time.Second
+	// staleLockAge is how long a lock file may go without a refresh before it is assumed
+	// abandoned by a crashed process. It stays well below defaultStartupTimeout so a crashed
+	// downloader cannot make New time out for everyone else.
+	staleLockAge = time.Minute
+)
+
+// acquireDownloadLock takes an advisory lock by exclusively creating path with this process's
+// PID and a random token. The holder refreshes the file's modification time while it holds
+// the lock; waiters take over a lock that has not been refreshed for staleLockAge. It waits
+// until the lock is acquired or ctx is done. The returned function releases it.
+func acquireDownloadLock(ctx context.Context, path string) (func(), error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+	contents := []byte(fmt.Sprintf("%d %s\n", os.Getpid(), token))
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_, writeErr := f.Write(contents)
+			closeErr := f.Close()
+			if err := errors.Join(writeErr, closeErr); err != nil {
+				_ = os.Remove(path)
+				return nil, CheckDiskFull(path, fmt.Errorf("write download lock: %w", err))
+			}
+			return holdDownloadLock(path, contents), nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, CheckDiskFull(path, fmt.Errorf("create download lock: %w", err))
+		}
+
+		if stale, ok := staleLockContents(path); ok {
+			takeOverStaleLock(path, stale, token)
+			continue
+		}
+
+		timer := time.NewTimer(lockPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("wait for download lock: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func newLockToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate download lock token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// holdDownloadLock refreshes the lock at path until the returned release function is called.
+// Release only removes the file while it still carries contents, so a lock that was taken over
+// in the meantime is left to its new holder.
+func holdDownloadLock(path string, contents []byte) func() {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				_ = os.Chtimes(path, now, now)
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, contents) {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+// staleLockContents returns the contents of the lock at path when it has not been refreshed
+// for staleLockAge.
+func staleLockContents(path string) ([]byte, bool) {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) <= staleLockAge {
+		return nil, false
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return contents, true
+}
+
+// takeOverStaleLock removes the stale lock at path. The lock is first renamed aside so that
+// only one waiter can claim it; if the renamed file turns out to be a fresh lock created after
+// the staleness check, it is put back for its holder.
+func takeOverStaleLock(path string, stale []byte, token string) {
+	aside := path + ".stale-" + token
+	if err := os.Rename(path, aside); err != nil {
+		return
+	}
+	contents, err := os.ReadFile(aside)
+	if err == nil && !bytes.Equal(contents, stale) {
+		_ = os.Rename(aside, path)
+		return
+	}
+	_ = os.Remove(aside)
+}
+
+// downloadLockHeld reports whether a live (non-stale) download lock exists at path.
+func downloadLockHeld(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) <= staleLockAge
+}
@@ -0,0 +1,44 @@
+package codexexec
+
+import (
+	"context"
+	"log/slog"
+)
+
+// newBundleLogger wraps logger so that records below minLevel are dropped before they reach
+// its handler. It returns nil when logger is nil.
+func newBundleLogger(logger *slog.Logger, minLevel slog.Level) *slog.Logger {
+	if logger == nil {
+		return nil
+	}
+	return slog.New(&levelHandler{min: minLevel, inner: logger.Handler()})
+}
+
+// levelHandler enforces a minimum level on top of another handler's own filtering.
+type levelHandler struct {
+	min   slog.Level
+	inner slog.Handler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.min && h.inner.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{min: h.min, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{min: h.min, inner: h.inner.WithGroup(name)}
+}
+
+// log records a bundle message when a logger is configured.
+func (cfg bundleConfig) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if cfg.logger != nil {
+		cfg.logger.Log(ctx, level, msg, args...)
+	}
+}
@@ -0,0 +1,55 @@
+package codexexec
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBundleLoggerSuppressesRecordsBelowLevel(t *testing.T) {
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		return os.WriteFile(destPath, []byte("binary"), 0o700)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	run := func(level slog.Level) string {
+		t.Helper()
+		var buf bytes.Buffer
+		// The application logger itself accepts everything down to debug.
+		logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		cfg := bundleConfig{cacheDir: t.TempDir(), logger: newBundleLogger(logger, level)}
+		for i := 0; i < 2; i++ {
+			if _, err := ensureBundledBinary(context.Background(), cfg); err != nil {
+				t.Fatalf("ensureBundledBinary returned error: %v", err)
+			}
+		}
+		return buf.String()
+	}
+
+	infoLogs := run(slog.LevelInfo)
+	if !strings.Contains(infoLogs, "downloading codex CLI") {
+		t.Fatalf("expected info records, got %q", infoLogs)
+	}
+	if strings.Contains(infoLogs, "level=DEBUG") {
+		t.Fatalf("expected debug records to be suppressed, got %q", infoLogs)
+	}
+
+	debugLogs := run(slog.LevelDebug)
+	if !strings.Contains(debugLogs, "using cached codex binary") {
+		t.Fatalf("expected debug records at debug level, got %q", debugLogs)
+	}
+
+	if warnLogs := run(slog.LevelWarn); warnLogs != "" {
+		t.Fatalf("expected a successful download to be silent at warn level, got %q", warnLogs)
+	}
+}
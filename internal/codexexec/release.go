@@ -0,0 +1,140 @@
+package codexexec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latestReleaseTag is the CLIReleaseTag value that resolves the newest published release.
+const latestReleaseTag = "latest"
+
+// latestReleaseURL is the GitHub API endpoint describing the newest release. Tests override it.
+var latestReleaseURL = "https://api.github.com/repos/openai/codex/releases/latest"
+
+// resolvedLatest caches "latest" resolutions for the process lifetime, keyed by endpoint.
+var resolvedLatest = struct {
+	mu   sync.Mutex
+	tags map[string]string
+}{tags: make(map[string]string)}
+
+// releaseMetadataEndpoint returns the URL queried to resolve "latest": the configured
+// metadata URL, then $GODEX_CLI_RELEASE_METADATA_URL, then the GitHub releases API. When a
+// download mirror is configured without a metadata URL it returns "", since the GitHub API
+// may be unreachable from where the mirror is used.
+func (cfg bundleConfig) releaseMetadataEndpoint() string {
+	if endpoint := strings.TrimSpace(cfg.releaseMetadataURL); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := strings.TrimSpace(os.Getenv("GODEX_CLI_RELEASE_METADATA_URL")); endpoint != "" {
+		return endpoint
+	}
+	if cfg.assetURLFunc != nil || strings.TrimSpace(cfg.baseURL) != "" || strings.TrimSpace(os.Getenv("GODEX_CLI_BASE_URL")) != "" {
+		return ""
+	}
+	return latestReleaseURL
+}
+
+// resolveReleaseTag maps the "latest" release tag to a concrete tag; a pinned tag is returned
+// without any lookup. When the release metadata is unreachable, or there is no endpoint to
+// ask, it reuses the most recently cached release for the target triple, falling back to the
+// pinned default when nothing is cached.
+func resolveReleaseTag(ctx context.Context, cfg bundleConfig, cacheDir string, info targetInfo) string {
+	release := cfg.releaseTagName()
+	if !strings.EqualFold(release, latestReleaseTag) {
+		return release
+	}
+
+	fallback := func() string {
+		if cached := newestCachedRelease(cacheDir, info); cached != "" {
+			return cached
+		}
+		return defaultCodexReleaseTag
+	}
+	endpoint := cfg.releaseMetadataEndpoint()
+	if endpoint == "" {
+		cfg.log(ctx, slog.LevelDebug, "no release metadata URL for the download mirror; not resolving latest online")
+		return fallback()
+	}
+
+	resolvedLatest.mu.Lock()
+	tag, ok := resolvedLatest.tags[endpoint]
+	resolvedLatest.mu.Unlock()
+	if ok {
+		return tag
+	}
+
+	// The lock is not held while querying, so a slow endpoint cannot block other resolutions.
+	tag, err := fetchLatestReleaseTag(ctx, cfg, endpoint)
+	if err != nil {
+		cfg.log(ctx, slog.LevelWarn, "resolving latest codex release failed", "endpoint", endpoint, "error", err)
+		return fallback()
+	}
+	resolvedLatest.mu.Lock()
+	resolvedLatest.tags[endpoint] = tag
+	resolvedLatest.mu.Unlock()
+	return tag
+}
+
+func fetchLatestReleaseTag(ctx context.Context, cfg bundleConfig, endpoint string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("create latest release request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := cfg.downloadClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("query latest release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("query latest release: %w", &downloadStatusError{StatusCode: resp.StatusCode})
+	}
+
+	var payload struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&payload); err != nil {
+		return "", fmt.Errorf("decode latest release: %w", err)
+	}
+	tag := strings.TrimSpace(payload.TagName)
+	if tag == "" {
+		return "", fmt.Errorf("latest release response has no tag_name")
+	}
+	return tag, nil
+}
+
+// newestCachedRelease returns the release whose cached binary for info was modified most
+// recently, or "" when none is cached.
+func newestCachedRelease(cacheDir string, info targetInfo) string {
+	releases, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return ""
+	}
+	var (
+		newest     string
+		newestTime time.Time
+	)
+	for _, release := range releases {
+		if !release.IsDir() {
+			continue
+		}
+		stat, err := os.Stat(filepath.Join(cacheDir, release.Name(), info.triple, info.exeName))
+		if err != nil || !stat.Mode().IsRegular() {
+			continue
+		}
+		if newest == "" || stat.ModTime().After(newestTime) {
+			newest, newestTime = release.Name(), stat.ModTime()
+		}
+	}
+	return newest
+}
@@ -0,0 +1,167 @@
+package codexexec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func useLatestReleaseEndpoint(t *testing.T, endpoint string) {
+	t.Helper()
+	original := latestReleaseURL
+	latestReleaseURL = endpoint
+	t.Cleanup(func() { latestReleaseURL = original })
+	forgetLatestRelease(t, endpoint)
+}
+
+// forgetLatestRelease drops the cached "latest" resolution for endpoint when the test ends.
+func forgetLatestRelease(t *testing.T, endpoint string) {
+	t.Helper()
+	t.Cleanup(func() {
+		resolvedLatest.mu.Lock()
+		delete(resolvedLatest.tags, endpoint)
+		resolvedLatest.mu.Unlock()
+	})
+}
+
+func TestEnsureBundledBinaryResolvesLatestRelease(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"tag_name":"rust-v9.9.9"}`))
+	}))
+	defer server.Close()
+	forgetLatestRelease(t, server.URL)
+
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	var assetURLs []string
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		url, err := cfg.assetURL(release, info.assetName)
+		if err != nil {
+			return err
+		}
+		assetURLs = append(assetURLs, url)
+		return os.WriteFile(destPath, []byte("latest"), 0o755)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	cfg := bundleConfig{cacheDir: t.TempDir(), releaseTag: "latest", baseURL: "https://mirror.example.com", releaseMetadataURL: server.URL}
+	for i := 0; i < 2; i++ {
+		path, err := ensureBundledBinary(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("ensureBundledBinary returned error: %v", err)
+		}
+		if want := filepath.Join(cfg.cacheDir, "rust-v9.9.9", "x86_64-unknown-linux-musl", "codex"); path != want {
+			t.Fatalf("expected path %s, got %s", want, path)
+		}
+	}
+
+	if want := "https://mirror.example.com/rust-v9.9.9/codex-x86_64-unknown-linux-musl.tar.gz"; len(assetURLs) != 1 || assetURLs[0] != want {
+		t.Fatalf("expected a single download from %s, got %v", want, assetURLs)
+	}
+	if requests != 1 {
+		t.Fatalf("expected latest tag to be cached for the process, got %d API requests", requests)
+	}
+}
+
+func TestResolveReleaseTagFallsBackWhenAPIFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer server.Close()
+	useLatestReleaseEndpoint(t, server.URL)
+
+	info, _ := detectTarget("linux", "amd64")
+	cacheDir := t.TempDir()
+	cfg := bundleConfig{releaseTag: "latest"}
+
+	if got := resolveReleaseTag(context.Background(), cfg, cacheDir, info); got != defaultCodexReleaseTag {
+		t.Fatalf("expected pinned default without a cache, got %s", got)
+	}
+
+	cachedDir := filepath.Join(cacheDir, "rust-v0.60.0", info.triple)
+	if err := os.MkdirAll(cachedDir, 0o755); err != nil {
+		t.Fatalf("mkdir cache: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(cachedDir, info.exeName), []byte("cached"), 0o755); err != nil {
+		t.Fatalf("write cached binary: %v", err)
+	}
+	if got := resolveReleaseTag(context.Background(), cfg, cacheDir, info); got != "rust-v0.60.0" {
+		t.Fatalf("expected cached release, got %s", got)
+	}
+}
+
+func TestResolveReleaseTagSkipsGitHubForMirrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"tag_name":"rust-v9.9.9"}`))
+	}))
+	defer server.Close()
+	useLatestReleaseEndpoint(t, server.URL)
+	t.Setenv("GODEX_CLI_RELEASE_METADATA_URL", "")
+
+	info, _ := detectTarget("linux", "amd64")
+	for _, cfg := range []bundleConfig{
+		{releaseTag: "latest", baseURL: "https://mirror.example.com"},
+		{releaseTag: "latest", assetURLFunc: func(release, assetName string) string { return "" }},
+		{releaseTag: "rust-v0.1.0", releaseMetadataURL: server.URL},
+	} {
+		got := resolveReleaseTag(context.Background(), cfg, t.TempDir(), info)
+		want := defaultCodexReleaseTag
+		if cfg.releaseTag != "latest" {
+			want = cfg.releaseTag
+		}
+		if got != want {
+			t.Fatalf("resolveReleaseTag = %s, want %s", got, want)
+		}
+	}
+	if requests != 0 {
+		t.Fatalf("expected no release metadata requests, got %d", requests)
+	}
+}
+
+func TestResolveReleaseTagDoesNotHoldLockDuringLookup(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-release
+		_, _ = w.Write([]byte(`{"tag_name":"rust-v1.0.0"}`))
+	}))
+	defer slow.Close()
+	defer close(release)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"tag_name":"rust-v2.0.0"}`))
+	}))
+	defer fast.Close()
+	forgetLatestRelease(t, slow.URL)
+	forgetLatestRelease(t, fast.URL)
+
+	info, _ := detectTarget("linux", "amd64")
+	go resolveReleaseTag(context.Background(), bundleConfig{releaseTag: "latest", releaseMetadataURL: slow.URL}, t.TempDir(), info)
+	<-arrived
+
+	done := make(chan string, 1)
+	go func() {
+		done <- resolveReleaseTag(context.Background(), bundleConfig{releaseTag: "latest", releaseMetadataURL: fast.URL}, t.TempDir(), info)
+	}()
+	select {
+	case got := <-done:
+		if got != "rust-v2.0.0" {
+			t.Fatalf("expected rust-v2.0.0, got %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow release lookup blocked an unrelated one")
+	}
+}
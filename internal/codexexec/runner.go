@@ -7,17 +7,36 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 const (
 	internalOriginatorEnv = "CODEX_INTERNAL_ORIGINATOR_OVERRIDE"
+	apiKeyEnv             = "CODEX_API_KEY"
+	codexHomeEnv          = "CODEX_HOME"
 	goSDKOriginator       = "codex_sdk_go"
 )
 
+// defaultStartupTimeout bounds binary discovery and download in New when
+// RunnerOptions.StartupTimeout is zero.
+const defaultStartupTimeout = 5 * time.Minute
+
+const (
+	defaultDownloadAttempts = 3
+	defaultRetryBaseDelay   = time.Second
+)
+
+// commandFactory builds the codex process. Tests override it to substitute a helper process.
+var commandFactory = exec.CommandContext
+
 // RunnerOptions controls how the Codex CLI binary is discovered / bootstrapped before execution.
 type RunnerOptions struct {
 	// PathOverride points directly at a Codex binary instead of discovering/downloading it.
@@ -28,6 +47,34 @@ type RunnerOptions struct {
 	ReleaseTag string
 	// ChecksumHex enforces an expected SHA-256 checksum (hex encoded) for the downloaded binary.
 	ChecksumHex string
+	// SignaturePublicKey enables ed25519 verification of downloaded assets against their
+	// detached "<asset>.sig" signature. Hex or base64 encoded.
+	SignaturePublicKey string
+	// DownloadBaseURL replaces the GitHub release location; assets are fetched from
+	// <DownloadBaseURL>/<release>/<asset>.
+	DownloadBaseURL string
+	// AssetURLFunc, when set, returns the full download URL for a release asset.
+	AssetURLFunc func(release, assetName string) string
+	// ReleaseMetadataURL is queried to resolve the "latest" release tag. It must answer like
+	// the GitHub "latest release" API, with a JSON object holding tag_name.
+	ReleaseMetadataURL string
+	// ForceDownload replaces any cached binary with a fresh download.
+	ForceDownload bool
+	// VerifyBinaryRuns runs `codex --version` after each download and fails with
+	// ErrBinaryNotRunnable, removing the download, when it does not succeed.
+	VerifyBinaryRuns bool
+	// HTTPClient downloads the Codex binary; nil uses a default client with a two minute timeout.
+	HTTPClient *http.Client
+	// DownloadProgress receives byte counts while the Codex binary is downloaded.
+	DownloadProgress func(downloaded, total int64)
+	// StartupTimeout bounds binary discovery and download. Zero uses a five minute default
+	// and a negative value disables the limit.
+	StartupTimeout time.Duration
+	// Logger receives download and cache diagnostics; nil disables them.
+	Logger *slog.Logger
+	// LogLevel is the minimum level of download records passed to Logger, on top of the
+	// logger's own level. The zero value is slog.LevelInfo.
+	LogLevel slog.Level
 }
 
 // Args mirrors the CLI flags accepted by `codex exec`.
@@ -41,42 +88,167 @@ type Args struct {
 	WorkingDirectory string
 	SkipGitRepoCheck bool
 	OutputSchemaPath string
-	Images           []string
-	ConfigOverrides  map[string]any
+	// ResumeFlag emits `--resume <id>` instead of the trailing `resume <id>` subcommand.
+	ResumeFlag bool
+	// ResumeContextFile is forwarded as `-c experimental_resume=<path>` when ThreadID is set.
+	ResumeContextFile string
+	Images            []string
+	ConfigOverrides   map[string]any
+	// EventFlushInterval, when positive, is forwarded as `-c <EventFlushIntervalKey>=<ms>`
+	// unless ConfigOverrides already sets that key.
+	EventFlushInterval time.Duration
+	// ExtraArgs are appended verbatim after the managed flags and before `resume <id>`.
+	ExtraArgs []string
+	// ExtraEnv is merged over the inherited environment and the SDK-managed variables.
+	ExtraEnv map[string]string
+	// DisableOriginator stops the SDK from setting CODEX_INTERNAL_ORIGINATOR_OVERRIDE.
+	DisableOriginator bool
+	// PreferLogin makes a stored CLI login win over APIKey: when auth.json exists in the CLI's
+	// home directory, neither APIKey nor an inherited CODEX_API_KEY reaches the process.
+	PreferLogin bool
+	// OnStderr, when set, receives each line the process writes to stderr (without the
+	// trailing newline) while it runs. Stderr is still captured in full for ExecError.
+	OnStderr func(line []byte)
+	// OnStdin, when set, receives a copy of the prompt bytes once they are written to stdin.
+	OnStdin func(data []byte)
+	// KeepStdinOpen leaves stdin open after the newline-terminated prompt and hands it to
+	// OnStdinReady so further messages can be written while the process runs. The pipe is
+	// closed when the process exits.
+	KeepStdinOpen bool
+	OnStdinReady  func(stdin io.WriteCloser)
+	// OnInterruptReady, when set, receives a function that sends the running process an
+	// interrupt signal so it can end the turn gracefully. It is never called on Windows, where
+	// the process cannot be interrupted without killing it.
+	OnInterruptReady func(interrupt func() error)
 }
 
 // Runner wraps execution of the Codex CLI.
 type Runner struct {
+	bootstrap    bundleConfig
+	pathOverride bool
+
+	mu             sync.RWMutex
 	executablePath string
+	// bundled reports whether executablePath was downloaded into (or found in) the cache.
+	bundled bool
 }
 
 // New constructs a Runner, optionally overriding the codex binary path.
 func New(options RunnerOptions) (*Runner, error) {
 	path := options.PathOverride
+	var bundled bool
 	bootstrap := bundleConfig{
-		cacheDir:    options.CacheDir,
-		releaseTag:  options.ReleaseTag,
-		checksumHex: options.ChecksumHex,
+		cacheDir:           options.CacheDir,
+		releaseTag:         options.ReleaseTag,
+		checksumHex:        options.ChecksumHex,
+		signaturePublicKey: options.SignaturePublicKey,
+		baseURL:            options.DownloadBaseURL,
+		assetURLFunc:       options.AssetURLFunc,
+		releaseMetadataURL: options.ReleaseMetadataURL,
+		forceDownload:      options.ForceDownload,
+		verifyRuns:         options.VerifyBinaryRuns,
+		httpClient:         options.HTTPClient,
+		progress:           options.DownloadProgress,
+		logger:             newBundleLogger(options.Logger, options.LogLevel),
+
+		downloadAttempts: defaultDownloadAttempts,
+		retryBaseDelay:   defaultRetryBaseDelay,
 	}
 	if path == "" {
+		if _, err := bootstrap.downloadBaseURL(); err != nil {
+			return nil, err
+		}
+
+		ctx := context.Background()
+		timeout := options.StartupTimeout
+		if timeout == 0 {
+			timeout = defaultStartupTimeout
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
 		var err error
-		path, err = findCodexPath(bootstrap)
+		path, bundled, err = findCodexPath(ctx, bootstrap)
 		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("codex binary startup timed out after %s: %w", timeout, ctxErr)
+			}
 			return nil, err
 		}
 	}
 	if _, err := os.Stat(path); err != nil {
 		return nil, fmt.Errorf("unable to locate codex binary at %q: %w", path, err)
 	}
-	return &Runner{executablePath: path}, nil
+	return &Runner{
+		bootstrap:      bootstrap,
+		pathOverride:   options.PathOverride != "",
+		executablePath: path,
+		bundled:        bundled,
+	}, nil
+}
+
+// Refresh discards the cached Codex binary and downloads it again, verifying the configured
+// checksum. Subsequent runs use the refreshed binary. Runners constructed with PathOverride
+// cannot be refreshed.
+func (r *Runner) Refresh(ctx context.Context) error {
+	if r.pathOverride {
+		return errors.New("codex binary path is overridden; nothing to refresh")
+	}
+	cfg := r.bootstrap
+	cfg.forceDownload = true
+	path, err := ensureBundledBinary(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("refresh bundled codex binary: %w", err)
+	}
+
+	r.mu.Lock()
+	r.executablePath = path
+	r.bundled = true
+	r.mu.Unlock()
+	return nil
+}
+
+// Version runs `codex --version` and returns its trimmed standard output.
+func (r *Runner) Version(ctx context.Context) (string, error) {
+	commandArgs := []string{"--version"}
+	cmd := commandFactory(ctx, r.path(), commandArgs...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", &ExecError{ExitCode: exitErr.ExitCode(), Stderr: stderr.String(), Args: commandArgs}
+		}
+		return "", fmt.Errorf("run codex --version: %w", err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (r *Runner) path() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.executablePath
 }
 
 // Run executes `codex exec --experimental-json` and streams each JSONL line through handleLine.
+// A final line without a trailing newline is delivered when the process exits on its own; if
+// the process is killed because ctx is done, such a partial line is discarded.
 func (r *Runner) Run(ctx context.Context, args Args, handleLine func([]byte) error) error {
 	commandArgs := buildCommandArgs(args)
 
-	cmd := exec.CommandContext(ctx, r.executablePath, commandArgs...)
-	cmd.Env = buildEnv(args.BaseURL, args.APIKey)
+	env, err := buildEnv(args)
+	if err != nil {
+		return err
+	}
+	cmd := commandFactory(ctx, r.path(), commandArgs...)
+	cmd.Env = env
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -96,14 +268,33 @@ func (r *Runner) Run(ctx context.Context, args Args, handleLine func([]byte) err
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("starting codex exec: %w", err)
 	}
+	if args.OnInterruptReady != nil && runtimeGOOS != "windows" {
+		process := cmd.Process
+		args.OnInterruptReady(func() error {
+			return process.Signal(os.Interrupt)
+		})
+	}
 
-	if _, err := io.WriteString(stdin, args.Input); err != nil {
+	prompt := args.Input
+	if args.KeepStdinOpen && !strings.HasSuffix(prompt, "\n") {
+		prompt += "\n"
+	}
+	if _, err := io.WriteString(stdin, prompt); err != nil {
 		_ = stdin.Close()
 		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
 		return fmt.Errorf("writing prompt to codex stdin: %w", err)
 	}
-	if err := stdin.Close(); err != nil {
+	if args.OnStdin != nil {
+		args.OnStdin([]byte(prompt))
+	}
+	if args.KeepStdinOpen {
+		if args.OnStdinReady != nil {
+			args.OnStdinReady(stdin)
+		}
+	} else if err := stdin.Close(); err != nil {
 		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
 		return fmt.Errorf("closing codex stdin: %w", err)
 	}
 
@@ -112,16 +303,35 @@ func (r *Runner) Run(ctx context.Context, args Args, handleLine func([]byte) err
 	stderrWG.Add(1)
 	go func() {
 		defer stderrWG.Done()
-		_, _ = io.Copy(&stderrBuf, stderr)
+		if args.OnStderr == nil {
+			_, _ = io.Copy(&stderrBuf, stderr)
+			return
+		}
+		forwardStderrLines(stderr, &stderrBuf, args.OnStderr)
 	}()
 
 	scanner := bufio.NewScanner(stdout)
 	const maxLineSize = 4 * 1024 * 1024
 	buf := make([]byte, 64*1024)
 	scanner.Buffer(buf, maxLineSize)
+	var unterminated bool
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		unterminated = atEOF && token != nil && bytes.IndexByte(data[:advance], '\n') < 0
+		return advance, token, err
+	})
 
 	readErr := func() error {
 		for scanner.Scan() {
+			if unterminated {
+				// The output ended without a trailing newline. After a clean exit the last
+				// line is complete and is still delivered; when the process was killed
+				// because ctx was done, it was most likely cut off mid-write and is dropped,
+				// as is a blank remainder.
+				if ctx.Err() != nil || len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+					break
+				}
+			}
 			line := append([]byte(nil), scanner.Bytes()...) // copy to avoid reuse
 			if err := handleLine(line); err != nil {
 				if cmd.Process != nil {
@@ -157,7 +367,7 @@ func (r *Runner) Run(ctx context.Context, args Args, handleLine func([]byte) err
 			return ctxErr
 		}
 		if exitErr, ok := waitErr.(*exec.ExitError); ok {
-			return fmt.Errorf("codex exec failed with code %d: %s", exitErr.ExitCode(), stderrBuf.String())
+			return &ExecError{ExitCode: exitErr.ExitCode(), Stderr: stderrBuf.String(), Args: commandArgs}
 		}
 		return fmt.Errorf("codex exec failed: %w", waitErr)
 	}
@@ -169,6 +379,31 @@ func (r *Runner) Run(ctx context.Context, args Args, handleLine func([]byte) err
 	return nil
 }
 
+// forwardStderrLines copies stderr into buf verbatim and hands each line to onLine.
+func forwardStderrLines(stderr io.Reader, buf *bytes.Buffer, onLine func([]byte)) {
+	reader := bufio.NewReader(stderr)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			buf.Write(line)
+			onLine(bytes.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// EventFlushIntervalKey is the CLI config key controlling how long `codex exec` may buffer
+// experimental-json events before flushing them to stdout, in milliseconds.
+const EventFlushIntervalKey = "exec.json_flush_interval_ms"
+
+// CommandArgs returns the arguments Run passes to the codex binary for args, without starting
+// a process.
+func CommandArgs(args Args) []string {
+	return buildCommandArgs(args)
+}
+
 func buildCommandArgs(args Args) []string {
 	commandArgs := []string{"exec", "--experimental-json"}
 
@@ -190,6 +425,15 @@ func buildCommandArgs(args Args) []string {
 			commandArgs = append(commandArgs, "-c", key+"="+fmt.Sprint(value))
 		}
 	}
+	if args.EventFlushInterval > 0 {
+		if _, overridden := args.ConfigOverrides[EventFlushIntervalKey]; !overridden {
+			ms := (args.EventFlushInterval + time.Millisecond - 1) / time.Millisecond
+			commandArgs = append(commandArgs, "-c", fmt.Sprintf("%s=%d", EventFlushIntervalKey, ms))
+		}
+	}
+	if args.ThreadID != "" && args.ResumeContextFile != "" {
+		commandArgs = append(commandArgs, "-c", "experimental_resume="+args.ResumeContextFile)
+	}
 
 	if args.Model != "" {
 		commandArgs = append(commandArgs, "--model", args.Model)
@@ -211,34 +455,70 @@ func buildCommandArgs(args Args) []string {
 			commandArgs = append(commandArgs, "--image", image)
 		}
 	}
+	commandArgs = append(commandArgs, args.ExtraArgs...)
 	if args.ThreadID != "" {
-		commandArgs = append(commandArgs, "resume", args.ThreadID)
+		if args.ResumeFlag {
+			commandArgs = append(commandArgs, "--resume", args.ThreadID)
+		} else {
+			commandArgs = append(commandArgs, "resume", args.ThreadID)
+		}
 	}
 	return commandArgs
 }
 
-func buildEnv(baseURL, apiKey string) []string {
+// buildEnv merges the inherited environment, the SDK-managed variables and extra, in that
+// order of increasing precedence, so an explicit extra entry overrides a managed key.
+func buildEnv(args Args) ([]string, error) {
+	for key := range args.ExtraEnv {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return nil, fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+
 	envMap := make(map[string]string)
 	for _, kv := range os.Environ() {
 		if i := indexByte(kv, '='); i >= 0 {
 			envMap[kv[:i]] = kv[i+1:]
 		}
 	}
-	if _, ok := envMap[internalOriginatorEnv]; !ok {
+	if _, ok := envMap[internalOriginatorEnv]; !ok && !args.DisableOriginator {
 		envMap[internalOriginatorEnv] = goSDKOriginator
 	}
-	if baseURL != "" {
-		envMap["OPENAI_BASE_URL"] = baseURL
+	if args.BaseURL != "" {
+		envMap["OPENAI_BASE_URL"] = args.BaseURL
 	}
-	if apiKey != "" {
-		envMap["CODEX_API_KEY"] = apiKey
+	if args.PreferLogin && hasStoredLogin(envMap, args.ExtraEnv) {
+		delete(envMap, apiKeyEnv)
+	} else if args.APIKey != "" {
+		envMap[apiKeyEnv] = args.APIKey
+	}
+	for key, value := range args.ExtraEnv {
+		envMap[key] = value
 	}
 
 	env := make([]string, 0, len(envMap))
 	for k, v := range envMap {
 		env = append(env, k+"="+v)
 	}
-	return env
+	return env, nil
+}
+
+// hasStoredLogin reports whether the CLI has a stored login: an auth.json file in CODEX_HOME
+// (taken from extra, then env) or, when that is unset, in ~/.codex.
+func hasStoredLogin(env, extra map[string]string) bool {
+	home, ok := extra[codexHomeEnv]
+	if !ok {
+		home = env[codexHomeEnv]
+	}
+	if home == "" {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return false
+		}
+		home = filepath.Join(userHome, ".codex")
+	}
+	info, err := os.Stat(filepath.Join(home, "auth.json"))
+	return err == nil && info.Mode().IsRegular()
 }
 
 func indexByte(s string, b byte) int {
@@ -250,19 +530,21 @@ func indexByte(s string, b byte) int {
 	return -1
 }
 
-func findCodexPath(cfg bundleConfig) (string, error) {
-	bundledPath, bundleErr := ensureBundledBinary(cfg)
+// findCodexPath returns the bundled binary, falling back to `codex` on PATH. bundled reports
+// whether the returned path lives in the download cache.
+func findCodexPath(ctx context.Context, cfg bundleConfig) (path string, bundled bool, err error) {
+	bundledPath, bundleErr := ensureBundledBinary(ctx, cfg)
 	if bundleErr == nil {
-		return bundledPath, nil
+		return bundledPath, true, nil
 	}
 	if cfg.requireBundledBinary() {
-		return "", fmt.Errorf("ensure bundled codex binary: %w", bundleErr)
+		return "", false, fmt.Errorf("ensure bundled codex binary: %w", bundleErr)
 	}
 
-	path, err := exec.LookPath("codex")
+	path, err = exec.LookPath("codex")
 	if err == nil {
-		return path, nil
+		return path, false, nil
 	}
 
-	return "", fmt.Errorf("unable to discover codex binary: bundle error: %v; PATH lookup error: %w", bundleErr, err)
+	return "", false, fmt.Errorf("unable to discover codex binary: bundle error: %v; PATH lookup error: %w", bundleErr, err)
 }
@@ -1,8 +1,18 @@
 package codexexec
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestBuildCommandArgsConfigOverridesWithoutProfile(t *testing.T) {
@@ -35,6 +45,133 @@ func TestBuildCommandArgsConfigOverridesWithoutProfile(t *testing.T) {
 	}
 }
 
+func TestBuildCommandArgsPlacesExtraArgsBeforeResume(t *testing.T) {
+	commandArgs := buildCommandArgs(Args{
+		Model:     "gpt-test",
+		ThreadID:  "thread_1",
+		ExtraArgs: []string{"--new-flag", "value"},
+	})
+
+	expected := []string{"exec", "--experimental-json", "--model", "gpt-test", "--new-flag", "value", "resume", "thread_1"}
+	if !slices.Equal(commandArgs, expected) {
+		t.Fatalf("expected %v, got %v", expected, commandArgs)
+	}
+}
+
+func TestBuildCommandArgsEventFlushInterval(t *testing.T) {
+	commandArgs := buildCommandArgs(Args{EventFlushInterval: 2500 * time.Microsecond})
+	expected := []string{"exec", "--experimental-json", "-c", EventFlushIntervalKey + "=3"}
+	if !slices.Equal(commandArgs, expected) {
+		t.Fatalf("expected %v, got %v", expected, commandArgs)
+	}
+
+	commandArgs = buildCommandArgs(Args{
+		EventFlushInterval: time.Second,
+		ConfigOverrides:    map[string]any{EventFlushIntervalKey: 5},
+	})
+	expected = []string{"exec", "--experimental-json", "-c", EventFlushIntervalKey + "=5"}
+	if !slices.Equal(commandArgs, expected) {
+		t.Fatalf("expected an explicit override to win, got %v", commandArgs)
+	}
+
+	if commandArgs := buildCommandArgs(Args{}); slices.Contains(commandArgs, "-c") {
+		t.Fatalf("expected no override when unset, got %v", commandArgs)
+	}
+}
+
+func TestBuildEnvMergesExtraEnv(t *testing.T) {
+	t.Setenv("GODEX_TEST_INHERITED", "inherited")
+	t.Setenv("HTTPS_PROXY", "")
+
+	env, err := buildEnv(Args{BaseURL: "https://api.example.com", APIKey: "sk-test", ExtraEnv: map[string]string{
+		"HTTPS_PROXY":   "http://proxy:8080",
+		"CODEX_API_KEY": "sk-explicit",
+	}})
+	if err != nil {
+		t.Fatalf("buildEnv returned error: %v", err)
+	}
+	for _, want := range []string{
+		"GODEX_TEST_INHERITED=inherited",
+		"HTTPS_PROXY=http://proxy:8080",
+		"OPENAI_BASE_URL=https://api.example.com",
+		"CODEX_API_KEY=sk-explicit",
+		internalOriginatorEnv + "=" + goSDKOriginator,
+	} {
+		if !slices.Contains(env, want) {
+			t.Fatalf("expected %q in env %v", want, env)
+		}
+	}
+}
+
+func TestBuildEnvDisableOriginator(t *testing.T) {
+	t.Setenv(internalOriginatorEnv, "")
+	os.Unsetenv(internalOriginatorEnv)
+
+	hasOriginator := func(env []string) bool {
+		return slices.ContainsFunc(env, func(kv string) bool {
+			return strings.HasPrefix(kv, internalOriginatorEnv+"=")
+		})
+	}
+	env, err := buildEnv(Args{})
+	if err != nil {
+		t.Fatalf("buildEnv returned error: %v", err)
+	}
+	if !hasOriginator(env) {
+		t.Fatalf("expected %s by default, got %v", internalOriginatorEnv, env)
+	}
+	env, err = buildEnv(Args{DisableOriginator: true})
+	if err != nil {
+		t.Fatalf("buildEnv returned error: %v", err)
+	}
+	if hasOriginator(env) {
+		t.Fatalf("expected no %s with DisableOriginator, got %v", internalOriginatorEnv, env)
+	}
+}
+
+func TestBuildEnvAuthPriority(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv(codexHomeEnv, home)
+	t.Setenv(apiKeyEnv, "sk-inherited")
+
+	apiKey := func(env []string) string {
+		for _, kv := range env {
+			if value, ok := strings.CutPrefix(kv, apiKeyEnv+"="); ok {
+				return value
+			}
+		}
+		return ""
+	}
+	run := func(preferLogin bool) string {
+		t.Helper()
+		env, err := buildEnv(Args{APIKey: "sk-explicit", PreferLogin: preferLogin})
+		if err != nil {
+			t.Fatalf("buildEnv returned error: %v", err)
+		}
+		return apiKey(env)
+	}
+
+	if got := run(true); got != "sk-explicit" {
+		t.Fatalf("expected APIKey without a stored login, got %q", got)
+	}
+	if err := os.WriteFile(filepath.Join(home, "auth.json"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if got := run(false); got != "sk-explicit" {
+		t.Fatalf("expected APIKey to win by default, got %q", got)
+	}
+	if got := run(true); got != "" {
+		t.Fatalf("expected no %s when the stored login is preferred, got %q", apiKeyEnv, got)
+	}
+}
+
+func TestBuildEnvRejectsInvalidNames(t *testing.T) {
+	for _, key := range []string{"", "A=B"} {
+		if _, err := buildEnv(Args{ExtraEnv: map[string]string{key: "value"}}); err == nil {
+			t.Fatalf("expected an error for environment variable name %q", key)
+		}
+	}
+}
+
 func TestBuildCommandArgsConfigOverridesWithProfile(t *testing.T) {
 	commandArgs := buildCommandArgs(Args{
 		ConfigOverrides: map[string]any{
@@ -117,3 +254,302 @@ func TestBuildCommandArgsConfigOverridesDeterministicOrder(t *testing.T) {
 		t.Fatalf("expected configs %v, got %v", want, expected)
 	}
 }
+
+func TestBuildCommandArgsResumeContextFileOnlyWhenResuming(t *testing.T) {
+	const want = "experimental_resume=/tmp/session.jsonl"
+
+	fresh := buildCommandArgs(Args{ResumeContextFile: "/tmp/session.jsonl"})
+	if slices.Contains(fresh, want) {
+		t.Fatalf("expected no resume context flag for new thread, got %v", fresh)
+	}
+
+	resumed := buildCommandArgs(Args{ThreadID: "thread_1", ResumeContextFile: "/tmp/session.jsonl"})
+	idx := slices.Index(resumed, want)
+	if idx < 1 || resumed[idx-1] != "-c" {
+		t.Fatalf("expected -c %s in %v", want, resumed)
+	}
+	if resumeIdx := slices.Index(resumed, "resume"); resumeIdx < idx {
+		t.Fatalf("expected config flag before resume subcommand, got %v", resumed)
+	}
+}
+
+func TestRunnerRefreshRedownloadsBundledBinary(t *testing.T) {
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	var downloads int
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		downloads++
+		return os.WriteFile(destPath, []byte("binary"), 0o700)
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	runner, err := New(RunnerOptions{CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := runner.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if downloads != 2 {
+		t.Fatalf("expected initial download plus refresh, got %d downloads", downloads)
+	}
+}
+
+func TestRunnerRefreshRejectsPathOverride(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "codex")
+	if err := os.WriteFile(binary, []byte("binary"), 0o700); err != nil {
+		t.Fatalf("write binary: %v", err)
+	}
+
+	runner, err := New(RunnerOptions{PathOverride: binary})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if err := runner.Refresh(context.Background()); err == nil {
+		t.Fatal("expected Refresh to fail for overridden path")
+	}
+}
+
+func TestBuildCommandArgsResumeStyles(t *testing.T) {
+	positional := buildCommandArgs(Args{ThreadID: "thread_1", Model: "gpt-test"})
+	if got := positional[len(positional)-2:]; !slices.Equal(got, []string{"resume", "thread_1"}) {
+		t.Fatalf("expected trailing resume subcommand, got %v", positional)
+	}
+	if slices.Contains(positional, "--resume") {
+		t.Fatalf("expected no --resume flag by default, got %v", positional)
+	}
+
+	flag := buildCommandArgs(Args{ThreadID: "thread_1", Model: "gpt-test", ResumeFlag: true})
+	idx := slices.Index(flag, "--resume")
+	if idx < 0 || idx+1 >= len(flag) || flag[idx+1] != "thread_1" {
+		t.Fatalf("expected --resume thread_1, got %v", flag)
+	}
+	if slices.Contains(flag, "resume") {
+		t.Fatalf("expected no resume subcommand in flag style, got %v", flag)
+	}
+}
+
+// TestHelperProcess is not a real test; it stands in for the codex binary when
+// useHelperProcess swaps commandFactory.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GODEX_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	switch os.Getenv("GODEX_HELPER_MODE") {
+	case "exit-code":
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		fmt.Fprint(os.Stderr, "authentication failed")
+		os.Exit(3)
+	case "stderr-lines":
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		fmt.Fprint(os.Stderr, "loading config\nwarning: model deprecated\nfatal: quota exceeded")
+		os.Exit(1)
+	case "close-stdin":
+		_ = os.Stdin.Close()
+		os.Exit(0)
+	case "echo-lines":
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		fmt.Println(`{"type":"thread.started","thread_id":"thread_1"}`)
+		fmt.Println(`{"type":"turn.started"}`)
+	case "no-trailing-newline":
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		fmt.Println(`{"type":"thread.started","thread_id":"thread_1"}`)
+		fmt.Print(`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`)
+	case "partial-line-hang":
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		fmt.Println(`{"type":"thread.started","thread_id":"thread_1"}`)
+		fmt.Print(`{"type":"turn.comp`)
+		time.Sleep(time.Minute)
+	}
+	os.Exit(0)
+}
+
+func useHelperProcess(t *testing.T, mode string) *Runner {
+	t.Helper()
+
+	t.Setenv("GODEX_WANT_HELPER_PROCESS", "1")
+	t.Setenv("GODEX_HELPER_MODE", mode)
+
+	original := commandFactory
+	commandFactory = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, os.Args[0], "-test.run=^TestHelperProcess$")
+	}
+	t.Cleanup(func() { commandFactory = original })
+
+	return &Runner{executablePath: "codex"}
+}
+
+func TestRunnerRunStreamsLines(t *testing.T) {
+	runner := useHelperProcess(t, "echo-lines")
+
+	var lines []string
+	err := runner.Run(context.Background(), Args{Input: "hello"}, func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %v", len(lines), lines)
+	}
+}
+
+func TestRunnerRunDeliversFinalLineWithoutNewline(t *testing.T) {
+	runner := useHelperProcess(t, "no-trailing-newline")
+
+	var lines []string
+	err := runner.Run(context.Background(), Args{Input: "hello"}, func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(lines) != 2 || !strings.Contains(lines[1], `"turn.completed"`) {
+		t.Fatalf("expected the unterminated turn.completed line to be delivered, got %v", lines)
+	}
+}
+
+func TestRunnerRunDropsPartialLineOnCancel(t *testing.T) {
+	runner := useHelperProcess(t, "partial-line-hang")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var lines []string
+	err := runner.Run(ctx, Args{Input: "hello"}, func(line []byte) error {
+		lines = append(lines, string(line))
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("expected only the complete line, got %v", lines)
+	}
+}
+
+func TestRunnerRunReportsExitCodeAndStderr(t *testing.T) {
+	runner := useHelperProcess(t, "exit-code")
+
+	err := runner.Run(context.Background(), Args{Input: "hello"}, func([]byte) error { return nil })
+	if err == nil {
+		t.Fatal("expected error for non-zero exit")
+	}
+	if !strings.Contains(err.Error(), "code 3") || !strings.Contains(err.Error(), "authentication failed") {
+		t.Fatalf("expected exit code and stderr in error, got %v", err)
+	}
+	var execErr *ExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("expected ExecError, got %T", err)
+	}
+	if execErr.ExitCode != 3 || execErr.Stderr != "authentication failed" {
+		t.Fatalf("unexpected exec error fields: %+v", execErr)
+	}
+	if !slices.Equal(execErr.Args[:2], []string{"exec", "--experimental-json"}) {
+		t.Fatalf("unexpected exec error args: %v", execErr.Args)
+	}
+}
+
+func TestRunnerRunReportsStdinWriteFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("broken pipe semantics differ on windows")
+	}
+	runner := useHelperProcess(t, "close-stdin")
+
+	// The prompt exceeds the pipe buffer so the write fails once the child exits.
+	input := strings.Repeat("x", 4<<20)
+	err := runner.Run(context.Background(), Args{Input: input}, func([]byte) error { return nil })
+	if err == nil {
+		t.Fatal("expected error when the process does not read stdin")
+	}
+	if !strings.Contains(err.Error(), "writing prompt to codex stdin") {
+		t.Fatalf("expected stdin write error, got %v", err)
+	}
+}
+
+func TestRunnerRunPropagatesHandlerError(t *testing.T) {
+	runner := useHelperProcess(t, "echo-lines")
+
+	sentinel := errors.New("stop")
+	err := runner.Run(context.Background(), Args{Input: "hello"}, func([]byte) error { return sentinel })
+	if !errors.Is(err, sentinel) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+}
+
+func TestRunnerRunForwardsStderrLines(t *testing.T) {
+	runner := useHelperProcess(t, "stderr-lines")
+
+	var lines []string
+	err := runner.Run(context.Background(), Args{
+		Input:    "hello",
+		OnStderr: func(line []byte) { lines = append(lines, string(line)) },
+	}, func([]byte) error { return nil })
+
+	expected := []string{"loading config", "warning: model deprecated", "fatal: quota exceeded"}
+	if !slices.Equal(lines, expected) {
+		t.Fatalf("expected stderr lines %v, got %v", expected, lines)
+	}
+
+	var execErr *ExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("expected ExecError, got %v", err)
+	}
+	if execErr.Stderr != "loading config\nwarning: model deprecated\nfatal: quota exceeded" {
+		t.Fatalf("expected full stderr to be captured, got %q", execErr.Stderr)
+	}
+}
+
+func TestNewTimesOutStalledDownload(t *testing.T) {
+	t.Setenv("GODEX_CLI_CACHE", t.TempDir())
+
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	originalDownloader := downloadBinaryFunc
+	downloadBinaryFunc = func(ctx context.Context, cfg bundleConfig, info targetInfo, release, destPath string) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	t.Cleanup(func() { downloadBinaryFunc = originalDownloader })
+
+	start := time.Now()
+	_, err := New(RunnerOptions{ReleaseTag: "rust-v-test", StartupTimeout: 50 * time.Millisecond})
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected startup timeout error, got %v", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("expected New to return within the startup bound, took %s", elapsed)
+	}
+}
+
+func TestRunnerRunReportsStdinBytes(t *testing.T) {
+	runner := useHelperProcess(t, "echo-lines")
+
+	var calls [][]byte
+	err := runner.Run(context.Background(), Args{
+		Input:   "explain the diff",
+		OnStdin: func(data []byte) { calls = append(calls, data) },
+	}, func([]byte) error { return nil })
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("expected OnStdin to fire once, got %d calls", len(calls))
+	}
+	if string(calls[0]) != "explain the diff" {
+		t.Fatalf("unexpected stdin bytes %q", calls[0])
+	}
+}
@@ -0,0 +1,145 @@
+package codexexec
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrSignatureInvalid reports that a downloaded release asset did not match its detached
+// ed25519 signature.
+var ErrSignatureInvalid = errors.New("codex bundle signature invalid")
+
+// maxSignatureSize bounds the size of a detached signature asset.
+const maxSignatureSize = 4 * 1024
+
+// signatureKey decodes the configured ed25519 public key. It returns nil when no key is set.
+func (cfg bundleConfig) signatureKey() (ed25519.PublicKey, error) {
+	value := strings.TrimSpace(cfg.signaturePublicKey)
+	if value == "" {
+		return nil, nil
+	}
+	key, err := decodeKeyMaterial(value, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature public key: %w", err)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// decodeKeyMaterial accepts raw, hex or base64 encoded bytes of the given size.
+func decodeKeyMaterial(value string, size int) ([]byte, error) {
+	if len(value) == size {
+		return []byte(value), nil
+	}
+	if decoded, err := hex.DecodeString(value); err == nil && len(decoded) == size {
+		return decoded, nil
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(value); err == nil && len(decoded) == size {
+		return decoded, nil
+	}
+	return nil, fmt.Errorf("expected %d bytes encoded as hex or base64", size)
+}
+
+// fetchSignature downloads the detached signature published as "<asset>.sig".
+func fetchSignature(ctx context.Context, cfg bundleConfig, info targetInfo, release string) ([]byte, error) {
+	sigAsset := info.assetName + ".sig"
+	url, err := cfg.assetURL(release, sigAsset)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create signature request: %w", err)
+	}
+	resp, err := cfg.downloadClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download signature: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download signature asset %s from %s: %w", sigAsset, url, &downloadStatusError{StatusCode: resp.StatusCode})
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSignatureSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read signature: %w", err)
+	}
+	if len(data) > maxSignatureSize {
+		return nil, fmt.Errorf("%w: signature asset %s exceeds %d bytes", ErrSignatureInvalid, sigAsset, maxSignatureSize)
+	}
+	if len(data) != ed25519.SignatureSize {
+		data = bytes.TrimSpace(data)
+	}
+	sig, err := decodeKeyMaterial(string(data), ed25519.SignatureSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature asset %s: %v", ErrSignatureInvalid, sigAsset, err)
+	}
+	return sig, nil
+}
+
+// verifySignature checks archive against the detached signature for the release asset.
+func verifySignature(ctx context.Context, cfg bundleConfig, key ed25519.PublicKey, info targetInfo, release string, archive []byte) error {
+	sig, err := fetchSignature(ctx, cfg, info, release)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, archive, sig) {
+		return fmt.Errorf("%w: %s", ErrSignatureInvalid, info.assetName)
+	}
+	return nil
+}
+
+// signatureRecordSuffix names the file stored next to a cached binary that was extracted from
+// a signature-verified asset.
+const signatureRecordSuffix = ".sig-ok"
+
+// writeSignatureRecord records that the binary at destPath was extracted from the archive with
+// archiveDigest after it verified against key. Cache hits require a matching record, so a
+// binary cached without verification, or replaced afterwards, is downloaded again.
+func writeSignatureRecord(destPath string, key ed25519.PublicKey, archiveDigest string) error {
+	binaryDigest, err := fileSHA256(destPath)
+	if err != nil {
+		return err
+	}
+	record := fmt.Sprintf("key %s\narchive %s\nbinary %s\n", hex.EncodeToString(key), archiveDigest, binaryDigest)
+	if err := os.WriteFile(destPath+signatureRecordSuffix, []byte(record), 0o644); err != nil {
+		return CheckDiskFull(destPath, fmt.Errorf("write signature record: %w", err))
+	}
+	return nil
+}
+
+// hasSignatureRecord reports whether the binary at destPath has a verification record for key
+// that still matches the binary's contents.
+func hasSignatureRecord(destPath string, key ed25519.PublicKey) (bool, error) {
+	data, err := os.ReadFile(destPath + signatureRecordSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read signature record: %w", err)
+	}
+
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, value, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			fields[name] = value
+		}
+	}
+	if fields["key"] != hex.EncodeToString(key) || fields["archive"] == "" {
+		return false, nil
+	}
+	binaryDigest, err := fileSHA256(destPath)
+	if err != nil {
+		return false, err
+	}
+	return fields["binary"] == binaryDigest, nil
+}
@@ -0,0 +1,176 @@
+package codexexec
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func newSignedAssetServer(t *testing.T, assets map[string][]byte) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := assets[filepath.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func signedDownloadConfig(server *httptest.Server, publicKey ed25519.PublicKey) bundleConfig {
+	return bundleConfig{
+		signaturePublicKey: hex.EncodeToString(publicKey),
+		assetURLFunc: func(release, assetName string) string {
+			return server.URL + "/" + release + "/" + assetName
+		},
+	}
+}
+
+func TestDownloadBinaryFromReleaseVerifiesSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	info, _ := detectTarget("linux", "amd64")
+	archive := tarGzArchive(t, archiveEntry{name: info.binaryName, contents: "signed", mode: 0o755})
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, archive))
+
+	server := newSignedAssetServer(t, map[string][]byte{
+		info.assetName:          archive,
+		info.assetName + ".sig": []byte(signature + "\n"),
+	})
+
+	destPath := filepath.Join(t.TempDir(), info.exeName)
+	if err := downloadBinaryFromRelease(context.Background(), signedDownloadConfig(server, publicKey), info, "rust-v1", destPath); err != nil {
+		t.Fatalf("downloadBinaryFromRelease returned error: %v", err)
+	}
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("read binary: %v", err)
+	}
+	if string(data) != "signed" {
+		t.Fatalf("unexpected binary contents %q", data)
+	}
+}
+
+func TestDownloadBinaryFromReleaseRejectsTamperedAsset(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	info, _ := detectTarget("linux", "amd64")
+	original := tarGzArchive(t, archiveEntry{name: info.binaryName, contents: "signed", mode: 0o755})
+	tampered := tarGzArchive(t, archiveEntry{name: info.binaryName, contents: "malicious", mode: 0o755})
+
+	server := newSignedAssetServer(t, map[string][]byte{
+		info.assetName:          tampered,
+		info.assetName + ".sig": ed25519.Sign(privateKey, original),
+	})
+
+	destPath := filepath.Join(t.TempDir(), info.exeName)
+	err = downloadBinaryFromRelease(context.Background(), signedDownloadConfig(server, publicKey), info, "rust-v1", destPath)
+	if !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatalf("expected ErrSignatureInvalid, got %v", err)
+	}
+	if _, statErr := os.Stat(destPath); !errors.Is(statErr, os.ErrNotExist) {
+		t.Fatalf("expected tampered binary not to be written, got %v", statErr)
+	}
+}
+
+func TestDownloadBinaryFromReleaseFailsWithoutSignatureAsset(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	info, _ := detectTarget("linux", "amd64")
+	archive := tarGzArchive(t, archiveEntry{name: info.binaryName, contents: "unsigned", mode: 0o755})
+
+	server := newSignedAssetServer(t, map[string][]byte{info.assetName: archive})
+
+	destPath := filepath.Join(t.TempDir(), info.exeName)
+	err = downloadBinaryFromRelease(context.Background(), signedDownloadConfig(server, publicKey), info, "rust-v1", destPath)
+	var statusErr *downloadStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected missing signature error, got %v", err)
+	}
+	if _, statErr := os.Stat(destPath); !errors.Is(statErr, os.ErrNotExist) {
+		t.Fatalf("expected unsigned binary not to be written, got %v", statErr)
+	}
+}
+
+func TestEnsureBundledBinaryDoesNotTrustUnverifiedCache(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	originalGOOS, originalGOARCH := runtimeGOOS, runtimeGOARCH
+	runtimeGOOS, runtimeGOARCH = "linux", "amd64"
+	t.Cleanup(func() {
+		runtimeGOOS, runtimeGOARCH = originalGOOS, originalGOARCH
+	})
+
+	info, _ := detectTarget("linux", "amd64")
+	archive := tarGzArchive(t, archiveEntry{name: info.binaryName, contents: "signed", mode: 0o755})
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch filepath.Base(r.URL.Path) {
+		case info.assetName:
+			downloads.Add(1)
+			_, _ = w.Write(archive)
+		case info.assetName + ".sig":
+			_, _ = w.Write(ed25519.Sign(privateKey, archive))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := signedDownloadConfig(server, publicKey)
+	cfg.cacheDir = t.TempDir()
+	destPath := filepath.Join(cfg.cacheDir, cfg.releaseTagName(), info.triple, info.exeName)
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(destPath, []byte("planted"), 0o700); err != nil {
+		t.Fatalf("seed cache: %v", err)
+	}
+
+	readBinary := func() string {
+		t.Helper()
+		path, err := ensureBundledBinary(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("ensureBundledBinary returned error: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read binary: %v", err)
+		}
+		return string(data)
+	}
+
+	if got := readBinary(); got != "signed" || downloads.Load() != 1 {
+		t.Fatalf("expected the unverified cache to be replaced, got %q after %d downloads", got, downloads.Load())
+	}
+	if got := readBinary(); got != "signed" || downloads.Load() != 1 {
+		t.Fatalf("expected the verified cache to be reused, got %q after %d downloads", got, downloads.Load())
+	}
+
+	if err := os.WriteFile(destPath, []byte("swapped"), 0o700); err != nil {
+		t.Fatalf("swap binary: %v", err)
+	}
+	if got := readBinary(); got != "signed" || downloads.Load() != 2 {
+		t.Fatalf("expected a swapped binary to be downloaded again, got %q after %d downloads", got, downloads.Load())
+	}
+}
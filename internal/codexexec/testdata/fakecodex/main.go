@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +12,34 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "--version" {
+		if counter := os.Getenv("CODEX_FAKE_VERSION_COUNTER"); counter != "" {
+			f, err := os.OpenFile(counter, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err == nil {
+				_, _ = f.WriteString("x")
+				_ = f.Close()
+			}
+		}
+		fmt.Println(os.Getenv("CODEX_FAKE_VERSION"))
+		return
+	}
+
+	if code := os.Getenv("CODEX_FAKE_EXIT_CODE"); code != "" {
+		exitCode, err := strconv.Atoi(code)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid CODEX_FAKE_EXIT_CODE %q\n", code)
+			os.Exit(2)
+		}
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		fmt.Fprint(os.Stderr, os.Getenv("CODEX_FAKE_STDERR"))
+		os.Exit(exitCode)
+	}
+
+	if os.Getenv("CODEX_FAKE_ECHO_STDIN") != "" {
+		echoStdin()
+		return
+	}
+
 	pidFile := os.Getenv("CODEX_FAKE_PID_FILE")
 	if pidFile == "" {
 		fmt.Fprintln(os.Stderr, "CODEX_FAKE_PID_FILE not set")
@@ -19,15 +49,43 @@ func main() {
 	// Drain stdin to avoid the parent process blocking while sending a prompt.
 	go io.Copy(io.Discard, os.Stdin)
 
-	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0o644); err != nil {
-		fmt.Fprintf(os.Stderr, "write pid file: %v\n", err)
-		os.Exit(3)
-	}
-
 	// Block until a termination signal arrives. If the parent issues SIGKILL the
 	// process will exit immediately without delivering a signal on sigCh, which
 	// is fine for the integration test.
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "write pid file: %v\n", err)
+		os.Exit(3)
+	}
+
+	if signalFile := os.Getenv("CODEX_FAKE_SIGNAL_FILE"); signalFile != "" {
+		// Behave like a CLI that ends the turn cleanly when interrupted.
+		fmt.Println(`{"type":"thread.started","thread_id":"thread_interrupt"}`)
+		sig := <-sigCh
+		_ = os.WriteFile(signalFile, []byte(sig.String()), 0o644)
+		fmt.Println(`{"type":"turn.failed","error":{"message":"turn interrupted"}}`)
+		return
+	}
 	<-sigCh
 }
+
+// echoStdin emits an agent message for every stdin line and completes the turn on "stop" or
+// EOF, mimicking a CLI that accepts steering messages mid-turn.
+func echoStdin() {
+	fmt.Println(`{"type":"thread.started","thread_id":"thread_echo"}`)
+	scanner := bufio.NewScanner(os.Stdin)
+	for i := 0; scanner.Scan(); i++ {
+		line := scanner.Text()
+		item, _ := json.Marshal(map[string]any{
+			"type": "item.completed",
+			"item": map[string]any{"id": fmt.Sprintf("item_%d", i), "type": "agent_message", "text": "echo: " + line},
+		})
+		fmt.Println(string(item))
+		if line == "stop" {
+			break
+		}
+	}
+	fmt.Println(`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`)
+}
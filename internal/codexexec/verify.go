@@ -0,0 +1,76 @@
+package codexexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrBinaryNotCached reports that no Codex binary is cached for the current platform and
+// release.
+var ErrBinaryNotCached = errors.New("codex binary is not cached")
+
+// VerifyCachedBinary checks that the cached Codex binary for the current platform exists and
+// matches the configured checksum, using CacheDir, ReleaseTag, ChecksumHex and
+// SignaturePublicKey from options. It never downloads or contacts the network: a "latest"
+// release resolves to the newest release already in the cache.
+func VerifyCachedBinary(options RunnerOptions) error {
+	cfg := bundleConfig{
+		cacheDir:           options.CacheDir,
+		releaseTag:         options.ReleaseTag,
+		checksumHex:        options.ChecksumHex,
+		signaturePublicKey: options.SignaturePublicKey,
+	}
+	info, ok := detectTarget(runtimeGOOS, runtimeGOARCH)
+	if !ok {
+		return fmt.Errorf("unsupported platform: %s/%s", runtimeGOOS, runtimeGOARCH)
+	}
+	checksumHex, err := cfg.checksumValue()
+	if err != nil {
+		return fmt.Errorf("resolve checksum: %w", err)
+	}
+	if checksumHex == "" {
+		return errors.New("verify cached binary: no checksum configured (set ChecksumHex or GODEX_CLI_CHECKSUM)")
+	}
+	signatureKey, err := cfg.signatureKey()
+	if err != nil {
+		return err
+	}
+	// Resolve the directory the way downloads do, so a read-only primary is skipped.
+	cacheDir, err := cfg.writableCacheDir(context.Background())
+	if err != nil {
+		return err
+	}
+
+	release := cfg.releaseTagName()
+	if strings.EqualFold(release, latestReleaseTag) {
+		release = newestCachedRelease(cacheDir, info)
+		if release == "" {
+			return fmt.Errorf("%w: no cached release for %s under %s", ErrBinaryNotCached, info.triple, cacheDir)
+		}
+	}
+
+	destPath := filepath.Join(cacheDir, release, info.triple, info.exeName)
+	if err := ensureBinaryState(destPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrBinaryNotCached, destPath)
+		}
+		return fmt.Errorf("stat bundled binary: %w", err)
+	}
+	if signatureKey != nil {
+		verified, err := hasSignatureRecord(destPath, signatureKey)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			return fmt.Errorf("%w: %s has no record of a verified signature", ErrSignatureInvalid, destPath)
+		}
+	}
+	if err := verifyChecksum(destPath, checksumHex); err != nil {
+		return fmt.Errorf("verify cached binary %s: %w", destPath, err)
+	}
+	return nil
+}
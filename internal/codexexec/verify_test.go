@@ -0,0 +1,81 @@
+package codexexec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func seedCachedBinary(t *testing.T, cacheDir, release string, contents []byte) string {
+	t.Helper()
+	info, ok := detectTarget(runtimeGOOS, runtimeGOARCH)
+	if !ok {
+		t.Skipf("platform %s/%s is not supported", runtimeGOOS, runtimeGOARCH)
+	}
+	dir := filepath.Join(cacheDir, release, info.triple)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("create cache dir: %v", err)
+	}
+	path := filepath.Join(dir, info.exeName)
+	if err := os.WriteFile(path, contents, 0o755); err != nil {
+		t.Fatalf("write cached binary: %v", err)
+	}
+	return path
+}
+
+func TestVerifyCachedBinary(t *testing.T) {
+	t.Setenv("GODEX_CLI_CHECKSUM", "")
+	t.Setenv("GODEX_CLI_RELEASE_TAG", "")
+
+	contents := []byte("cached codex binary")
+	sum := sha256.Sum256(contents)
+	checksum := hex.EncodeToString(sum[:])
+
+	t.Run("matching", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		seedCachedBinary(t, cacheDir, "rust-v1.2.3", contents)
+		err := VerifyCachedBinary(RunnerOptions{CacheDir: cacheDir, ReleaseTag: "rust-v1.2.3", ChecksumHex: checksum})
+		if err != nil {
+			t.Fatalf("VerifyCachedBinary returned error: %v", err)
+		}
+	})
+
+	t.Run("latest resolves from cache", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		seedCachedBinary(t, cacheDir, "rust-v1.2.3", contents)
+		err := VerifyCachedBinary(RunnerOptions{CacheDir: cacheDir, ReleaseTag: latestReleaseTag, ChecksumHex: checksum})
+		if err != nil {
+			t.Fatalf("VerifyCachedBinary returned error: %v", err)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		err := VerifyCachedBinary(RunnerOptions{CacheDir: t.TempDir(), ReleaseTag: "rust-v1.2.3", ChecksumHex: checksum})
+		if !errors.Is(err, ErrBinaryNotCached) {
+			t.Fatalf("expected ErrBinaryNotCached, got %v", err)
+		}
+	})
+
+	t.Run("mismatched", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		path := seedCachedBinary(t, cacheDir, "rust-v1.2.3", []byte("tampered"))
+		err := VerifyCachedBinary(RunnerOptions{CacheDir: cacheDir, ReleaseTag: "rust-v1.2.3", ChecksumHex: checksum})
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+		}
+		if _, statErr := os.Stat(path); statErr != nil {
+			t.Fatalf("verification must not remove the cached binary: %v", statErr)
+		}
+	})
+
+	t.Run("no checksum configured", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		seedCachedBinary(t, cacheDir, "rust-v1.2.3", contents)
+		if err := VerifyCachedBinary(RunnerOptions{CacheDir: cacheDir, ReleaseTag: "rust-v1.2.3"}); err == nil {
+			t.Fatal("expected an error without a configured checksum")
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package godex
 
+import "encoding/json"
+
 // CommandExecutionStatus represents the lifecycle stage of a command started by the agent.
 type CommandExecutionStatus string
 
@@ -17,6 +19,31 @@ type CommandExecutionItem struct {
 	AggregatedOutput string                 `json:"aggregated_output"`
 	ExitCode         *int                   `json:"exit_code,omitempty"`
 	Status           CommandExecutionStatus `json:"status"`
+	// Stdout and Stderr hold the command's output streams when the CLI reports them
+	// separately; they are empty otherwise. See OutputStreams for a best-effort split.
+	Stdout string `json:"stdout,omitempty"`
+	Stderr string `json:"stderr,omitempty"`
+	// DurationMs is the command's wall-clock run time in milliseconds, or nil when the CLI
+	// omits it.
+	DurationMs *int64 `json:"duration_ms,omitempty"`
+}
+
+// Failed reports whether the command failed or exited with a non-zero code.
+func (i CommandExecutionItem) Failed() bool {
+	return i.Status == CommandExecutionStatusFailed || (i.ExitCode != nil && *i.ExitCode != 0)
+}
+
+// OutputStreams returns the command's stdout and stderr. When the CLI reported only
+// AggregatedOutput, it is attributed to stderr for failed commands (where it usually carries
+// the error) and to stdout otherwise.
+func (i CommandExecutionItem) OutputStreams() (stdout, stderr string) {
+	if i.Stdout != "" || i.Stderr != "" {
+		return i.Stdout, i.Stderr
+	}
+	if i.Failed() {
+		return "", i.AggregatedOutput
+	}
+	return i.AggregatedOutput, ""
 }
 
 // PatchChangeKind indicates how a file changed.
@@ -32,6 +59,11 @@ const (
 type FileUpdateChange struct {
 	Path string          `json:"path"`
 	Kind PatchChangeKind `json:"kind"`
+	// OldContent and NewContent hold the file contents before and after the change when the
+	// CLI includes them. They are nil when omitted; an added file has no OldContent and a
+	// deleted file no NewContent.
+	OldContent *string `json:"old_content,omitempty"`
+	NewContent *string `json:"new_content,omitempty"`
 }
 
 // PatchApplyStatus indicates whether the patch was applied successfully.
@@ -66,6 +98,12 @@ type McpToolCallItem struct {
 	Server string            `json:"server"`
 	Tool   string            `json:"tool"`
 	Status McpToolCallStatus `json:"status"`
+	// Arguments and Result hold the JSON the tool was called with and returned. They are nil
+	// when the CLI omits them, for example while the call is still in progress.
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	// Error describes why a failed call failed. Empty when the CLI omits it.
+	Error string `json:"error,omitempty"`
 }
 
 // AgentMessageItem contains the model's response payload (natural language or structured JSON).
@@ -75,11 +113,24 @@ type AgentMessageItem struct {
 	Text string `json:"text"`
 }
 
+// ReasoningKind distinguishes reasoning summaries from raw reasoning content.
+type ReasoningKind string
+
+const (
+	ReasoningKindSummary ReasoningKind = "summary"
+	ReasoningKindRaw     ReasoningKind = "raw"
+)
+
 // ReasoningItem provides insight into the agent's intermediate reasoning.
 type ReasoningItem struct {
 	ID   string `json:"id"`
 	Type string `json:"type"`
 	Text string `json:"text"`
+	// Kind reports whether Text is a summary or raw reasoning. Empty when the CLI omits it.
+	Kind ReasoningKind `json:"kind,omitempty"`
+	// Summary is the redacted summary some deployments report next to, or instead of, the
+	// full reasoning text. Empty when the CLI omits it.
+	Summary string `json:"summary,omitempty"`
 }
 
 // WebSearchItem denotes a web search performed by the agent.
@@ -87,6 +138,16 @@ type WebSearchItem struct {
 	ID    string `json:"id"`
 	Type  string `json:"type"`
 	Query string `json:"query"`
+	// Results lists the pages the search returned. It is nil until the CLI reports them,
+	// typically on item.completed.
+	Results []WebSearchResult `json:"results,omitempty"`
+}
+
+// WebSearchResult is a single page returned by a web search.
+type WebSearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
 }
 
 // ErrorItem captures non-fatal errors emitted by the agent.
@@ -109,6 +170,31 @@ type TodoListItem struct {
 	Items []TodoItem `json:"items"`
 }
 
+// StructuredOutputItem carries a machine-readable JSON payload the agent emits alongside its
+// prose answer, for turns that produce both.
+type StructuredOutputItem struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// UnknownItem carries an item whose type this version of the SDK does not recognize, such as
+// one introduced by a newer CLI. It keeps the turn running instead of failing the stream.
+type UnknownItem struct {
+	ID   string
+	Type ThreadItemType
+	// Payload is the item's original JSON object.
+	Payload json.RawMessage
+}
+
+// MarshalJSON returns the original payload so transcripts keep the item unchanged.
+func (i UnknownItem) MarshalJSON() ([]byte, error) {
+	if len(i.Payload) == 0 {
+		return json.Marshal(map[string]any{"id": i.ID, "type": i.Type})
+	}
+	return i.Payload, nil
+}
+
 // ThreadItemType enumerates all valid thread item type strings.
 type ThreadItemType string
 
@@ -122,12 +208,14 @@ const (
 	ThreadItemTypeWebSearch        ThreadItemType = "web_search"
 	ThreadItemTypeTodoList         ThreadItemType = "todo_list"
 	ThreadItemTypeError            ThreadItemType = "error"
+	ThreadItemTypeStructuredOutput ThreadItemType = "structured_output"
 )
 
 // ThreadItem is the polymorphic representation of all items that can appear on a thread.
 type ThreadItem interface {
 	threadItem()
 	itemType() ThreadItemType
+	itemID() string
 }
 
 func (AgentMessageItem) threadItem()     {}
@@ -138,6 +226,8 @@ func (McpToolCallItem) threadItem()      {}
 func (WebSearchItem) threadItem()        {}
 func (TodoListItem) threadItem()         {}
 func (ErrorItem) threadItem()            {}
+func (StructuredOutputItem) threadItem() {}
+func (UnknownItem) threadItem()          {}
 
 func (AgentMessageItem) itemType() ThreadItemType     { return ThreadItemTypeAgentMessage }
 func (ReasoningItem) itemType() ThreadItemType        { return ThreadItemTypeReasoning }
@@ -147,3 +237,16 @@ func (McpToolCallItem) itemType() ThreadItemType      { return ThreadItemTypeMcp
 func (WebSearchItem) itemType() ThreadItemType        { return ThreadItemTypeWebSearch }
 func (TodoListItem) itemType() ThreadItemType         { return ThreadItemTypeTodoList }
 func (ErrorItem) itemType() ThreadItemType            { return ThreadItemTypeError }
+func (StructuredOutputItem) itemType() ThreadItemType { return ThreadItemTypeStructuredOutput }
+func (i UnknownItem) itemType() ThreadItemType        { return i.Type }
+
+func (i AgentMessageItem) itemID() string     { return i.ID }
+func (i ReasoningItem) itemID() string        { return i.ID }
+func (i CommandExecutionItem) itemID() string { return i.ID }
+func (i FileChangeItem) itemID() string       { return i.ID }
+func (i McpToolCallItem) itemID() string      { return i.ID }
+func (i WebSearchItem) itemID() string        { return i.ID }
+func (i TodoListItem) itemID() string         { return i.ID }
+func (i ErrorItem) itemID() string            { return i.ID }
+func (i StructuredOutputItem) itemID() string { return i.ID }
+func (i UnknownItem) itemID() string          { return i.ID }
@@ -0,0 +1,102 @@
+package godex
+
+import "time"
+
+// TurnLifecyclePhase identifies a step in the normalized lifecycle of a turn.
+type TurnLifecyclePhase string
+
+const (
+	// TurnPhaseStarted fires when the CLI process for the turn is launched.
+	TurnPhaseStarted TurnLifecyclePhase = "started"
+	// TurnPhaseFirstItem fires once, when the first item event of the turn arrives.
+	TurnPhaseFirstItem TurnLifecyclePhase = "first_item"
+	// TurnPhaseItemCompleted fires for every completed item.
+	TurnPhaseItemCompleted TurnLifecyclePhase = "item_completed"
+	// TurnPhaseCompleted fires when the turn completes successfully.
+	TurnPhaseCompleted TurnLifecyclePhase = "completed"
+	// TurnPhaseFailed fires when the turn fails, the stream reports an error, or the CLI exits
+	// with an error before completing the turn.
+	TurnPhaseFailed TurnLifecyclePhase = "failed"
+)
+
+// TurnLifecycleEvent is a normalized, timestamped view of a turn's progress delivered to
+// CodexOptions.LifecycleHook. Exactly one terminal phase (completed or failed) is emitted
+// per turn unless the CLI exits without reporting one.
+type TurnLifecycleEvent struct {
+	Phase TurnLifecyclePhase
+	// Time records when the SDK observed the phase. It carries a monotonic clock reading, so
+	// durations between events of the same turn are reliable.
+	Time time.Time
+	// ThreadID is the thread identifier, when known.
+	ThreadID string
+	// Label and Metadata echo TurnOptions.Label and TurnOptions.Metadata for correlation.
+	Label    string
+	Metadata map[string]string
+	// Item is set for first-item and item-completed phases.
+	Item ThreadItem
+	// Usage is set for terminal phases when the CLI reported token usage.
+	Usage *Usage
+	// Err is set for the failed phase.
+	Err error
+	// TempBytes is set for the started phase: the bytes of temporary files written for the
+	// turn (input images and the inline output schema), as counted against
+	// CodexOptions.MaxTempBytes.
+	TempBytes int64
+}
+
+// lifecycleTracker derives lifecycle phases from thread events for a single turn.
+type lifecycleTracker struct {
+	hook      func(TurnLifecycleEvent)
+	threadID  func() string
+	label     string
+	metadata  map[string]string
+	tempBytes int64
+	sawItem   bool
+	terminal  bool
+}
+
+func (l *lifecycleTracker) emit(event TurnLifecycleEvent) {
+	if l == nil || l.hook == nil {
+		return
+	}
+	event.Time = time.Now()
+	event.ThreadID = l.threadID()
+	event.Label = l.label
+	event.Metadata = l.metadata
+	l.hook(event)
+}
+
+func (l *lifecycleTracker) start() {
+	l.emit(TurnLifecycleEvent{Phase: TurnPhaseStarted, TempBytes: l.tempBytes})
+}
+
+func (l *lifecycleTracker) observe(event ThreadEvent) {
+	if l == nil || l.hook == nil {
+		return
+	}
+	if item := eventItem(event); item != nil && !l.sawItem {
+		l.sawItem = true
+		l.emit(TurnLifecycleEvent{Phase: TurnPhaseFirstItem, Item: item})
+	}
+
+	switch e := event.(type) {
+	case ItemCompletedEvent:
+		l.emit(TurnLifecycleEvent{Phase: TurnPhaseItemCompleted, Item: e.Item})
+	case TurnCompletedEvent:
+		l.terminal = true
+		usage := e.Usage
+		l.emit(TurnLifecycleEvent{Phase: TurnPhaseCompleted, Usage: &usage})
+	case TurnFailedEvent:
+		l.terminal = true
+		l.emit(TurnLifecycleEvent{Phase: TurnPhaseFailed, Usage: e.Usage, Err: &TurnFailedError{ThreadError: e.Error}})
+	}
+}
+
+// finish reports a failure when the turn ended with err and no terminal phase was emitted.
+func (l *lifecycleTracker) finish(err error) {
+	if l == nil || l.terminal || err == nil {
+		return
+	}
+	l.terminal = true
+	l.emit(TurnLifecycleEvent{Phase: TurnPhaseFailed, Err: err})
+}
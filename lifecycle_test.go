@@ -0,0 +1,117 @@
+package godex
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLifecycleHookReceivesOrderedPhases(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "turn.started"},
+		{"type": "item.started", "item": map[string]any{"id": "item_0", "type": "reasoning", "text": "thinking"}},
+		{"type": "item.completed", "item": map[string]any{"id": "item_0", "type": "reasoning", "text": "thinking"}},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "Hello"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 4, "cached_input_tokens": 1, "output_tokens": 2}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+
+	var received []TurnLifecycleEvent
+	options := CodexOptions{LifecycleHook: func(event TurnLifecycleEvent) {
+		received = append(received, event)
+	}}
+	thread := newThread(runner, options, ThreadOptions{}, "")
+
+	if _, err := thread.Run(context.Background(), "hello", nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	var phases []TurnLifecyclePhase
+	for _, event := range received {
+		phases = append(phases, event.Phase)
+	}
+	expected := []TurnLifecyclePhase{
+		TurnPhaseStarted,
+		TurnPhaseFirstItem,
+		TurnPhaseItemCompleted,
+		TurnPhaseItemCompleted,
+		TurnPhaseCompleted,
+	}
+	if !slices.Equal(phases, expected) {
+		t.Fatalf("expected phases %v, got %v", expected, phases)
+	}
+
+	for i := 1; i < len(received); i++ {
+		if received[i].Time.Before(received[i-1].Time) {
+			t.Fatalf("timestamps not monotonic at %d: %v before %v", i, received[i].Time, received[i-1].Time)
+		}
+	}
+	if received[0].ThreadID != "" || received[len(received)-1].ThreadID != "thread_1" {
+		t.Fatalf("unexpected thread ids: first %q last %q", received[0].ThreadID, received[len(received)-1].ThreadID)
+	}
+	if final := received[len(received)-1]; final.Usage == nil || final.Usage.InputTokens != 4 {
+		t.Fatalf("expected usage on completed phase, got %+v", final.Usage)
+	}
+	if _, ok := received[1].Item.(ReasoningItem); !ok {
+		t.Fatalf("expected first item to be reasoning, got %T", received[1].Item)
+	}
+}
+
+func TestLifecycleHookReportsFailure(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: threadErrorEvents(t)}}}
+
+	var phases []TurnLifecyclePhase
+	var failure error
+	options := CodexOptions{LifecycleHook: func(event TurnLifecycleEvent) {
+		phases = append(phases, event.Phase)
+		if event.Phase == TurnPhaseFailed {
+			failure = event.Err
+		}
+	}}
+	thread := newThread(runner, options, ThreadOptions{}, "")
+
+	if _, err := thread.Run(context.Background(), "hello", nil); err == nil {
+		t.Fatal("expected Run to fail")
+	}
+	if len(phases) == 0 || phases[len(phases)-1] != TurnPhaseFailed {
+		t.Fatalf("expected failed terminal phase, got %v", phases)
+	}
+	if failure == nil {
+		t.Fatal("expected failure error on failed phase")
+	}
+}
+
+func TestLifecycleHookEchoesTurnLabelAndMetadata(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+
+	var received []TurnLifecycleEvent
+	options := CodexOptions{LifecycleHook: func(event TurnLifecycleEvent) {
+		received = append(received, event)
+	}}
+	thread := newThread(runner, options, ThreadOptions{}, "")
+
+	metadata := map[string]string{"request_id": "req-42", "tenant": "acme"}
+	turnOpts := &TurnOptions{Label: "checkout-review", Metadata: metadata}
+	if _, err := thread.Run(context.Background(), "hello", turnOpts); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if len(received) == 0 {
+		t.Fatal("expected lifecycle events")
+	}
+	for _, event := range received {
+		if event.Label != "checkout-review" {
+			t.Fatalf("phase %s: unexpected label %q", event.Phase, event.Label)
+		}
+		if !maps.Equal(event.Metadata, metadata) {
+			t.Fatalf("phase %s: unexpected metadata %v", event.Phase, event.Metadata)
+		}
+	}
+	if args := fmt.Sprintf("%+v", runner.lastCall()); strings.Contains(args, "req-42") || strings.Contains(args, "checkout-review") {
+		t.Fatalf("turn label or metadata leaked into CLI args: %s", args)
+	}
+}
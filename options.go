@@ -1,5 +1,62 @@
 package godex
 
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ResumeStyle selects how a thread ID is passed to the CLI when resuming a thread.
+type ResumeStyle string
+
+const (
+	// ResumeStylePositional appends `resume <id>` after the other arguments (the default).
+	ResumeStylePositional ResumeStyle = "positional"
+	// ResumeStyleFlag passes `--resume <id>`, as expected by CLI versions with the flag form.
+	ResumeStyleFlag ResumeStyle = "flag"
+)
+
+// DuplicateStartedPolicy selects what a thread does when the CLI reports a second
+// thread.started event carrying a different thread ID.
+type DuplicateStartedPolicy string
+
+const (
+	// DuplicateStartedIgnore keeps the first thread ID, logs the conflicting event to
+	// CodexOptions.Logger and drops it (the default).
+	DuplicateStartedIgnore DuplicateStartedPolicy = "ignore"
+	// DuplicateStartedError fails the turn with ErrDuplicateThreadStarted.
+	DuplicateStartedError DuplicateStartedPolicy = "error"
+)
+
+// EventOverflowPolicy selects what a streaming turn does when the consumer of its Events
+// channel falls behind the CLI.
+type EventOverflowPolicy string
+
+const (
+	// EventOverflowBlock delivers every event and stalls reading the CLI's output until the
+	// consumer catches up (the default).
+	EventOverflowBlock EventOverflowPolicy = "block"
+	// EventOverflowDropOldest buffers up to CodexOptions.EventBufferSize events and, when the
+	// buffer is full, drops the oldest non-terminal event to make room. Terminal events
+	// (turn.completed, turn.failed and error) are never dropped. Callbacks and the assembled
+	// Turn still see every event.
+	EventOverflowDropOldest EventOverflowPolicy = "drop_oldest"
+)
+
+// AuthPriority selects which credentials the CLI uses when CodexOptions.APIKey is set and the
+// CLI also has a stored login (`codex auth login`).
+type AuthPriority string
+
+const (
+	// AuthPriorityAPIKey passes APIKey to the CLI as CODEX_API_KEY, which takes precedence over
+	// a stored login (the default).
+	AuthPriorityAPIKey AuthPriority = "api_key"
+	// AuthPriorityLogin uses the stored login when one exists (auth.json under CODEX_HOME,
+	// default ~/.codex): APIKey is not passed and an inherited CODEX_API_KEY is removed from
+	// the CLI's environment. Without a stored login APIKey is passed as usual.
+	AuthPriorityLogin AuthPriority = "login"
+)
+
 // ApprovalMode describes how the Codex CLI should request approval for actions that
 // might require user consent. The Codex CLI itself interprets these values, the SDK
 // merely forwards them when provided.
@@ -31,24 +88,154 @@ type CodexOptions struct {
 	// APIKey optionally overrides authentication for the Codex CLI. When empty, the CLI
 	// falls back to its own configured credentials (e.g. environment variables or auth login).
 	APIKey string
+	// AuthPriority decides between APIKey and an existing CLI login when both are present.
+	// Empty uses AuthPriorityAPIKey.
+	AuthPriority AuthPriority
 	// ConfigOverrides forwards CLI configuration overrides as `-c key=value` pairs. When
 	// the `profile` key is present it is emitted as `--profile <value>` instead.
 	ConfigOverrides map[string]any
 	// CLICacheDir overrides the directory used to cache downloaded Codex binaries. When empty,
-	// the SDK falls back to $GODEX_CLI_CACHE, then the user cache directory.
+	// the SDK falls back to $GODEX_CLI_CACHE, then the user cache directory. Before downloading,
+	// the first writable directory in that order (followed by the temp directory and
+	// .godex-cache in the working directory) is used.
 	CLICacheDir string
 	// CLIReleaseTag pins the Codex CLI release tag to download. When unset, the SDK checks
-	// $GODEX_CLI_RELEASE_TAG before falling back to its default bundled tag.
+	// $GODEX_CLI_RELEASE_TAG before falling back to its default bundled tag. The value "latest"
+	// resolves the newest release through the GitHub releases API once per process, falling
+	// back to the newest cached release or the bundled tag when the API is unreachable.
 	CLIReleaseTag string
 	// CLIChecksum optionally enforces integrity verification of the downloaded Codex binary.
 	// Provide the expected SHA-256 checksum (hex encoded). When empty, checksum verification
 	// is skipped. Use $GODEX_CLI_CHECKSUM to configure the same behavior via environment.
 	CLIChecksum string
+	// ResumeStyle controls how resumed thread IDs are passed to the CLI. Empty uses
+	// ResumeStylePositional.
+	ResumeStyle ResumeStyle
+	// DuplicateThreadStarted controls how a thread.started event whose ID differs from the
+	// thread's existing ID is handled. Empty uses DuplicateStartedIgnore. Repeated events with
+	// the same ID are always accepted.
+	DuplicateThreadStarted DuplicateStartedPolicy
+	// CLIDownloadBaseURL replaces the GitHub release host for air-gapped or regional mirrors.
+	// Assets are fetched from <CLIDownloadBaseURL>/<release>/<asset>, mirroring the layout of
+	// github.com/openai/codex/releases/download. Falls back to GODEX_CLI_BASE_URL.
+	CLIDownloadBaseURL string
+	// CLISignaturePublicKey enables tamper protection for downloaded CLI builds. When set to an
+	// ed25519 public key (hex or base64 encoded), every downloaded release asset must verify
+	// against its detached "<asset>.sig" signature or New fails with ErrSignatureInvalid.
+	// Cached binaries are only reused when they were verified with the same key and are
+	// unchanged since; anything else is downloaded and verified again.
+	CLISignaturePublicKey string
+	// AssetURLFunc, when set, fully controls the URL used to download a Codex CLI release
+	// asset, which allows mirrors with arbitrary path layouts. Returning an empty string or
+	// leaving it nil falls back to the GitHub release URL.
+	AssetURLFunc func(release, assetName string) string
+	// CLIReleaseMetadataURL is queried instead of the GitHub releases API to resolve a "latest"
+	// CLIReleaseTag; it must answer with a JSON object holding tag_name, like
+	// api.github.com/repos/openai/codex/releases/latest. Falls back to
+	// GODEX_CLI_RELEASE_METADATA_URL. When CLIDownloadBaseURL or AssetURLFunc points at a
+	// mirror and no metadata URL is set, "latest" is not resolved online: the newest cached
+	// release, or else the bundled tag, is used.
+	CLIReleaseMetadataURL string
+	// ForceDownload ignores any cached Codex binary and downloads a fresh copy during New,
+	// verifying CLIChecksum when configured. Useful when the cache is suspected to be corrupt.
+	ForceDownload bool
+	// VerifyBinaryRuns runs `codex --version` on every freshly downloaded binary before it is
+	// used. If it fails (for example a musl/glibc mismatch a checksum cannot catch), the
+	// download is removed from the cache and New fails with ErrBinaryNotRunnable.
+	VerifyBinaryRuns bool
+	// DownloadHTTPClient downloads the Codex CLI release asset, allowing custom proxies, TLS
+	// roots or authentication. Nil uses a default client with a two minute timeout.
+	DownloadHTTPClient *http.Client
+	// DownloadProgress is called periodically while the Codex CLI is downloaded with the bytes
+	// received so far and the total size from Content-Length (-1 when unknown). The final call
+	// reports the full download size.
+	DownloadProgress func(downloaded, total int64)
+	// StartupTimeout bounds how long New may spend locating or downloading the Codex CLI so a
+	// stalled connection cannot block startup indefinitely. Zero uses a five minute default;
+	// a negative value disables the limit.
+	StartupTimeout time.Duration
+	// Logger receives structured diagnostics from the CLI download and cache (which release
+	// is fetched, retries, cache hits) and warnings about ignored stream anomalies such as
+	// conflicting thread.started events. Nil disables logging.
+	Logger *slog.Logger
+	// BundleLogLevel is the minimum level of download/cache records sent to Logger,
+	// independent of the logger's own level, so download noise can be silenced while other
+	// output stays verbose. The zero value is slog.LevelInfo. Progress is never logged per
+	// chunk; use DownloadProgress for that.
+	BundleLogLevel slog.Level
+	// LifecycleHook, when set, receives a normalized, timestamped TurnLifecycleEvent for each
+	// phase of every turn (started, first item, each completed item, completed or failed). It
+	// runs on the streaming goroutine, so it should return quickly.
+	LifecycleHook func(TurnLifecycleEvent)
+	// ResponseCache, when set, serves Run/RunInputs from previously completed turns with an
+	// identical request fingerprint and stores successful turns. See ResponseCache for what
+	// the key covers.
+	ResponseCache ResponseCache
+	// ExtraEnv adds environment variables (for example HTTPS_PROXY or CODEX_HOME) to the CLI
+	// process only, on top of the inherited environment. Variables the SDK manages
+	// (OPENAI_BASE_URL from BaseURL, CODEX_API_KEY from APIKey and the originator override)
+	// take precedence over inherited values, but an entry here overrides them too. Empty
+	// names, or names containing '=', make the turn fail.
+	ExtraEnv map[string]string
+	// DisableOriginator stops the SDK from setting CODEX_INTERNAL_ORIGINATOR_OVERRIDE, which
+	// identifies the Go SDK to the CLI, for locked-down CLI setups that reject unexpected
+	// environment variables. A value inherited from the parent environment is left alone.
+	DisableOriginator bool
+	// ExtraArgs are passed to `codex exec` verbatim on every turn, for CLI flags the SDK does
+	// not model yet. They are appended after the flags the SDK manages (--model, --sandbox,
+	// --cd, --image, ...) and before the trailing `resume <id>`. The SDK does not check them
+	// for conflicts: repeating a managed flag or passing a positional argument may change the
+	// CLI's behaviour or make it reject the invocation.
+	ExtraArgs []string
+	// OnStdin, when set, receives a copy of the exact prompt bytes written to the CLI's stdin,
+	// once per run. Useful for protocol debugging or capturing traffic for replay.
+	OnStdin func(data []byte)
+	// SanitizeText makes decoded text fields (agent messages, reasoning, command output, file
+	// contents of changes, errors, rate limit messages and todo entries) safe to store in UTF-8 text columns before events reach
+	// callbacks or callers. JSON decoding already turns invalid byte sequences into U+FFFD,
+	// but a "\u0000" escape still yields a NUL character, which databases such as PostgreSQL
+	// reject; with SanitizeText, NUL characters are removed and any remaining invalid UTF-8
+	// is replaced with U+FFFD.
+	SanitizeText bool
+	// StrictDecode makes a turn fail when a CLI event or item carries a field the SDK does not
+	// model, instead of silently ignoring it. It is meant for tests and for catching drift
+	// after a CLI upgrade; event and item types the SDK does not know at all are still
+	// delivered as UnknownEvent and UnknownItem.
+	StrictDecode bool
+	// Decoder replaces encoding/json when decoding CLI events and items, for callers that
+	// decode enough events for JSON parsing to dominate CPU. It must honour the standard
+	// `json` struct tags and json.RawMessage. Nil uses json.Unmarshal. StrictDecode still
+	// decodes event and item payloads with encoding/json.
+	Decoder UnmarshalFunc
+	// DefaultModel is used for threads whose ThreadOptions.Model is empty. When it is empty
+	// too, the SDK falls back to $GODEX_DEFAULT_MODEL and otherwise lets the CLI pick.
+	DefaultModel string
+	// EventOverflowPolicy controls what happens when a streaming consumer lags behind the
+	// CLI. Empty uses EventOverflowBlock.
+	EventOverflowPolicy EventOverflowPolicy
+	// EventBufferSize is the number of events buffered for EventOverflowDropOldest. Zero or
+	// negative uses a default of 256.
+	EventBufferSize int
+	// ImageDownloadConcurrency caps how many LazyURLImageSegment images a turn downloads in
+	// parallel while preparing its input. Zero or negative uses a default of 4.
+	ImageDownloadConcurrency int
+	// MaxTempBytes caps the bytes of temporary files a turn may use: images written by
+	// BytesImageSegment, URLImageSegment or LazyURLImageSegment and the file holding an
+	// inline output schema. A turn over the limit fails with ErrTempBytesExceeded before the
+	// CLI is launched; lazy downloads and the schema file are checked before they are written,
+	// so they never take the turn past the limit. Zero or negative means no limit. The started
+	// lifecycle event reports the bytes used.
+	MaxTempBytes int64
 }
 
+// UnmarshalFunc decodes JSON data into v with the semantics of json.Unmarshal. It lets callers
+// plug in a faster JSON library for decoding CLI events.
+type UnmarshalFunc func(data []byte, v any) error
+
 // ThreadOptions configure how the CLI executes a particular thread.
 type ThreadOptions struct {
-	// Model specifies the model identifier to use for the thread.
+	// Model specifies the model identifier to use for the thread. When empty,
+	// CodexOptions.DefaultModel and then $GODEX_DEFAULT_MODEL apply.
 	Model string
 	// SandboxMode controls the CLI sandbox setting (equivalent to `--sandbox` flag).
 	SandboxMode SandboxMode
@@ -56,6 +243,16 @@ type ThreadOptions struct {
 	WorkingDirectory string
 	// SkipGitRepoCheck mirrors the CLI flag `--skip-git-repo-check`.
 	SkipGitRepoCheck bool
+	// ResumeContextFile points at a saved session file the CLI loads when resuming, forwarded
+	// as `-c experimental_resume=<path>`. It is only sent on turns that resume an existing
+	// thread and must reference a readable regular file.
+	ResumeContextFile string
+	// EventFlushInterval lets the CLI buffer experimental-json events for up to this long
+	// before writing them, trading latency for fewer writes on very chatty turns. It is
+	// forwarded as `-c exec.json_flush_interval_ms=<ms>` (rounded up to whole milliseconds)
+	// only when positive; negative values are rejected. CLI versions without the setting
+	// ignore it.
+	EventFlushInterval time.Duration
 }
 
 // TurnOptions configure a single turn executed within a thread.
@@ -63,6 +260,49 @@ type TurnOptions struct {
 	// OutputSchema is an optional JSON schema describing the structured response to
 	// collect from the agent. Must serialize to a JSON object (not an array or primitive).
 	OutputSchema any
+	// OutputSchemaPath points at an existing JSON schema file that is forwarded directly as
+	// `--output-schema` without writing a temporary copy. Mutually exclusive with OutputSchema.
+	OutputSchemaPath string
+	// MaxSchemaBytes caps the marshaled size of OutputSchema; larger schemas fail with
+	// ErrSchemaTooLarge before the CLI starts. Zero uses a 1 MiB default and a negative value
+	// disables the check.
+	MaxSchemaBytes int
 	// Callbacks attaches optional streaming callbacks invoked as events arrive.
 	Callbacks *StreamCallbacks
+	// Timeout bounds the duration of the turn. When it elapses the CLI process is killed and
+	// Wait/Run return ErrTurnTimeout. Zero means no limit beyond the caller's context.
+	Timeout time.Duration
+	// Label and Metadata tag the turn for correlation (for example with a request ID). They
+	// are echoed on every TurnLifecycleEvent of the turn and never sent to the CLI.
+	Label    string
+	Metadata map[string]string
+	// ExtraArgs are appended after CodexOptions.ExtraArgs for this turn only. The same
+	// ordering and conflict caveats apply.
+	ExtraArgs []string
+	// Steering keeps the CLI's stdin open after the prompt so RunStreamedResult.Send can
+	// inject additional messages mid-turn. The prompt and each message are written as
+	// newline-terminated lines; call RunStreamedResult.CloseInput when done sending.
+	// Run/RunInputs close the input immediately after the prompt.
+	Steering bool
+	// AutoContinue makes Run/RunInputs issue follow-up "continue" turns while the agent's
+	// response is truncated (FinishReasonLength). The responses are concatenated, items are
+	// appended, and usage is summed across every turn. Streaming runs ignore this option.
+	AutoContinue bool
+	// MaxContinuations bounds the number of follow-up turns issued by AutoContinue. Zero uses
+	// the default of 3.
+	MaxContinuations int
+}
+
+// RunUntilOptions configure Thread.RunUntilComplete.
+type RunUntilOptions struct {
+	// Turn applies to every turn issued by the loop.
+	Turn *TurnOptions
+	// ContinuePrompt is sent as the input of every follow-up turn. Empty uses
+	// "Continue with the task."
+	ContinuePrompt string
+	// MaxTurns caps the number of turns, including the first. Zero uses a default of 10.
+	MaxTurns int
+	// IsComplete, when set, is called after each turn; returning true ends the loop, for
+	// example when the agent's final response carries an agreed completion marker.
+	IsComplete func(Turn) bool
 }
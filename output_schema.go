@@ -6,9 +6,33 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/activadee/godex/internal/codexexec"
 )
 
-func createOutputSchemaFile(schema any) (string, func() error, error) {
+// defaultMaxSchemaBytes caps the marshaled size of inline output schemas when
+// TurnOptions.MaxSchemaBytes is zero.
+const defaultMaxSchemaBytes = 1 << 20
+
+// ErrSchemaTooLarge is returned when a marshaled output schema exceeds the configured size limit.
+var ErrSchemaTooLarge = errors.New("output schema too large")
+
+// resolveOutputSchemaPath returns the schema path forwarded to the CLI for the turn. Schemas
+// supplied via TurnOptions.OutputSchemaPath are used as-is; inline schemas are written to a
+// temporary file that the returned cleanup removes, after its size is reserved in budget.
+func resolveOutputSchemaPath(opts TurnOptions, budget *tempBudget) (string, func() error, error) {
+	if opts.OutputSchemaPath == "" {
+		return createOutputSchemaFile(opts.OutputSchema, opts.MaxSchemaBytes, budget)
+	}
+
+	noCleanup := func() error { return nil }
+	if opts.OutputSchema != nil {
+		return "", noCleanup, errors.New("TurnOptions.OutputSchema and TurnOptions.OutputSchemaPath are mutually exclusive")
+	}
+	return opts.OutputSchemaPath, noCleanup, nil
+}
+
+func createOutputSchemaFile(schema any, maxBytes int, budget *tempBudget) (string, func() error, error) {
 	noCleanup := func() error { return nil }
 	if schema == nil {
 		return "", noCleanup, nil
@@ -21,10 +45,19 @@ func createOutputSchemaFile(schema any) (string, func() error, error) {
 	if len(data) == 0 || data[0] != '{' {
 		return "", noCleanup, errors.New("output schema must serialize to a JSON object")
 	}
+	if maxBytes == 0 {
+		maxBytes = defaultMaxSchemaBytes
+	}
+	if maxBytes > 0 && len(data) > maxBytes {
+		return "", noCleanup, fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrSchemaTooLarge, len(data), maxBytes)
+	}
+	if err := budget.reserve(int64(len(data)), "output schema"); err != nil {
+		return "", noCleanup, err
+	}
 
 	dir, err := os.MkdirTemp("", "codex-output-schema-")
 	if err != nil {
-		return "", noCleanup, fmt.Errorf("create schema temp dir: %w", err)
+		return "", noCleanup, codexexec.CheckDiskFull(os.TempDir(), fmt.Errorf("create schema temp dir: %w", err))
 	}
 
 	cleanup := func() error {
@@ -34,7 +67,7 @@ func createOutputSchemaFile(schema any) (string, func() error, error) {
 	path := filepath.Join(dir, "schema.json")
 	if err := os.WriteFile(path, data, 0o600); err != nil {
 		_ = cleanup()
-		return "", noCleanup, fmt.Errorf("write schema file: %w", err)
+		return "", noCleanup, codexexec.CheckDiskFull(path, fmt.Errorf("write schema file: %w", err))
 	}
 
 	return path, cleanup, nil
@@ -0,0 +1,18 @@
+package godex
+
+import "github.com/activadee/godex/internal/codexexec"
+
+// Platform identifies a GOOS/GOARCH pair for which the SDK can download the Codex CLI.
+type Platform = codexexec.Platform
+
+// SupportedPlatforms lists every platform for which the SDK can download a bundled Codex CLI.
+// Other platforms require CodexOptions.CodexPathOverride or a codex binary on PATH.
+func SupportedPlatforms() []Platform {
+	return codexexec.SupportedPlatforms()
+}
+
+// IsPlatformSupported reports whether the SDK can download a bundled Codex CLI for goos/goarch.
+// Pass runtime.GOOS and runtime.GOARCH to check the current platform before calling New.
+func IsPlatformSupported(goos, goarch string) bool {
+	return codexexec.IsPlatformSupported(goos, goarch)
+}
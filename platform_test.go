@@ -0,0 +1,38 @@
+package godex
+
+import "testing"
+
+func TestSupportedPlatformsReportsKnownCombinations(t *testing.T) {
+	expected := map[string]string{
+		"linux/amd64":   "x86_64-unknown-linux-musl",
+		"linux/arm64":   "aarch64-unknown-linux-musl",
+		"darwin/amd64":  "x86_64-apple-darwin",
+		"darwin/arm64":  "aarch64-apple-darwin",
+		"windows/amd64": "x86_64-pc-windows-msvc",
+		"windows/arm64": "aarch64-pc-windows-msvc",
+	}
+
+	platforms := SupportedPlatforms()
+	if len(platforms) != len(expected) {
+		t.Fatalf("expected %d platforms, got %d: %+v", len(expected), len(platforms), platforms)
+	}
+	for _, p := range platforms {
+		key := p.GOOS + "/" + p.GOARCH
+		if triple, ok := expected[key]; !ok || triple != p.Triple {
+			t.Fatalf("unexpected platform %+v", p)
+		}
+		if !IsPlatformSupported(p.GOOS, p.GOARCH) {
+			t.Fatalf("expected %s to be supported", key)
+		}
+	}
+}
+
+func TestIsPlatformSupportedRejectsUnknown(t *testing.T) {
+	if IsPlatformSupported("plan9", "386") {
+		t.Fatal("expected plan9/386 to be unsupported")
+	}
+	// FreeBSD can be downloaded from a mirror but has no upstream asset.
+	if IsPlatformSupported("freebsd", "amd64") {
+		t.Fatal("expected freebsd/amd64 to be reported as unsupported")
+	}
+}
@@ -0,0 +1,67 @@
+package godex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ResponseCache stores completed turns keyed by a fingerprint of the request. When
+// CodexOptions.ResponseCache is set, Run and RunInputs consult it before launching the CLI
+// and store every successful turn afterwards. Streaming runs bypass the cache.
+//
+// Keys cover the thread ID, prompt, image contents, output schema, model, sandbox mode,
+// working directory, base URL, config overrides, extra CLI arguments and extra environment.
+// Turns on a thread that has not started yet (no ID) are never cached, so a cached turn
+// always belongs to the conversation it continues. Turns with LazyURLImageSegment inputs are
+// not cached either, since fingerprinting them would require downloading the images. Implementations must be safe for
+// concurrent use.
+type ResponseCache interface {
+	Get(key string) (Turn, bool)
+	Set(key string, turn Turn)
+}
+
+// responseCacheKey derives the cache key for a turn. ok is false when the request cannot be
+// fingerprinted or the thread has no ID yet, in which case the cache is bypassed.
+func (t *Thread) responseCacheKey(baseInput string, segments []InputSegment, turnOptions *TurnOptions) (string, bool) {
+	threadID := t.ID()
+	if threadID == "" || hasLazyImages(segments) {
+		return "", false
+	}
+	// encoding/json sorts map keys, so equal overrides always marshal identically.
+	overrides, err := json.Marshal(t.options.ConfigOverrides)
+	if err != nil {
+		return "", false
+	}
+	extraEnv, err := json.Marshal(t.options.ExtraEnv)
+	if err != nil {
+		return "", false
+	}
+	prepared, err := normalizeInput(baseInput, segments)
+	if err != nil {
+		return "", false
+	}
+	fingerprint, err := FingerprintTurn(prepared.prompt, prepared.images, turnOptions)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	writeField(h, "thread", []byte(threadID))
+	writeField(h, "turn", []byte(fingerprint))
+	writeField(h, "model", []byte(t.model()))
+	writeField(h, "sandbox", []byte(t.threadOptions.SandboxMode))
+	writeField(h, "cwd", []byte(t.threadOptions.WorkingDirectory))
+	writeField(h, "base-url", []byte(t.options.BaseURL))
+	writeField(h, "config", overrides)
+	writeField(h, "env", extraEnv)
+	for _, arg := range t.options.ExtraArgs {
+		writeField(h, "extra-arg", []byte(arg))
+	}
+	if turnOptions != nil {
+		for _, arg := range turnOptions.ExtraArgs {
+			writeField(h, "turn-extra-arg", []byte(arg))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
@@ -0,0 +1,139 @@
+package godex
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type recordingCache struct {
+	mu    sync.Mutex
+	turns map[string]Turn
+	gets  int
+	hits  int
+	sets  int
+}
+
+func newRecordingCache() *recordingCache {
+	return &recordingCache{turns: make(map[string]Turn)}
+}
+
+func (c *recordingCache) Get(key string) (Turn, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets++
+	turn, ok := c.turns[key]
+	if ok {
+		c.hits++
+	}
+	return turn, ok
+}
+
+func (c *recordingCache) Set(key string, turn Turn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sets++
+	c.turns[key] = turn
+}
+
+func TestThreadRunServesIdenticalRequestsFromCache(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+	cache := newRecordingCache()
+	options := CodexOptions{ResponseCache: cache}
+	threadOptions := ThreadOptions{Model: "gpt-test"}
+
+	first, err := newThread(runner, options, threadOptions, "thread_1").Run(context.Background(), "Summarize the report", nil)
+	if err != nil {
+		t.Fatalf("first Run returned error: %v", err)
+	}
+	second, err := newThread(runner, options, threadOptions, "thread_1").Run(context.Background(), "Summarize the report", nil)
+	if err != nil {
+		t.Fatalf("second Run returned error: %v", err)
+	}
+
+	if len(runner.calls) != 1 {
+		t.Fatalf("expected the runner to be invoked once, got %d", len(runner.calls))
+	}
+	if cache.hits != 1 || cache.sets != 1 {
+		t.Fatalf("expected one cache hit and one store, got hits=%d sets=%d", cache.hits, cache.sets)
+	}
+	if second.FinalResponse != first.FinalResponse {
+		t.Fatalf("expected cached response %q, got %q", first.FinalResponse, second.FinalResponse)
+	}
+
+	if _, err := newThread(runner, options, ThreadOptions{Model: "other"}, "thread_1").Run(context.Background(), "Summarize the report", nil); err != nil {
+		t.Fatalf("Run with a different model returned error: %v", err)
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected a different model to miss the cache, got %d calls", len(runner.calls))
+	}
+}
+
+func TestThreadRunCacheKeyCoversThreadAndConfig(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+	cache := newRecordingCache()
+	options := CodexOptions{ResponseCache: cache}
+
+	run := func(options CodexOptions, threadID string) {
+		t.Helper()
+		if _, err := newThread(runner, options, ThreadOptions{}, threadID).Run(context.Background(), "yes, apply it", nil); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+	}
+
+	run(options, "thread_a")
+	run(options, "thread_b")
+	overridden := options
+	overridden.ConfigOverrides = map[string]any{"model_reasoning_effort": "high"}
+	run(overridden, "thread_a")
+	rerouted := options
+	rerouted.BaseURL = "https://proxy.example.com"
+	run(rerouted, "thread_a")
+
+	if cache.hits != 0 {
+		t.Fatalf("expected every variation to miss the cache, got %d hits", cache.hits)
+	}
+	if len(runner.calls) != 4 {
+		t.Fatalf("expected four runner invocations, got %d", len(runner.calls))
+	}
+}
+
+func TestThreadRunBypassesCacheWithoutThreadID(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+	cache := newRecordingCache()
+	options := CodexOptions{ResponseCache: cache}
+
+	for i := 0; i < 2; i++ {
+		thread := newThread(runner, options, ThreadOptions{}, "")
+		if _, err := thread.Run(context.Background(), "Summarize the report", nil); err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		if thread.ID() != "thread_1" {
+			t.Fatalf("expected the thread ID to be recorded, got %q", thread.ID())
+		}
+	}
+	if cache.gets != 0 || cache.sets != 0 {
+		t.Fatalf("expected new threads to bypass the cache, got gets=%d sets=%d", cache.gets, cache.sets)
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected both runs to reach the runner, got %d", len(runner.calls))
+	}
+}
+
+func TestThreadRunDoesNotCacheFailedTurns(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: threadErrorEvents(t)}}
+	cache := newRecordingCache()
+	thread := newThread(runner, CodexOptions{ResponseCache: cache}, ThreadOptions{}, "thread_1")
+
+	for i := 0; i < 2; i++ {
+		if _, err := thread.Run(context.Background(), "hello", nil); err == nil {
+			t.Fatal("expected Run to fail")
+		}
+	}
+	if cache.sets != 0 {
+		t.Fatalf("expected failed turns not to be cached, got %d stores", cache.sets)
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected both runs to reach the runner, got %d", len(runner.calls))
+	}
+}
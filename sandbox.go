@@ -0,0 +1,72 @@
+package godex
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SandboxViolationError describes an operation the agent attempted that the CLI sandbox
+// blocked. It is derived from a failed command execution or an error item whose output carries
+// a sandbox denial; use SandboxViolation to classify items.
+type SandboxViolationError struct {
+	// ItemID identifies the item that reported the denial.
+	ItemID string
+	// Command is the blocked command line; empty when the denial came from an error item.
+	Command string
+	// Message is the output line that matched the sandbox denial signature.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *SandboxViolationError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Command != "" {
+		return fmt.Sprintf("sandbox blocked %q: %s", e.Command, e.Message)
+	}
+	return "sandbox blocked operation: " + e.Message
+}
+
+// sandboxDenialVerbs are the words that, next to a mention of the sandbox, mark a denial.
+// Generic failures such as a bare "permission denied" are deliberately not matched.
+var sandboxDenialVerbs = []string{"denied", "deny", "blocked", "forbidden", "not permitted", "disallowed"}
+
+// SandboxViolation reports whether item records an operation blocked by the sandbox. It
+// returns nil for items that succeeded, failed for other reasons, or are not command
+// executions or error items.
+func SandboxViolation(item ThreadItem) *SandboxViolationError {
+	switch v := item.(type) {
+	case CommandExecutionItem:
+		if !v.Failed() {
+			return nil
+		}
+		for _, output := range []string{v.Stderr, v.AggregatedOutput} {
+			if line, ok := sandboxDenialLine(output); ok {
+				return &SandboxViolationError{ItemID: v.ID, Command: v.Command, Message: line}
+			}
+		}
+	case ErrorItem:
+		if line, ok := sandboxDenialLine(v.Message); ok {
+			return &SandboxViolationError{ItemID: v.ID, Message: line}
+		}
+	}
+	return nil
+}
+
+// sandboxDenialLine returns the first line of output that mentions the sandbox together with
+// a denial verb.
+func sandboxDenialLine(output string) (string, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		lower := strings.ToLower(line)
+		if !strings.Contains(lower, "sandbox") {
+			continue
+		}
+		for _, verb := range sandboxDenialVerbs {
+			if strings.Contains(lower, verb) {
+				return strings.TrimSpace(line), true
+			}
+		}
+	}
+	return "", false
+}
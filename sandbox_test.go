@@ -0,0 +1,78 @@
+package godex
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSandboxViolationClassifiesDenials(t *testing.T) {
+	failed := 1
+	cases := []struct {
+		name    string
+		item    ThreadItem
+		message string
+	}{
+		{
+			name: "seatbelt denial on failed command",
+			item: CommandExecutionItem{
+				ID:               "cmd_1",
+				Command:          "touch /etc/hosts",
+				AggregatedOutput: "touch: /etc/hosts: Operation not permitted\nSandbox: touch(4121) deny(1) file-write-create /etc/hosts\n",
+				ExitCode:         &failed,
+				Status:           CommandExecutionStatusFailed,
+			},
+			message: "Sandbox: touch(4121) deny(1) file-write-create /etc/hosts",
+		},
+		{
+			name:    "error item",
+			item:    ErrorItem{ID: "err_1", Message: "exec command denied by sandbox policy: network access"},
+			message: "exec command denied by sandbox policy: network access",
+		},
+		{
+			name: "generic permission failure",
+			item: CommandExecutionItem{
+				ID:               "cmd_2",
+				Command:          "cat secret",
+				AggregatedOutput: "cat: secret: Permission denied\n",
+				ExitCode:         &failed,
+				Status:           CommandExecutionStatusFailed,
+			},
+		},
+		{
+			name: "successful command mentioning the sandbox",
+			item: CommandExecutionItem{
+				ID:               "cmd_3",
+				Command:          "grep -r sandbox docs",
+				AggregatedOutput: "docs/security.md: writes outside the workspace are blocked by the sandbox\n",
+				Status:           CommandExecutionStatusCompleted,
+			},
+		},
+		{
+			name: "other item types",
+			item: AgentMessageItem{ID: "msg_1", Text: "The sandbox denied my write."},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			violation := SandboxViolation(tc.item)
+			if tc.message == "" {
+				if violation != nil {
+					t.Fatalf("expected no violation, got %v", violation)
+				}
+				return
+			}
+			if violation == nil {
+				t.Fatal("expected a sandbox violation")
+			}
+			if violation.Message != tc.message {
+				t.Fatalf("message = %q, want %q", violation.Message, tc.message)
+			}
+			var err error = violation
+			var target *SandboxViolationError
+			if !errors.As(err, &target) || target.ItemID == "" {
+				t.Fatalf("expected a typed error carrying the item ID, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package godex
+
+import "strings"
+
+const utf8Replacement = "\uFFFD"
+
+// sanitizeEvent removes NUL characters and replaces invalid UTF-8 sequences in the
+// human-readable text carried by event.
+func sanitizeEvent(event ThreadEvent) ThreadEvent {
+	switch e := event.(type) {
+	case ItemStartedEvent:
+		e.Item = sanitizeItem(e.Item)
+		return e
+	case ItemUpdatedEvent:
+		e.Item = sanitizeItem(e.Item)
+		return e
+	case ItemCompletedEvent:
+		e.Item = sanitizeItem(e.Item)
+		return e
+	case TurnFailedEvent:
+		e.Error.Message = sanitizeText(e.Error.Message)
+		return e
+	case ThreadErrorEvent:
+		e.Message = sanitizeText(e.Message)
+		return e
+	case RateLimitEvent:
+		e.Message = sanitizeText(e.Message)
+		return e
+	}
+	return event
+}
+
+func sanitizeItem(item ThreadItem) ThreadItem {
+	switch v := item.(type) {
+	case AgentMessageItem:
+		v.Text = sanitizeText(v.Text)
+		return v
+	case ReasoningItem:
+		v.Text = sanitizeText(v.Text)
+		v.Summary = sanitizeText(v.Summary)
+		return v
+	case CommandExecutionItem:
+		v.Command = sanitizeText(v.Command)
+		v.AggregatedOutput = sanitizeText(v.AggregatedOutput)
+		v.Stdout = sanitizeText(v.Stdout)
+		v.Stderr = sanitizeText(v.Stderr)
+		return v
+	case FileChangeItem:
+		if v.Changes != nil {
+			changes := make([]FileUpdateChange, len(v.Changes))
+			for i, change := range v.Changes {
+				change.OldContent = sanitizeTextPtr(change.OldContent)
+				change.NewContent = sanitizeTextPtr(change.NewContent)
+				changes[i] = change
+			}
+			v.Changes = changes
+		}
+		return v
+	case McpToolCallItem:
+		v.Error = sanitizeText(v.Error)
+		return v
+	case WebSearchItem:
+		v.Query = sanitizeText(v.Query)
+		if v.Results != nil {
+			results := make([]WebSearchResult, len(v.Results))
+			for i, result := range v.Results {
+				result.Title = sanitizeText(result.Title)
+				result.Snippet = sanitizeText(result.Snippet)
+				results[i] = result
+			}
+			v.Results = results
+		}
+		return v
+	case ErrorItem:
+		v.Message = sanitizeText(v.Message)
+		return v
+	case TodoListItem:
+		items := make([]TodoItem, len(v.Items))
+		for i, todo := range v.Items {
+			todo.Text = sanitizeText(todo.Text)
+			items[i] = todo
+		}
+		v.Items = items
+		return v
+	}
+	return item
+}
+
+// sanitizeText drops NUL characters, which encoding/json produces for "\u0000" escapes, and
+// repairs invalid UTF-8 in strings that did not come through the JSON decoder.
+func sanitizeText(s string) string {
+	return strings.ToValidUTF8(strings.ReplaceAll(s, "\x00", ""), utf8Replacement)
+}
+
+// sanitizeTextPtr sanitizes the string s points to into a new string, leaving nil as nil.
+func sanitizeTextPtr(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	sanitized := sanitizeText(*s)
+	return &sanitized
+}
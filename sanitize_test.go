@@ -0,0 +1,98 @@
+package godex
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeEventRepairsInvalidUTF8(t *testing.T) {
+	invalid := "build \xff\x00\xfe output"
+
+	event := sanitizeEvent(ItemCompletedEvent{
+		Type: ThreadEventTypeItemCompleted,
+		Item: CommandExecutionItem{ID: "cmd_1", Command: "cat bin", AggregatedOutput: invalid},
+	})
+
+	command := event.(ItemCompletedEvent).Item.(CommandExecutionItem)
+	if !utf8.ValidString(command.AggregatedOutput) {
+		t.Fatalf("expected valid UTF-8, got %q", command.AggregatedOutput)
+	}
+	if want := "build \uFFFD output"; command.AggregatedOutput != want {
+		t.Fatalf("expected %q, got %q", want, command.AggregatedOutput)
+	}
+}
+
+func TestSanitizeEventCoversFileContentsAndErrors(t *testing.T) {
+	invalid := "line \xff\x00 end"
+	const want = "line \uFFFD end"
+
+	oldContent, newContent := invalid, invalid
+	event := sanitizeEvent(ItemCompletedEvent{
+		Type: ThreadEventTypeItemCompleted,
+		Item: FileChangeItem{ID: "patch_1", Changes: []FileUpdateChange{
+			{Path: "main.go", Kind: PatchChangeKindUpdate, OldContent: &oldContent, NewContent: &newContent},
+			{Path: "gone.go", Kind: PatchChangeKindDelete, OldContent: &oldContent},
+		}},
+	})
+	changes := event.(ItemCompletedEvent).Item.(FileChangeItem).Changes
+	if *changes[0].OldContent != want || *changes[0].NewContent != want || *changes[1].OldContent != want {
+		t.Fatalf("expected sanitized file contents, got %q, %q and %q", *changes[0].OldContent, *changes[0].NewContent, *changes[1].OldContent)
+	}
+	if changes[1].NewContent != nil {
+		t.Fatalf("expected a missing NewContent to stay nil, got %q", *changes[1].NewContent)
+	}
+	if oldContent != invalid {
+		t.Fatalf("expected the original contents to be left untouched, got %q", oldContent)
+	}
+
+	event = sanitizeEvent(ItemCompletedEvent{
+		Type: ThreadEventTypeItemCompleted,
+		Item: McpToolCallItem{ID: "mcp_1", Error: invalid},
+	})
+	if got := event.(ItemCompletedEvent).Item.(McpToolCallItem).Error; got != want {
+		t.Fatalf("expected sanitized MCP error, got %q", got)
+	}
+
+	event = sanitizeEvent(RateLimitEvent{Type: ThreadEventTypeRateLimit, Message: invalid})
+	if got := event.(RateLimitEvent).Message; got != want {
+		t.Fatalf("expected sanitized rate limit message, got %q", got)
+	}
+}
+
+func TestThreadRunSanitizesAgentMessageText(t *testing.T) {
+	// The CLI escapes NUL as \u0000 and may leak raw invalid bytes from command output.
+	line := append(append([]byte(`{"type":"item.completed","item":{"id":"msg_1","type":"agent_message","text":"ok \u0000`), 0xff, 0xfe), []byte(` done"}}`)...)
+	events := [][]byte{
+		[]byte(`{"type":"thread.started","thread_id":"thread_1"}`),
+		line,
+		[]byte(`{"type":"turn.completed","usage":{"input_tokens":1,"cached_input_tokens":0,"output_tokens":1}}`),
+	}
+
+	run := func(sanitize bool) (string, string) {
+		t.Helper()
+		runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+		thread := newThread(runner, CodexOptions{SanitizeText: sanitize}, ThreadOptions{}, "")
+
+		var callbackText string
+		turn, err := thread.Run(context.Background(), "hello", &TurnOptions{Callbacks: &StreamCallbacks{
+			OnMessage: func(evt StreamMessageEvent) { callbackText = evt.Message.Text },
+		}})
+		if err != nil {
+			t.Fatalf("Run returned error: %v", err)
+		}
+		return turn.FinalResponse, callbackText
+	}
+
+	final, callbackText := run(true)
+	for _, text := range []string{final, callbackText} {
+		if !utf8.ValidString(text) || strings.Contains(text, "\x00") {
+			t.Fatalf("expected sanitized text, got %q", text)
+		}
+	}
+
+	if final, _ := run(false); !strings.Contains(final, "\x00") {
+		t.Fatalf("expected the NUL character to be kept without SanitizeText, got %q", final)
+	}
+}
@@ -0,0 +1,84 @@
+package godex
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	validator "github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// outputSchemaURL names the output schema inside the validator; it is never fetched.
+const outputSchemaURL = "mem://godex/output-schema.json"
+
+// outputValidator checks structured output against the turn's output schema locally.
+type outputValidator struct {
+	schema *validator.Schema
+}
+
+// compileOutputValidator compiles the inline schema, or the schema file at path when schema is
+// nil, for local validation of structured output.
+func compileOutputValidator(schema any, path string) (*outputValidator, error) {
+	var data []byte
+	var err error
+	if schema != nil {
+		data, err = json.Marshal(schema)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load output schema for validation: %w", err)
+	}
+
+	compiler := validator.NewCompiler()
+	if err := compiler.AddResource(outputSchemaURL, bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("load output schema for validation: %w", err)
+	}
+	compiled, err := compiler.Compile(outputSchemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("compile output schema for validation: %w", err)
+	}
+	return &outputValidator{schema: compiled}, nil
+}
+
+// validate checks the JSON document data and reports the first failure as a
+// *SchemaViolationError pointing at the offending value.
+func (v *outputValidator) validate(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var instance any
+	if err := decoder.Decode(&instance); err != nil {
+		return fmt.Errorf("decode structured output: %w", err)
+	}
+	return v.validateValue(instance)
+}
+
+func (v *outputValidator) validateValue(instance any) error {
+	err := v.schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	var validationErr *validator.ValidationError
+	if !errors.As(err, &validationErr) {
+		return &SchemaViolationError{Message: err.Error()}
+	}
+	// The leaf of the first cause chain names the exact keyword and value that failed.
+	leaf := validationErr
+	for len(leaf.Causes) > 0 {
+		leaf = leaf.Causes[0]
+	}
+	return &SchemaViolationError{
+		Message: fmt.Sprintf("structured output does not match the schema at %s: %s", displayPointer(leaf.InstanceLocation), leaf.Message),
+		Path:    leaf.InstanceLocation,
+	}
+}
+
+// displayPointer renders a JSON pointer for messages, naming the document root explicitly.
+func displayPointer(pointer string) string {
+	if pointer == "" {
+		return "(root)"
+	}
+	return pointer
+}
@@ -2,30 +2,219 @@ package godex
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
 	"sync"
 )
 
+// ErrSteeringDisabled is returned by RunStreamedResult.Send when the turn was not started
+// with TurnOptions.Steering.
+var ErrSteeringDisabled = errors.New("steering is not enabled for this turn")
+
+// ErrInputClosed is returned by RunStreamedResult.Send once the turn's input has been closed
+// or the turn has finished.
+var ErrInputClosed = errors.New("turn input is closed")
+
 // Stream is an internal helper that coordinates the lifecycle of a streaming turn.
 type Stream struct {
 	events <-chan ThreadEvent
 	cancel context.CancelFunc
 
-	done chan struct{}
+	done      chan struct{}
+	closing   chan struct{}
+	closeOnce sync.Once
 
 	mu  sync.Mutex
 	err error
+
+	// turn is fed every event by the producer goroutine and read only after done is closed.
+	turn turnBuilder
+
+	// pending holds events consumed by helpers such as UntilCommand so that Events can replay
+	// them before forwarding live events. consumer is the channel handed out by Events.
+	bufMu    sync.Mutex
+	pending  []ThreadEvent
+	consumer <-chan ThreadEvent
+
+	// inputReady is non-nil for steering turns and is closed once stdin is attached.
+	inputReady  chan struct{}
+	inputMu     sync.Mutex
+	input       io.WriteCloser
+	inputClosed bool
+
+	// interrupt signals the CLI process; nil until the process starts or where unsupported.
+	interruptMu sync.Mutex
+	interrupt   func() error
 }
 
 func newStream(events <-chan ThreadEvent, cancel context.CancelFunc) *Stream {
 	return &Stream{
-		events: events,
-		cancel: cancel,
-		done:   make(chan struct{}),
+		events:  events,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
 	}
 }
 
 func (s *Stream) Events() <-chan ThreadEvent {
-	return s.events
+	s.bufMu.Lock()
+	defer s.bufMu.Unlock()
+	if s.consumer != nil {
+		return s.consumer
+	}
+	if len(s.pending) == 0 {
+		s.consumer = s.events
+		return s.consumer
+	}
+	out := make(chan ThreadEvent)
+	s.consumer = out
+	go s.replay(out)
+	return out
+}
+
+// replay forwards buffered events followed by the remaining live events.
+func (s *Stream) replay(out chan<- ThreadEvent) {
+	defer close(out)
+	for {
+		event, ok := s.popPending()
+		if !ok {
+			if event, ok = <-s.events; !ok {
+				return
+			}
+		}
+		select {
+		case out <- event:
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+func (s *Stream) popPending() (ThreadEvent, bool) {
+	s.bufMu.Lock()
+	defer s.bufMu.Unlock()
+	if len(s.pending) == 0 {
+		return nil, false
+	}
+	event := s.pending[0]
+	s.pending = s.pending[1:]
+	return event, true
+}
+
+// bufferNext reads the next live event and records it for replay by Events. ok is false once
+// the stream has closed.
+func (s *Stream) bufferNext(ctx context.Context) (event ThreadEvent, ok bool, err error) {
+	select {
+	case event, ok = <-s.events:
+		if !ok {
+			return nil, false, nil
+		}
+		s.bufMu.Lock()
+		s.pending = append(s.pending, event)
+		s.bufMu.Unlock()
+		return event, true, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// enableInput prepares the stream to accept steering messages once attachInput is called.
+func (s *Stream) enableInput() {
+	s.inputReady = make(chan struct{})
+}
+
+// attachInput receives the CLI's stdin for a steering turn.
+func (s *Stream) attachInput(w io.WriteCloser) {
+	s.inputMu.Lock()
+	s.input = w
+	s.inputMu.Unlock()
+	close(s.inputReady)
+}
+
+// send writes a newline-terminated steering message to the CLI's stdin.
+func (s *Stream) send(ctx context.Context, text string) error {
+	if s.inputReady == nil {
+		return ErrSteeringDisabled
+	}
+	select {
+	case <-s.inputReady:
+	case <-s.done:
+		return ErrInputClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	s.inputMu.Lock()
+	defer s.inputMu.Unlock()
+	if s.inputClosed || s.finished() {
+		return ErrInputClosed
+	}
+	if _, err := io.WriteString(s.input, text+"\n"); err != nil {
+		if errors.Is(err, os.ErrClosed) {
+			return ErrInputClosed
+		}
+		return fmt.Errorf("write steering message: %w", err)
+	}
+	return nil
+}
+
+// closeInput closes the CLI's stdin so it sees EOF. It is a no-op when steering is disabled.
+func (s *Stream) closeInput() error {
+	if s.inputReady == nil {
+		return nil
+	}
+	select {
+	case <-s.inputReady:
+	case <-s.done:
+		return nil
+	}
+
+	s.inputMu.Lock()
+	defer s.inputMu.Unlock()
+	if s.inputClosed {
+		return nil
+	}
+	s.inputClosed = true
+	return s.input.Close()
+}
+
+// attachInterrupt receives the function that interrupts the CLI process for this turn.
+func (s *Stream) attachInterrupt(interrupt func() error) {
+	s.interruptMu.Lock()
+	s.interrupt = interrupt
+	s.interruptMu.Unlock()
+}
+
+// interruptTurn asks the CLI to stop the turn gracefully, falling back to cancellation when
+// the process cannot be signalled.
+func (s *Stream) interruptTurn() error {
+	if s.finished() {
+		return nil
+	}
+	s.interruptMu.Lock()
+	interrupt := s.interrupt
+	s.interruptMu.Unlock()
+	if interrupt == nil {
+		s.cancel()
+		return nil
+	}
+	if err := interrupt(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("interrupt codex process: %w", err)
+	}
+	return nil
+}
+
+// finished reports whether the turn has completed.
+func (s *Stream) finished() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
 }
 
 func (s *Stream) setErr(err error) {
@@ -54,6 +243,97 @@ func (s *Stream) Wait() error {
 }
 
 func (s *Stream) Close() error {
+	s.closeOnce.Do(func() { close(s.closing) })
 	s.cancel()
 	return s.Wait()
 }
+
+// defaultEventBufferSize is the ring capacity used by EventOverflowDropOldest when
+// CodexOptions.EventBufferSize is unset.
+const defaultEventBufferSize = 256
+
+// eventRing buffers events between the CLI reader and the Events channel for
+// EventOverflowDropOldest, so a lagging consumer never stalls the CLI.
+type eventRing struct {
+	size   int
+	notify chan struct{}
+
+	mu     sync.Mutex
+	buf    []ThreadEvent
+	closed bool
+}
+
+func newEventRing(size int) *eventRing {
+	if size <= 0 {
+		size = defaultEventBufferSize
+	}
+	return &eventRing{size: size, notify: make(chan struct{}, 1)}
+}
+
+// push appends event, first dropping the oldest non-terminal event when the ring is full. A
+// ring holding only terminal events grows instead.
+func (r *eventRing) push(event ThreadEvent) {
+	r.mu.Lock()
+	if len(r.buf) >= r.size {
+		if i := slices.IndexFunc(r.buf, func(e ThreadEvent) bool { return !isTerminalEvent(e) }); i >= 0 {
+			r.buf = slices.Delete(r.buf, i, i+1)
+		}
+	}
+	r.buf = append(r.buf, event)
+	r.mu.Unlock()
+	r.signal()
+}
+
+// close marks the end of the stream; forward closes its channel once the ring is drained.
+func (r *eventRing) close() {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.signal()
+}
+
+func (r *eventRing) signal() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// forward delivers buffered events to out in order and closes out once the ring is closed and
+// empty, or as soon as closing is closed.
+func (r *eventRing) forward(out chan<- ThreadEvent, closing <-chan struct{}) {
+	defer close(out)
+	for {
+		r.mu.Lock()
+		if len(r.buf) > 0 {
+			event := r.buf[0]
+			r.buf = r.buf[1:]
+			r.mu.Unlock()
+			select {
+			case out <- event:
+			case <-closing:
+				return
+			}
+			continue
+		}
+		closed := r.closed
+		r.mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case <-r.notify:
+		case <-closing:
+			return
+		}
+	}
+}
+
+// isTerminalEvent reports whether event ends a turn and must never be dropped.
+func isTerminalEvent(event ThreadEvent) bool {
+	switch event.(type) {
+	case TurnCompletedEvent, TurnFailedEvent, ThreadErrorEvent:
+		return true
+	}
+	return false
+}
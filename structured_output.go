@@ -1,6 +1,7 @@
 package godex
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -18,7 +19,20 @@ var (
 	ErrNoStructuredOutput = errors.New("structured output not returned")
 )
 
-const runStreamedJSONEventBuffer = 16
+const (
+	runStreamedJSONEventBuffer = 16
+	defaultRepairAttempts      = 2
+	// structuredValueField is the property that holds a slice, array or map T in the inferred
+	// output schema, because the CLI only accepts object schemas.
+	structuredValueField = "value"
+)
+
+// Kinds reported to RunJSONOptions.OnDropped.
+const (
+	DroppedKindUpdate      = "update"
+	DroppedKindFinalUpdate = "final_update"
+	DroppedKindEvent       = "event"
+)
 
 // RunJSONOptions configure a typed JSON turn.
 type RunJSONOptions[T any] struct {
@@ -30,11 +44,81 @@ type RunJSONOptions[T any] struct {
 	Schema any
 	// DisableSchemaInference prevents automatic schema inference from T when Schema is nil.
 	DisableSchemaInference bool
+	// Repair sends follow-up turns asking the agent to correct its output when the final
+	// response is not valid JSON for T. RunJSON returns the last decode error once
+	// MaxRepairAttempts follow-ups have failed.
+	Repair bool
+	// MaxRepairAttempts bounds the number of repair turns. Defaults to 2 when Repair is set.
+	MaxRepairAttempts int
+	// MaxSchemaRetries re-runs the turn, up to this many times, when RunJSON's output breaks
+	// the schema: the CLI reports a schema failure, local validation (ValidateSchema) fails,
+	// or the response does not decode into T. Each retry sends the failure detail back as
+	// "Your previous output failed validation: <detail>". Once retries are exhausted the last
+	// failure is returned as a *SchemaViolationError.
+	MaxSchemaRetries int
+	// SkipInvalidMessages makes RunJSONAll ignore agent messages that do not decode into T
+	// instead of failing the call.
+	SkipInvalidMessages bool
+	// OnDropped is invoked by RunStreamedJSON whenever a snapshot or raw event is discarded
+	// because its channel buffer is full. kind is one of the DroppedKind constants. The
+	// callback runs on the streaming goroutine and should return quickly.
+	OnDropped func(kind string)
+	// BlockOnEvents makes RunStreamedJSON deliver every raw event on Events(), applying
+	// backpressure instead of dropping events when the buffer is full. Callers that set it
+	// must drain Events() alongside Updates().
+	BlockOnEvents bool
+	// SchemaID and SchemaTitle set `$id` and `title` on the schema inferred from T, for
+	// validators and logs that key on them. They are ignored when an explicit schema is used.
+	SchemaID    string
+	SchemaTitle string
+	// DecoderConfig controls how responses are decoded into T by RunJSON, RunJSONAll and
+	// both the intermediate and final snapshots of RunStreamedJSON.
+	DecoderConfig StructuredDecoderConfig
+	// ValidateSchema validates the structured output against the output schema (inferred,
+	// explicit or loaded from TurnOptions.OutputSchemaPath) in the SDK before it is returned,
+	// instead of relying only on the CLI's error message. A mismatch is reported as a
+	// *SchemaViolationError naming the failing location.
+	ValidateSchema bool
+}
+
+// StructuredDecoderConfig adjusts the JSON decoding of structured output. The zero value
+// decodes like json.Unmarshal.
+type StructuredDecoderConfig struct {
+	// DisallowUnknownFields fails decoding when the response has an object key that does not
+	// match a field of the target struct, to catch drift between the schema and T.
+	DisallowUnknownFields bool
+	// UseNumber decodes numbers held in interface{} values as json.Number instead of float64,
+	// keeping large integers exact.
+	UseNumber bool
+}
+
+// unmarshal decodes data into v according to the configuration.
+func (c StructuredDecoderConfig) unmarshal(data []byte, v any) error {
+	if c == (StructuredDecoderConfig{}) {
+		return json.Unmarshal(data, v)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if c.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if c.UseNumber {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("invalid data after top-level JSON value")
+	}
+	return nil
 }
 
 // SchemaViolationError indicates that the structured output failed schema validation.
 type SchemaViolationError struct {
 	Message string
+	// Path is the JSON pointer (for example "/items/0/name") of the value that failed local
+	// validation; empty for the document root and for violations reported by the CLI.
+	Path string
 }
 
 // Error implements the error interface.
@@ -46,6 +130,9 @@ func (e *SchemaViolationError) Error() string {
 }
 
 // RunJSON executes a turn expecting a structured JSON response that can be decoded into T.
+// When T is a slice, array or map, the inferred schema is an object whose required "value"
+// property holds T, as the CLI only accepts object schemas; the value is unwrapped before it
+// is decoded.
 func RunJSON[T any](ctx context.Context, thread *Thread, input string, options *RunJSONOptions[T]) (T, error) {
 	var zero T
 
@@ -58,19 +145,105 @@ func RunJSON[T any](ctx context.Context, thread *Thread, input string, options *
 		return zero, err
 	}
 
+	// Repairs and schema retries are counted separately, so each option bounds only its own
+	// follow-up turns.
+	prompt := input
+	var repairs, retries int
+	for {
+		result, err := thread.run(ctx, prompt, nil, &config.turnOptions)
+		if err != nil {
+			schemaErr, ok := classifyStructuredOutputError(err, config.expectSchemaError)
+			if !ok {
+				return zero, err
+			}
+			if retries >= config.schemaRetries {
+				return zero, schemaErr
+			}
+			retries++
+			prompt = schemaRetryPrompt(schemaErr)
+			continue
+		}
+
+		value, decodeErr := decodeStructuredValue[T](result.FinalResponse, config)
+		if decodeErr == nil {
+			return value, nil
+		}
+		var violation *SchemaViolationError
+		if !errors.As(decodeErr, &violation) && repairs < config.repairAttempts {
+			repairs++
+			prompt = repairPrompt(decodeErr)
+			continue
+		}
+		if violation == nil && config.schemaRetries > 0 {
+			violation = &SchemaViolationError{Message: fmt.Sprintf("decode structured output: %v", decodeErr)}
+		}
+		if violation == nil {
+			return zero, fmt.Errorf("decode structured output: %w", decodeErr)
+		}
+		if retries >= config.schemaRetries {
+			return zero, violation
+		}
+		retries++
+		prompt = schemaRetryPrompt(violation)
+	}
+}
+
+// RunJSONAll executes a turn and decodes every completed agent message into T, returning the
+// records in the order they were produced. Messages that fail to decode return an error unless
+// RunJSONOptions.SkipInvalidMessages is set. ErrNoStructuredOutput is returned when no message
+// decodes successfully.
+func RunJSONAll[T any](ctx context.Context, thread *Thread, input string, options *RunJSONOptions[T]) ([]T, error) {
+	if thread == nil {
+		return nil, errors.New("RunJSONAll requires a non-nil thread")
+	}
+
+	config, err := prepareRunJSONOptions[T](options)
+	if err != nil {
+		return nil, err
+	}
+	skipInvalid := options != nil && options.SkipInvalidMessages
+
 	result, err := thread.run(ctx, input, nil, &config.turnOptions)
 	if err != nil {
 		if schemaErr, ok := classifyStructuredOutputError(err, config.expectSchemaError); ok {
-			return zero, schemaErr
+			return nil, schemaErr
 		}
-		return zero, err
+		return nil, err
 	}
 
-	var value T
-	if err := json.Unmarshal([]byte(result.FinalResponse), &value); err != nil {
-		return zero, fmt.Errorf("decode structured output: %w", err)
+	var records []T
+	for _, item := range result.Items {
+		msg, ok := item.(AgentMessageItem)
+		if !ok {
+			continue
+		}
+		value, err := decodeStructuredValue[T](msg.Text, config)
+		if err != nil {
+			if skipInvalid {
+				continue
+			}
+			return nil, fmt.Errorf("decode structured output from message %s: %w", msg.ID, err)
+		}
+		records = append(records, value)
 	}
-	return value, nil
+
+	if len(records) == 0 {
+		return nil, ErrNoStructuredOutput
+	}
+	return records, nil
+}
+
+// schemaRetryPromptTemplate is the follow-up sent by MaxSchemaRetries; %s receives the failure.
+const schemaRetryPromptTemplate = "Your previous output failed validation: %s. " +
+	"Reply again with only a single JSON value that conforms to the output schema, without any surrounding text."
+
+func schemaRetryPrompt(violation error) string {
+	return fmt.Sprintf(schemaRetryPromptTemplate, violation.Error())
+}
+
+func repairPrompt(decodeErr error) string {
+	return fmt.Sprintf("Your previous response could not be parsed as JSON (%v). "+
+		"Reply again with only a single JSON value that conforms to the output schema, without any surrounding text.", decodeErr)
 }
 
 // RunStreamedJSONUpdate captures a typed snapshot of the structured output as the turn progresses.
@@ -83,6 +256,7 @@ type RunStreamedJSONUpdate[T any] struct {
 // RunStreamedJSONResult exposes the streaming lifecycle for a typed structured output turn.
 type RunStreamedJSONResult[T any] struct {
 	stream  *Stream
+	cancel  context.CancelFunc
 	events  <-chan ThreadEvent
 	updates <-chan RunStreamedJSONUpdate[T]
 	err     *sharedError
@@ -118,6 +292,13 @@ func (r RunStreamedJSONResult[T]) Wait() error {
 		if done != nil {
 			<-done
 		}
+		// A failed turn may have been classified as a schema violation while streaming.
+		var failed *TurnFailedError
+		if errors.As(err, &failed) && r.err != nil {
+			if classified := r.err.get(); classified != nil {
+				return classified
+			}
+		}
 		return err
 	}
 	if done != nil {
@@ -129,34 +310,16 @@ func (r RunStreamedJSONResult[T]) Wait() error {
 	return nil
 }
 
-// Close cancels the turn and waits for shutdown.
+// Close cancels the turn and waits for shutdown. Like RunStreamedResult.Close it is safe to
+// call repeatedly and after Wait, returning the same error as Wait.
 func (r RunStreamedJSONResult[T]) Close() error {
-	var done <-chan struct{}
-	if r.done != nil {
-		done = r.done
+	if r.cancel != nil {
+		r.cancel()
 	}
-	if r.stream == nil {
-		if done != nil {
-			<-done
-		}
-		if r.err != nil {
-			return r.err.get()
-		}
-		return nil
-	}
-	if err := r.stream.Close(); err != nil {
-		if done != nil {
-			<-done
-		}
-		return err
+	if r.stream != nil {
+		_ = r.stream.Close()
 	}
-	if done != nil {
-		<-done
-	}
-	if r.err != nil {
-		return r.err.get()
-	}
-	return nil
+	return r.Wait()
 }
 
 // RunStreamedJSON executes a turn expecting structured JSON output and streams raw events
@@ -171,11 +334,31 @@ func RunStreamedJSON[T any](ctx context.Context, thread *Thread, input string, o
 		return RunStreamedJSONResult[T]{}, errors.New("RunStreamedJSON requires a non-nil thread")
 	}
 
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
 	raw, err := thread.runStreamed(ctx, input, nil, &config.turnOptions)
 	if err != nil {
+		cancel()
 		return RunStreamedJSONResult[T]{}, err
 	}
 
+	var (
+		onDropped     func(string)
+		blockOnEvents bool
+	)
+	if options != nil {
+		onDropped = options.OnDropped
+		blockOnEvents = options.BlockOnEvents
+	}
+	dropped := func(kind string) {
+		if onDropped != nil {
+			onDropped(kind)
+		}
+	}
+
 	events := make(chan ThreadEvent, runStreamedJSONEventBuffer)
 	updates := make(chan RunStreamedJSONUpdate[T], runStreamedJSONEventBuffer)
 	shErr := &sharedError{}
@@ -183,6 +366,7 @@ func RunStreamedJSON[T any](ctx context.Context, thread *Thread, input string, o
 
 	result := RunStreamedJSONResult[T]{
 		stream:  raw.stream,
+		cancel:  cancel,
 		events:  events,
 		updates: updates,
 		err:     shErr,
@@ -191,6 +375,7 @@ func RunStreamedJSON[T any](ctx context.Context, thread *Thread, input string, o
 
 	go func() {
 		defer close(fanoutDone)
+		defer cancel()
 		defer close(events)
 		defer close(updates)
 
@@ -201,19 +386,20 @@ func RunStreamedJSON[T any](ctx context.Context, thread *Thread, input string, o
 			switch e := event.(type) {
 			case ItemUpdatedEvent:
 				if msg, ok := e.Item.(AgentMessageItem); ok {
-					if update, decodeErr := decodeStructuredMessage[T](msg, false); decodeErr == nil {
+					if update, decodeErr := decodeStructuredMessage[T](msg, false, config); decodeErr == nil {
 						select {
 						case updates <- update:
 						case <-raw.stream.done:
 							return
 						default:
 							// Drop intermediate snapshot when the consumer ignores updates.
+							dropped(DroppedKindUpdate)
 						}
 					}
 				}
 			case ItemCompletedEvent:
 				if msg, ok := e.Item.(AgentMessageItem); ok {
-					update, decodeErr := decodeStructuredMessage[T](msg, true)
+					update, decodeErr := decodeStructuredMessage[T](msg, true, config)
 					if decodeErr != nil {
 						shErr.set(decodeErr)
 					} else {
@@ -224,6 +410,7 @@ func RunStreamedJSON[T any](ctx context.Context, thread *Thread, input string, o
 							return
 						default:
 							// Drop final snapshot when the consumer ignores updates.
+							dropped(DroppedKindFinalUpdate)
 						}
 					}
 				}
@@ -238,10 +425,20 @@ func RunStreamedJSON[T any](ctx context.Context, thread *Thread, input string, o
 				}
 			}
 
+			if blockOnEvents {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+
 			select {
 			case events <- event:
 			default:
 				// Drop events when no consumer is attached to avoid blocking snapshot updates.
+				dropped(DroppedKindEvent)
 			}
 		}
 
@@ -256,6 +453,14 @@ func RunStreamedJSON[T any](ctx context.Context, thread *Thread, input string, o
 type runJSONConfig struct {
 	turnOptions       TurnOptions
 	expectSchemaError bool
+	repairAttempts    int
+	schemaRetries     int
+	decoder           StructuredDecoderConfig
+	// wrapped reports that the inferred schema nests T under structuredValueField.
+	wrapped bool
+	// validator checks final structured output locally when RunJSONOptions.ValidateSchema
+	// is set.
+	validator *outputValidator
 }
 
 func prepareRunJSONOptions[T any](options *RunJSONOptions[T]) (runJSONConfig, error) {
@@ -264,10 +469,33 @@ func prepareRunJSONOptions[T any](options *RunJSONOptions[T]) (runJSONConfig, er
 	if options != nil && options.TurnOptions != nil {
 		config.turnOptions = *options.TurnOptions
 	}
+	if options != nil {
+		config.decoder = options.DecoderConfig
+	}
+	if options != nil && options.MaxSchemaRetries > 0 {
+		config.schemaRetries = options.MaxSchemaRetries
+	}
+	if options != nil && options.Repair {
+		config.repairAttempts = options.MaxRepairAttempts
+		if config.repairAttempts <= 0 {
+			config.repairAttempts = defaultRepairAttempts
+		}
+	}
 
 	var schema any
 	if options != nil && options.Schema != nil {
 		schema = options.Schema
+		config.turnOptions.OutputSchemaPath = ""
+	} else if config.turnOptions.OutputSchemaPath != "" {
+		config.expectSchemaError = true
+		if options != nil && options.ValidateSchema {
+			validator, err := compileOutputValidator(nil, config.turnOptions.OutputSchemaPath)
+			if err != nil {
+				return config, err
+			}
+			config.validator = validator
+		}
+		return config, nil
 	} else if config.turnOptions.OutputSchema != nil {
 		schema = config.turnOptions.OutputSchema
 	} else if options == nil || !options.DisableSchemaInference {
@@ -275,10 +503,22 @@ func prepareRunJSONOptions[T any](options *RunJSONOptions[T]) (runJSONConfig, er
 		if err != nil {
 			return config, err
 		}
+		if needsObjectWrapper(reflect.TypeOf((*T)(nil)).Elem()) {
+			inferred = wrapValueSchema(inferred)
+			config.wrapped = true
+		}
+		if options != nil {
+			if options.SchemaID != "" {
+				inferred.ID = jsonschema.ID(options.SchemaID)
+			}
+			if options.SchemaTitle != "" {
+				inferred.Title = options.SchemaTitle
+			}
+		}
 		schema = inferred
 		config.expectSchemaError = true
 	} else {
-		return config, errors.New("RunJSON requires a schema; provide RunJSONOptions.Schema or TurnOptions.OutputSchema")
+		return config, errors.New("RunJSON requires a schema; provide RunJSONOptions.Schema, TurnOptions.OutputSchema, or TurnOptions.OutputSchemaPath")
 	}
 
 	if schema == nil {
@@ -289,6 +529,13 @@ func prepareRunJSONOptions[T any](options *RunJSONOptions[T]) (runJSONConfig, er
 	if !config.expectSchemaError && schema != nil {
 		config.expectSchemaError = true
 	}
+	if options != nil && options.ValidateSchema {
+		validator, err := compileOutputValidator(schema, "")
+		if err != nil {
+			return config, err
+		}
+		config.validator = validator
+	}
 
 	return config, nil
 }
@@ -314,9 +561,17 @@ func classifyStructuredOutputError(err error, expectSchema bool) (error, bool) {
 	return nil, false
 }
 
-func decodeStructuredMessage[T any](msg AgentMessageItem, final bool) (RunStreamedJSONUpdate[T], error) {
-	var value T
-	if err := json.Unmarshal([]byte(msg.Text), &value); err != nil {
+func decodeStructuredMessage[T any](msg AgentMessageItem, final bool, config runJSONConfig) (RunStreamedJSONUpdate[T], error) {
+	if !final {
+		// Intermediate snapshots may legitimately be incomplete.
+		config.validator = nil
+	}
+	value, err := decodeStructuredValue[T](msg.Text, config)
+	if err != nil {
+		var violation *SchemaViolationError
+		if errors.As(err, &violation) {
+			return RunStreamedJSONUpdate[T]{}, err
+		}
 		if final {
 			return RunStreamedJSONUpdate[T]{}, fmt.Errorf("decode structured output: %w", err)
 		}
@@ -329,6 +584,77 @@ func decodeStructuredMessage[T any](msg AgentMessageItem, final bool) (RunStream
 	}, nil
 }
 
+// decodeStructuredValue decodes a structured response into T. When the schema wraps T, the
+// value is taken from the {"value": ...} object; a bare value is accepted too. With a
+// validator, a response that decodes but does not match the schema yields a
+// *SchemaViolationError.
+func decodeStructuredValue[T any](text string, config runJSONConfig) (T, error) {
+	var value T
+	data := []byte(text)
+	// Only output produced against the wrapped schema is unwrapped. The CLI enforces that
+	// schema, so any object is the wrapper, even when T is a map that itself has a single
+	// "value" key; a bare array is still accepted for slice and array types.
+	if config.wrapped && bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var wrapper map[string]json.RawMessage
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return value, err
+		}
+		inner, ok := wrapper[structuredValueField]
+		if !ok {
+			return value, fmt.Errorf("structured output is missing the %q wrapper property", structuredValueField)
+		}
+		data = inner
+	}
+	if err := config.decoder.unmarshal(data, &value); err != nil {
+		return value, err
+	}
+	if config.validator != nil {
+		instance := data
+		if config.wrapped {
+			instance = append(append([]byte(`{"`+structuredValueField+`":`), data...), '}')
+		}
+		if err := config.validator.validate(instance); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+	return value, nil
+}
+
+// needsObjectWrapper reports whether t is encoded as a JSON array or free-form object, which
+// the CLI cannot use as a top-level output schema.
+func needsObjectWrapper(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	default:
+		return false
+	}
+}
+
+// wrapValueSchema nests schema under a required structuredValueField property of an object
+// schema. Root keywords ($schema, $id, title and $defs, which $ref pointers resolve against)
+// stay on the wrapper.
+func wrapValueSchema(schema *jsonschema.Schema) *jsonschema.Schema {
+	inner := *schema
+	inner.Version, inner.ID, inner.Title, inner.Definitions = "", "", "", nil
+	properties := jsonschema.NewProperties()
+	properties.Set(structuredValueField, &inner)
+	return &jsonschema.Schema{
+		Version:              schema.Version,
+		ID:                   schema.ID,
+		Title:                schema.Title,
+		Definitions:          schema.Definitions,
+		Type:                 "object",
+		Properties:           properties,
+		Required:             []string{structuredValueField},
+		AdditionalProperties: jsonschema.FalseSchema,
+	}
+}
+
 func inferSchemaForType[T any]() (*jsonschema.Schema, error) {
 	t := reflect.TypeOf((*T)(nil)).Elem()
 	if t == nil {
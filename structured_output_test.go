@@ -2,8 +2,12 @@ package godex
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type structuredUpdate struct {
@@ -174,3 +178,540 @@ func TestRunStreamedJSONWaitWithoutUpdatesConsumer(t *testing.T) {
 		t.Fatalf("expected ErrNoStructuredOutput, got %v", waitErr)
 	}
 }
+
+func TestRunJSONRepairsInvalidOutput(t *testing.T) {
+	invalid := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{
+			"id":   "msg_1",
+			"type": "agent_message",
+			"text": "Sure! Here is the update: headline=Release ready",
+		}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+	repaired := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{
+			"id":   "msg_2",
+			"type": "agent_message",
+			"text": `{"headline":"Release ready","next_step":"Ship it"}`,
+		}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: invalid}, {events: repaired}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	update, err := RunJSON[structuredUpdate](context.Background(), thread, "structured", &RunJSONOptions[structuredUpdate]{
+		Repair: true,
+	})
+	if err != nil {
+		t.Fatalf("RunJSON returned error: %v", err)
+	}
+	if update.Headline != "Release ready" || update.NextStep != "Ship it" {
+		t.Fatalf("unexpected update: %+v", update)
+	}
+
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected 2 runner calls, got %d", len(runner.calls))
+	}
+	repairCall := runner.callAt(1)
+	if repairCall.ThreadID != "thread_1" {
+		t.Fatalf("expected repair turn to resume thread_1, got %q", repairCall.ThreadID)
+	}
+	if !strings.Contains(repairCall.Input, "could not be parsed as JSON") {
+		t.Fatalf("unexpected repair prompt %q", repairCall.Input)
+	}
+	if repairCall.OutputSchemaPath == "" {
+		t.Fatal("expected repair turn to keep the output schema")
+	}
+}
+
+func TestRunJSONRepairReturnsLastErrorWhenAttemptsExhausted(t *testing.T) {
+	invalid := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "msg_1", "type": "agent_message", "text": "not json"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: invalid}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	_, err := RunJSON[structuredUpdate](context.Background(), thread, "structured", &RunJSONOptions[structuredUpdate]{
+		Repair:            true,
+		MaxRepairAttempts: 1,
+	})
+	if err == nil || !strings.Contains(err.Error(), "decode structured output") {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected initial turn plus 1 repair, got %d calls", len(runner.calls))
+	}
+}
+
+func TestRunJSONAllDecodesEveryMessage(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{
+			"id":   "msg_1",
+			"type": "agent_message",
+			"text": `{"headline":"Row one","next_step":"Review"}`,
+		}},
+		{"type": "item.completed", "item": map[string]any{
+			"id":   "reasoning_1",
+			"type": "reasoning",
+			"text": "thinking about row two",
+		}},
+		{"type": "item.completed", "item": map[string]any{
+			"id":   "msg_2",
+			"type": "agent_message",
+			"text": `{"headline":"Row two","next_step":"Publish"}`,
+		}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	records, err := RunJSONAll[structuredUpdate](context.Background(), thread, "structured", nil)
+	if err != nil {
+		t.Fatalf("RunJSONAll returned error: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].Headline != "Row one" || records[1].Headline != "Row two" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestRunJSONAllInvalidMessageHandling(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "msg_1", "type": "agent_message", "text": "Processing rows..."}},
+		{"type": "item.completed", "item": map[string]any{
+			"id":   "msg_2",
+			"type": "agent_message",
+			"text": `{"headline":"Row one","next_step":"Review"}`,
+		}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: events}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	if _, err := RunJSONAll[structuredUpdate](context.Background(), thread, "structured", nil); err == nil {
+		t.Fatal("expected error for non-JSON agent message")
+	}
+
+	records, err := RunJSONAll[structuredUpdate](context.Background(), thread, "structured", &RunJSONOptions[structuredUpdate]{
+		SkipInvalidMessages: true,
+	})
+	if err != nil {
+		t.Fatalf("RunJSONAll returned error: %v", err)
+	}
+	if len(records) != 1 || records[0].Headline != "Row one" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestRunStreamedJSONReportsDroppedSnapshots(t *testing.T) {
+	const snapshots = runStreamedJSONEventBuffer + 4
+
+	raw := []map[string]any{{"type": "thread.started", "thread_id": "thread_1"}}
+	for i := 0; i < snapshots; i++ {
+		raw = append(raw, map[string]any{"type": "item.updated", "item": map[string]any{
+			"id":   "msg_1",
+			"type": "agent_message",
+			"text": `{"headline":"Draft","next_step":"Review"}`,
+		}})
+	}
+	raw = append(raw, map[string]any{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}})
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: marshalEvents(t, raw)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var (
+		mu      sync.Mutex
+		dropped = map[string]int{}
+	)
+	result, err := RunStreamedJSON[structuredUpdate](context.Background(), thread, "structured", &RunJSONOptions[structuredUpdate]{
+		OnDropped: func(kind string) {
+			mu.Lock()
+			defer mu.Unlock()
+			dropped[kind]++
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunStreamedJSON returned error: %v", err)
+	}
+	defer result.Close()
+
+	// Ignore Updates() entirely; Wait only observes the terminal error.
+	_ = result.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := dropped[DroppedKindUpdate], snapshots-runStreamedJSONEventBuffer; got != want {
+		t.Fatalf("expected %d dropped updates, got %d", want, got)
+	}
+	if dropped[DroppedKindEvent] == 0 {
+		t.Fatal("expected dropped raw events to be reported")
+	}
+}
+
+func TestRunStreamedJSONBlockOnEventsDeliversAllEvents(t *testing.T) {
+	const snapshots = runStreamedJSONEventBuffer * 3
+
+	raw := []map[string]any{{"type": "thread.started", "thread_id": "thread_1"}}
+	for i := 0; i < snapshots; i++ {
+		raw = append(raw, map[string]any{"type": "item.updated", "item": map[string]any{
+			"id":   "msg_1",
+			"type": "agent_message",
+			"text": `{"headline":"Draft","next_step":"Review"}`,
+		}})
+	}
+	raw = append(raw,
+		map[string]any{"type": "item.completed", "item": map[string]any{
+			"id":   "msg_1",
+			"type": "agent_message",
+			"text": `{"headline":"Final","next_step":"Publish"}`,
+		}},
+		map[string]any{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	)
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: marshalEvents(t, raw)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var droppedEvents int
+	result, err := RunStreamedJSON[structuredUpdate](context.Background(), thread, "structured", &RunJSONOptions[structuredUpdate]{
+		BlockOnEvents: true,
+		OnDropped: func(kind string) {
+			if kind == DroppedKindEvent {
+				droppedEvents++
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunStreamedJSON returned error: %v", err)
+	}
+	defer result.Close()
+
+	updatesDone := make(chan struct{})
+	go func() {
+		defer close(updatesDone)
+		for range result.Updates() {
+			// drain updates concurrently with events
+		}
+	}()
+
+	var received int
+	for range result.Events() {
+		received++
+	}
+	<-updatesDone
+
+	if err := result.Wait(); err != nil {
+		t.Fatalf("result.Wait returned error: %v", err)
+	}
+	if received != len(raw) {
+		t.Fatalf("expected %d events, got %d", len(raw), received)
+	}
+	if droppedEvents != 0 {
+		t.Fatalf("expected no dropped events, got %d", droppedEvents)
+	}
+}
+
+func TestRunStreamedJSONBlockOnEventsCloseUnblocks(t *testing.T) {
+	raw := []map[string]any{{"type": "thread.started", "thread_id": "thread_1"}}
+	for i := 0; i < runStreamedJSONEventBuffer*2; i++ {
+		raw = append(raw, map[string]any{"type": "turn.started"})
+	}
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: marshalEvents(t, raw)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := RunStreamedJSON[structuredUpdate](context.Background(), thread, "structured", &RunJSONOptions[structuredUpdate]{
+		BlockOnEvents: true,
+	})
+	if err != nil {
+		t.Fatalf("RunStreamedJSON returned error: %v", err)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- result.Close() }()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return while events were undrained")
+	}
+}
+
+func TestRunJSONInferredSchemaCarriesIDAndTitle(t *testing.T) {
+	config, err := prepareRunJSONOptions(&RunJSONOptions[structuredUpdate]{
+		SchemaID:    "https://schemas.example.com/update.json",
+		SchemaTitle: "Release update",
+	})
+	if err != nil {
+		t.Fatalf("prepareRunJSONOptions returned error: %v", err)
+	}
+	data, err := json.Marshal(config.turnOptions.OutputSchema)
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	if schema["$id"] != "https://schemas.example.com/update.json" || schema["title"] != "Release update" {
+		t.Fatalf("expected $id and title on the inferred schema, got %s", data)
+	}
+
+	config, err = prepareRunJSONOptions[structuredUpdate](nil)
+	if err != nil {
+		t.Fatalf("prepareRunJSONOptions returned error: %v", err)
+	}
+	data, err = json.Marshal(config.turnOptions.OutputSchema)
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	schema = nil
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	if _, ok := schema["title"]; ok {
+		t.Fatalf("expected no title unless provided, got %s", data)
+	}
+}
+
+func TestRunJSONTopLevelSliceAndMap(t *testing.T) {
+	run := func(text string) *Thread {
+		t.Helper()
+		events := marshalEvents(t, []map[string]any{
+			{"type": "thread.started", "thread_id": "thread_1"},
+			{"type": "item.completed", "item": map[string]any{"id": "msg_1", "type": "agent_message", "text": text}},
+			{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+		})
+		return newThread(&fakeRunner{t: t, batches: []fakeRun{{events: events}}}, CodexOptions{}, ThreadOptions{}, "")
+	}
+
+	for _, text := range []string{
+		`{"value":[{"headline":"One","next_step":"a"},{"headline":"Two","next_step":"b"}]}`,
+		`[{"headline":"One","next_step":"a"},{"headline":"Two","next_step":"b"}]`,
+	} {
+		updates, err := RunJSON[[]structuredUpdate](context.Background(), run(text), "list", nil)
+		if err != nil {
+			t.Fatalf("RunJSON returned error for %s: %v", text, err)
+		}
+		if len(updates) != 2 || updates[0].Headline != "One" || updates[1].NextStep != "b" {
+			t.Fatalf("unexpected updates from %s: %+v", text, updates)
+		}
+	}
+
+	counts, err := RunJSON[map[string]int](context.Background(), run(`{"value":{"go":3,"md":1}}`), "count", nil)
+	if err != nil {
+		t.Fatalf("RunJSON returned error: %v", err)
+	}
+	if len(counts) != 2 || counts["go"] != 3 || counts["md"] != 1 {
+		t.Fatalf("unexpected counts %v", counts)
+	}
+
+	// A map whose only key is "value" is unwrapped exactly once.
+	single, err := RunJSON[map[string]int](context.Background(), run(`{"value":{"value":7}}`), "count", nil)
+	if err != nil {
+		t.Fatalf("RunJSON returned error: %v", err)
+	}
+	if len(single) != 1 || single["value"] != 7 {
+		t.Fatalf("unexpected single-key map %v", single)
+	}
+
+	// Struct results are never wrapped, so a "value" field is decoded as-is.
+	type valueOnly struct {
+		Value string `json:"value"`
+	}
+	plain, err := RunJSON[valueOnly](context.Background(), run(`{"value":"kept"}`), "plain", nil)
+	if err != nil {
+		t.Fatalf("RunJSON returned error: %v", err)
+	}
+	if plain.Value != "kept" {
+		t.Fatalf("unexpected struct result %+v", plain)
+	}
+
+	config, err := prepareRunJSONOptions[[]structuredUpdate](nil)
+	if err != nil {
+		t.Fatalf("prepareRunJSONOptions returned error: %v", err)
+	}
+	data, err := json.Marshal(config.turnOptions.OutputSchema)
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	var schema struct {
+		Type       string                     `json:"type"`
+		Required   []string                   `json:"required"`
+		Defs       map[string]json.RawMessage `json:"$defs"`
+		Properties map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+	if schema.Type != "object" || len(schema.Required) != 1 || schema.Required[0] != "value" ||
+		schema.Properties["value"].Type != "array" || len(schema.Defs) == 0 {
+		t.Fatalf("expected the array schema wrapped in an object, got %s", data)
+	}
+}
+
+func TestRunJSONValidateSchemaReportsMissingProperty(t *testing.T) {
+	run := func(text string) *Thread {
+		t.Helper()
+		events := marshalEvents(t, []map[string]any{
+			{"type": "thread.started", "thread_id": "thread_1"},
+			{"type": "item.completed", "item": map[string]any{"id": "msg_1", "type": "agent_message", "text": text}},
+			{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+		})
+		return newThread(&fakeRunner{t: t, batches: []fakeRun{{events: events}}}, CodexOptions{}, ThreadOptions{}, "")
+	}
+	missing := `{"headline":"Release ready"}`
+
+	if _, err := RunJSON[structuredUpdate](context.Background(), run(missing), "structured", nil); err != nil {
+		t.Fatalf("expected RunJSON without ValidateSchema to accept the output, got %v", err)
+	}
+
+	_, err := RunJSON(context.Background(), run(missing), "structured", &RunJSONOptions[structuredUpdate]{ValidateSchema: true})
+	var violation *SchemaViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("expected SchemaViolationError, got %v", err)
+	}
+	if violation.Path != "" || !strings.Contains(violation.Message, "next_step") {
+		t.Fatalf("expected a root violation naming next_step, got %+v", violation)
+	}
+
+	list := `{"value":[{"headline":"One","next_step":"a"},{"headline":"Two"}]}`
+	_, err = RunJSON(context.Background(), run(list), "list", &RunJSONOptions[[]structuredUpdate]{ValidateSchema: true})
+	if !errors.As(err, &violation) || violation.Path != "/value/1" {
+		t.Fatalf("expected a violation at /value/1, got %v", err)
+	}
+
+	update, err := RunJSON(context.Background(), run(`{"headline":"Release ready","next_step":"Ship it"}`), "structured", &RunJSONOptions[structuredUpdate]{ValidateSchema: true})
+	if err != nil || update.NextStep != "Ship it" {
+		t.Fatalf("expected valid output to pass validation, got %+v, %v", update, err)
+	}
+}
+
+func TestRunJSONMaxSchemaRetries(t *testing.T) {
+	turn := func(text string) fakeRun {
+		return fakeRun{events: marshalEvents(t, []map[string]any{
+			{"type": "thread.started", "thread_id": "thread_1"},
+			{"type": "item.completed", "item": map[string]any{"id": "msg_1", "type": "agent_message", "text": text}},
+			{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+		})}
+	}
+	good := `{"headline":"Release ready","next_step":"Ship it"}`
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{turn(`{"headline":`), turn(good)}}
+	update, err := RunJSON(context.Background(), newThread(runner, CodexOptions{}, ThreadOptions{}, ""), "structured", &RunJSONOptions[structuredUpdate]{
+		MaxSchemaRetries: 2,
+	})
+	if err != nil {
+		t.Fatalf("RunJSON returned error: %v", err)
+	}
+	if update.NextStep != "Ship it" {
+		t.Fatalf("unexpected update: %+v", update)
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected exactly 2 turns, got %d", len(runner.calls))
+	}
+	if retry := runner.callAt(1); !strings.HasPrefix(retry.Input, "Your previous output failed validation: ") || retry.ThreadID != "thread_1" {
+		t.Fatalf("unexpected retry turn: thread %q, prompt %q", retry.ThreadID, retry.Input)
+	}
+
+	missing := `{"headline":"Release ready"}`
+	runner = &fakeRunner{t: t, batches: []fakeRun{turn(missing), turn(missing)}}
+	_, err = RunJSON(context.Background(), newThread(runner, CodexOptions{}, ThreadOptions{}, ""), "structured", &RunJSONOptions[structuredUpdate]{
+		ValidateSchema:   true,
+		MaxSchemaRetries: 1,
+	})
+	var violation *SchemaViolationError
+	if !errors.As(err, &violation) || !strings.Contains(violation.Message, "next_step") {
+		t.Fatalf("expected the last SchemaViolationError, got %v", err)
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected 2 turns before giving up, got %d", len(runner.calls))
+	}
+	if retry := runner.callAt(1); !strings.Contains(retry.Input, "next_step") {
+		t.Fatalf("expected the retry prompt to carry the violation, got %q", retry.Input)
+	}
+
+	// A repair does not use up the schema retry budget, nor the other way round.
+	runner = &fakeRunner{t: t, batches: []fakeRun{turn(`not json`), turn(missing), turn(`not json`), turn(good)}}
+	update, err = RunJSON(context.Background(), newThread(runner, CodexOptions{}, ThreadOptions{}, ""), "structured", &RunJSONOptions[structuredUpdate]{
+		Repair:            true,
+		MaxRepairAttempts: 2,
+		ValidateSchema:    true,
+		MaxSchemaRetries:  1,
+	})
+	if err != nil {
+		t.Fatalf("RunJSON returned error: %v", err)
+	}
+	if update.NextStep != "Ship it" || len(runner.calls) != 4 {
+		t.Fatalf("expected success after 4 turns, got %+v after %d", update, len(runner.calls))
+	}
+}
+
+func TestRunJSONDecoderConfig(t *testing.T) {
+	const drifted = `{"headline":"Release ready","next_step":"Ship it","priority":"high"}`
+	newRunner := func(updated, completed string) *fakeRunner {
+		t.Helper()
+		return &fakeRunner{t: t, batches: []fakeRun{{events: marshalEvents(t, []map[string]any{
+			{"type": "thread.started", "thread_id": "thread_1"},
+			{"type": "item.updated", "item": map[string]any{"id": "msg_1", "type": "agent_message", "text": updated}},
+			{"type": "item.completed", "item": map[string]any{"id": "msg_1", "type": "agent_message", "text": completed}},
+			{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+		})}}}
+	}
+	strict := StructuredDecoderConfig{DisallowUnknownFields: true}
+
+	lenient, err := RunJSON[structuredUpdate](context.Background(), newThread(newRunner(drifted, drifted), CodexOptions{}, ThreadOptions{}, ""), "structured", nil)
+	if err != nil || lenient.NextStep != "Ship it" {
+		t.Fatalf("expected the lenient path to ignore the extra field, got %+v, %v", lenient, err)
+	}
+	_, err = RunJSON(context.Background(), newThread(newRunner(drifted, drifted), CodexOptions{}, ThreadOptions{}, ""), "structured", &RunJSONOptions[structuredUpdate]{
+		DecoderConfig: strict,
+	})
+	if err == nil || !strings.Contains(err.Error(), `unknown field "priority"`) {
+		t.Fatalf("expected the strict path to reject the extra field, got %v", err)
+	}
+
+	const valid = `{"headline":"Release ready","next_step":"Ship it"}`
+	result, err := RunStreamedJSON(context.Background(), newThread(newRunner(drifted, valid), CodexOptions{}, ThreadOptions{}, ""), "structured", &RunJSONOptions[structuredUpdate]{
+		DecoderConfig: strict,
+	})
+	if err != nil {
+		t.Fatalf("RunStreamedJSON returned error: %v", err)
+	}
+	var updates []RunStreamedJSONUpdate[structuredUpdate]
+	for update := range result.Updates() {
+		updates = append(updates, update)
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+	if len(updates) != 1 || !updates[0].Final {
+		t.Fatalf("expected the drifted snapshot to be skipped in strict mode, got %+v", updates)
+	}
+
+	type counted struct {
+		Count any `json:"count"`
+	}
+	value, err := RunJSON(context.Background(), newThread(newRunner(`{}`, `{"count":9007199254740993}`), CodexOptions{}, ThreadOptions{}, ""), "count", &RunJSONOptions[counted]{
+		DecoderConfig: StructuredDecoderConfig{UseNumber: true},
+	})
+	if err != nil {
+		t.Fatalf("RunJSON returned error: %v", err)
+	}
+	if number, ok := value.Count.(json.Number); !ok || number.String() != "9007199254740993" {
+		t.Fatalf("expected an exact json.Number, got %#v", value.Count)
+	}
+}
@@ -0,0 +1,90 @@
+package godex
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrTempBytesExceeded is returned when the temporary files a turn needs would exceed
+// CodexOptions.MaxTempBytes. The turn fails before the CLI is launched.
+var ErrTempBytesExceeded = errors.New("temporary files exceed MaxTempBytes")
+
+// tempBudget sums the bytes of temporary files written for one turn and enforces an optional
+// limit. It is safe for concurrent use by parallel image downloads.
+type tempBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+}
+
+func newTempBudget(limit int64) *tempBudget {
+	return &tempBudget{limit: limit}
+}
+
+// reserve accounts for n more bytes, described by what, failing if that would go over the limit.
+func (b *tempBudget) reserve(n int64, what string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit > 0 && b.used+n > b.limit {
+		return fmt.Errorf("%w: %s needs %d bytes with %d of %d already used", ErrTempBytesExceeded, what, n, b.used, b.limit)
+	}
+	b.used += n
+	return nil
+}
+
+// reserveInputImages accounts for the temporary image files owned by segments (written by
+// BytesImageSegment or URLImageSegment); caller-provided paths are not counted, and
+// LazyURLImageSegment downloads reserve their bytes through writer as they are written.
+func (b *tempBudget) reserveInputImages(segments []InputSegment) error {
+	for i, segment := range segments {
+		if segment.cleanup == nil || segment.LocalImagePath == "" {
+			continue
+		}
+		info, err := os.Stat(segment.LocalImagePath)
+		if err != nil {
+			return fmt.Errorf("stat temp image for input segment %d: %w", i, err)
+		}
+		if err := b.reserve(info.Size(), fmt.Sprintf("image for input segment %d", i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writer wraps w so every write is reserved in the budget before it reaches w, failing once
+// the limit would be exceeded. A nil budget returns w unchanged.
+func (b *tempBudget) writer(w io.Writer, what string) io.Writer {
+	if b == nil {
+		return w
+	}
+	return &budgetWriter{budget: b, w: w, what: what}
+}
+
+type budgetWriter struct {
+	budget *tempBudget
+	w      io.Writer
+	what   string
+}
+
+func (bw *budgetWriter) Write(p []byte) (int, error) {
+	if err := bw.budget.reserve(int64(len(p)), bw.what); err != nil {
+		return 0, err
+	}
+	return bw.w.Write(p)
+}
+
+// bytes returns the number of bytes reserved so far.
+func (b *tempBudget) bytes() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
@@ -2,21 +2,83 @@ package godex
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/activadee/godex/internal/codexexec"
 )
 
+const (
+	// defaultMaxContinuations bounds TurnOptions.AutoContinue when MaxContinuations is unset.
+	defaultMaxContinuations = 3
+	continuePrompt          = "Continue exactly where you left off. Do not repeat any text you already wrote."
+
+	// defaultRunUntilMaxTurns bounds RunUntilComplete when RunUntilOptions.MaxTurns is unset.
+	defaultRunUntilMaxTurns = 10
+	defaultRunUntilPrompt   = "Continue with the task."
+)
+
+// RunUntilStopReason explains why RunUntilComplete stopped issuing turns.
+type RunUntilStopReason string
+
+const (
+	// RunUntilQuiescent means the last turn neither ran commands nor changed files.
+	RunUntilQuiescent RunUntilStopReason = "quiescent"
+	// RunUntilCompleted means RunUntilOptions.IsComplete reported the task as done.
+	RunUntilCompleted RunUntilStopReason = "completed"
+	// RunUntilMaxTurns means the turn cap was reached while the agent was still working.
+	RunUntilMaxTurns RunUntilStopReason = "max_turns"
+)
+
+// RunUntilResult aggregates the turns run by RunUntilComplete. The embedded Turn holds every
+// item in order, the summed usage, and the final response and finish reason of the last turn.
+type RunUntilResult struct {
+	Turn
+	// TurnsExecuted counts the turns that ran, including a failing one.
+	TurnsExecuted int
+	// StopReason is empty when the loop ended with an error.
+	StopReason RunUntilStopReason
+}
+
 type execRunner interface {
 	Run(context.Context, codexexec.Args, func([]byte) error) error
 }
 
 // Turn represents a fully completed turn from the Codex agent.
+//
+// Every API that returns a Turn alongside an error (Run, RunInputs, RunUntilComplete,
+// RunStreamedResult.Result and SaveTranscript) follows the same contract: on success the error
+// and Err are nil; on failure the returned Turn holds whatever the turn produced before it
+// failed (possibly nothing) and Err returns the same error, so a Turn can be handled on its own.
 type Turn struct {
 	Items         []ThreadItem
 	FinalResponse string
-	Usage         *Usage
+	// StructuredResponse holds the data of the last StructuredOutputItem completed during the
+	// turn, for turns that emit a JSON sidecar next to FinalResponse. It is nil when absent.
+	StructuredResponse json.RawMessage
+	Usage              *Usage
+	// FinishReason mirrors TurnCompletedEvent.FinishReason.
+	FinishReason string
+
+	err error
+}
+
+// Err returns the error the turn failed with, or nil when it completed successfully.
+func (t Turn) Err() error {
+	return t.err
+}
+
+// withErr returns t carrying err, keeping Err in sync with the error returned next to t.
+func (t Turn) withErr(err error) Turn {
+	t.err = err
+	return t
 }
 
 // RunResult is an alias for Turn to mirror the TypeScript SDK naming.
@@ -37,7 +99,36 @@ func (r RunStreamedResult) Events() <-chan ThreadEvent {
 	return r.stream.Events()
 }
 
-// Wait blocks until the stream finishes and returns the terminal error, if any.
+// CompletedItems returns a channel that yields only the items reported by item.completed
+// events, skipping started and updated notifications and every other event. It consumes Events,
+// so use one or the other. The channel closes when the stream ends; call Wait afterwards for
+// the terminal error.
+func (r RunStreamedResult) CompletedItems() <-chan ThreadItem {
+	out := make(chan ThreadItem)
+	if r.stream == nil {
+		close(out)
+		return out
+	}
+	events := r.stream.Events()
+	go func() {
+		defer close(out)
+		for event := range events {
+			completed, ok := event.(ItemCompletedEvent)
+			if !ok {
+				continue
+			}
+			select {
+			case out <- completed.Item:
+			case <-r.stream.closing:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Wait blocks until the stream finishes and returns the terminal error, if any. Every
+// configured callback has returned by the time Wait unblocks.
 func (r RunStreamedResult) Wait() error {
 	if r.stream == nil {
 		return nil
@@ -45,7 +136,26 @@ func (r RunStreamedResult) Wait() error {
 	return r.stream.Wait()
 }
 
-// Close cancels the stream context and waits for shutdown.
+// Result waits for the stream to finish and returns the assembled turn, matching what Run
+// would have returned for the same events. Events not yet read from Events are discarded, so
+// call it after the consumer is done with the channel.
+func (r RunStreamedResult) Result() (Turn, error) {
+	if r.stream == nil {
+		return Turn{}, nil
+	}
+	for range r.stream.Events() {
+	}
+	if err := r.stream.Wait(); err != nil {
+		// However the turn ended, it still carries the items and usage reported before the
+		// failure.
+		turn, _ := r.stream.turn.result()
+		return turn.withErr(err), err
+	}
+	return r.stream.turn.result()
+}
+
+// Close cancels the stream context and waits for shutdown. It is safe to call more than once
+// and after Wait has returned; every call returns the same error as Wait.
 func (r RunStreamedResult) Close() error {
 	if r.stream == nil {
 		return nil
@@ -53,15 +163,111 @@ func (r RunStreamedResult) Close() error {
 	return r.stream.Close()
 }
 
+// Send writes an additional user message to the CLI while the turn is running, for example to
+// steer the agent mid-turn. The turn must have been started with TurnOptions.Steering;
+// otherwise ErrSteeringDisabled is returned. Each message is written as a single line.
+func (r RunStreamedResult) Send(text string) error {
+	if r.stream == nil {
+		return ErrSteeringDisabled
+	}
+	return r.stream.send(context.Background(), text)
+}
+
+// CloseInput closes the CLI's stdin for a steering turn, signalling that no further messages
+// will be sent. It is a no-op for turns without steering.
+func (r RunStreamedResult) CloseInput() error {
+	if r.stream == nil {
+		return nil
+	}
+	return r.stream.closeInput()
+}
+
+// Interrupt asks the CLI to stop the running turn gracefully by sending it an interrupt signal.
+// Unlike Close, the process gets to report the turn's end (typically a turn.failed event) and
+// the thread stays usable for later turns. It does not wait for the turn to finish; use Wait
+// or keep reading Events. On Windows, or before the process has started, Interrupt falls back
+// to cancelling the turn's context.
+func (r RunStreamedResult) Interrupt() error {
+	if r.stream == nil {
+		return nil
+	}
+	return r.stream.interruptTurn()
+}
+
+// ErrTurnTimeout is returned when a turn exceeds TurnOptions.Timeout. It wraps
+// context.DeadlineExceeded so errors.Is matches either value, while callers can still tell a
+// turn timeout apart from their own context deadline.
+var ErrTurnTimeout = fmt.Errorf("turn timed out: %w", context.DeadlineExceeded)
+
+// ErrDuplicateThreadStarted is returned when the CLI reports a second thread.started event with
+// a different thread ID and CodexOptions.DuplicateThreadStarted is DuplicateStartedError.
+var ErrDuplicateThreadStarted = errors.New("conflicting thread.started event")
+
+// ErrThreadBusy is returned when a turn is started on a Thread while another turn on the same
+// Thread is still running. Wait for (or drain) the running turn first.
+var ErrThreadBusy = errors.New("thread already has a turn in progress")
+
+// ErrCommandNotFound is returned by RunStreamedResult.UntilCommand when the turn ends without
+// producing a matching command execution.
+var ErrCommandNotFound = errors.New("no matching command execution in stream")
+
+// UntilCommand consumes events until a command execution item satisfying match appears and
+// returns it, leaving the turn running so the caller can decide whether to Close it. Every
+// consumed event, including the match, is buffered and replayed first by a subsequent Events
+// call. Call UntilCommand before ranging over Events; the two must not run concurrently.
+func (r RunStreamedResult) UntilCommand(ctx context.Context, match func(CommandExecutionItem) bool) (CommandExecutionItem, error) {
+	if r.stream == nil || match == nil {
+		return CommandExecutionItem{}, ErrCommandNotFound
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for {
+		event, ok, err := r.stream.bufferNext(ctx)
+		if err != nil {
+			return CommandExecutionItem{}, err
+		}
+		if !ok {
+			if err := r.stream.Wait(); err != nil {
+				return CommandExecutionItem{}, err
+			}
+			return CommandExecutionItem{}, ErrCommandNotFound
+		}
+		if command, ok := eventItem(event).(CommandExecutionItem); ok && match(command) {
+			return command, nil
+		}
+	}
+}
+
+// eventItem returns the item carried by item lifecycle events, or nil for other events.
+func eventItem(event ThreadEvent) ThreadItem {
+	switch e := event.(type) {
+	case ItemStartedEvent:
+		return e.Item
+	case ItemUpdatedEvent:
+		return e.Item
+	case ItemCompletedEvent:
+		return e.Item
+	}
+	return nil
+}
+
 // Thread encapsulates a conversation with the Codex agent. It is safe to reuse a Thread
-// across sequential turns, but concurrent Run/RunStreamed calls on the same Thread are not supported.
+// across sequential turns, but only one turn may run at a time: starting a turn while another
+// is in progress returns ErrThreadBusy.
 type Thread struct {
 	exec          execRunner
 	options       CodexOptions
 	threadOptions ThreadOptions
 
-	mu sync.RWMutex
-	id string
+	// busy is set while a turn is running, from runStreamed until its stream finishes.
+	busy atomic.Bool
+
+	mu    sync.RWMutex
+	id    string
+	items []ThreadItem
+	usage Usage
 }
 
 func newThread(exec execRunner, options CodexOptions, threadOptions ThreadOptions, id string) *Thread {
@@ -73,6 +279,19 @@ func newThread(exec execRunner, options CodexOptions, threadOptions ThreadOption
 	}
 }
 
+// Clone returns a new Thread that shares t's runner and options and starts from t's current
+// thread ID, item history and usage totals. The two threads then evolve independently: turns
+// on one do not affect the other's history or busy state, and they may run concurrently. Both
+// resume the same CLI thread ID, so any branching on the CLI side follows its resume behavior.
+func (t *Thread) Clone() *Thread {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	clone := newThread(t.exec, t.options, t.threadOptions, t.id)
+	clone.items = slices.Clone(t.items)
+	clone.usage = t.usage
+	return clone
+}
+
 // ID returns the identifier of the thread. For new threads this becomes available after
 // the first `thread.started` event is received.
 func (t *Thread) ID() string {
@@ -81,6 +300,58 @@ func (t *Thread) ID() string {
 	return t.id
 }
 
+// Items returns every item completed on the thread so far, across all turns run through this
+// Thread value, in completion order. The returned slice is a copy.
+func (t *Thread) Items() []ThreadItem {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return slices.Clone(t.items)
+}
+
+// RestoreItems seeds Items with items saved from an earlier process, typically right after
+// ResumeThread and before the first turn, so the accumulated transcript continues across
+// restarts. Items the CLI replays when resuming are then recognized and not added again.
+func (t *Thread) RestoreItems(items ...ThreadItem) {
+	t.recordItems(items...)
+}
+
+func (t *Thread) recordItems(items ...ThreadItem) {
+	t.mu.Lock()
+	t.items = append(t.items, items...)
+	t.mu.Unlock()
+}
+
+// recordReplayedItems records items the CLI reported before the turn started, skipping those
+// whose type and ID match an item already accumulated: a resumed CLI may replay the history.
+// Item IDs are only compared for replays because the CLI numbers items per process, so new
+// items of different turns can share an ID.
+func (t *Thread) recordReplayedItems(items []ThreadItem) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, item := range items {
+		if id := item.itemID(); id != "" && slices.ContainsFunc(t.items, func(known ThreadItem) bool {
+			return known.itemID() == id && known.itemType() == item.itemType()
+		}) {
+			continue
+		}
+		t.items = append(t.items, item)
+	}
+}
+
+// TotalUsage returns the token usage summed over every turn completed through this Thread
+// value. Failed turns and turns answered from the response cache are not counted.
+func (t *Thread) TotalUsage() Usage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.usage
+}
+
+func (t *Thread) recordUsage(usage Usage) {
+	t.mu.Lock()
+	t.usage = t.usage.add(usage)
+	t.mu.Unlock()
+}
+
 // RunStreamed submits the provided input to the agent and streams events as they occur.
 func (t *Thread) RunStreamed(ctx context.Context, input string, turnOptions *TurnOptions) (RunStreamedResult, error) {
 	return t.runStreamed(ctx, input, nil, turnOptions)
@@ -96,6 +367,16 @@ func (t *Thread) runStreamed(ctx context.Context, baseInput string, segments []I
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	if !t.busy.CompareAndSwap(false, true) {
+		return RunStreamedResult{}, ErrThreadBusy
+	}
+	// The streaming goroutine clears busy once it starts; until then, any early return must.
+	started := false
+	defer func() {
+		if !started {
+			t.busy.Store(false)
+		}
+	}()
 
 	var turnOpts TurnOptions
 	if turnOptions != nil {
@@ -104,60 +385,140 @@ func (t *Thread) runStreamed(ctx context.Context, baseInput string, segments []I
 
 	callbacks := turnOpts.Callbacks
 
+	if err := t.threadOptions.validate(); err != nil {
+		return RunStreamedResult{}, err
+	}
+	budget := newTempBudget(t.options.MaxTempBytes)
+	if err := budget.reserveInputImages(segments); err != nil {
+		cleanupInputSegments(segments)
+		return RunStreamedResult{}, err
+	}
+	segments, err := resolveLazyImages(ctx, segments, t.options.ImageDownloadConcurrency, budget)
+	if err != nil {
+		return RunStreamedResult{}, err
+	}
 	prepared, err := normalizeInput(baseInput, segments)
 	if err != nil {
 		return RunStreamedResult{}, err
 	}
 
-	schemaPath, schemaCleanup, err := createOutputSchemaFile(turnOpts.OutputSchema)
+	schemaPath, schemaCleanup, err := resolveOutputSchemaPath(turnOpts, budget)
 	if err != nil {
 		prepared.cleanup()
 		return RunStreamedResult{}, err
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
+	currentThreadID := t.ID()
+	if currentThreadID != "" && t.threadOptions.ResumeContextFile != "" {
+		if err := validateResumeContextFile(t.threadOptions.ResumeContextFile); err != nil {
+			_ = schemaCleanup()
+			prepared.cleanup()
+			return RunStreamedResult{}, err
+		}
+	}
+
+	var cancel context.CancelFunc
+	if turnOpts.Timeout > 0 {
+		ctx, cancel = context.WithTimeoutCause(ctx, turnOpts.Timeout, ErrTurnTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
 	events := make(chan ThreadEvent)
 	stream := newStream(events, cancel)
+	if turnOpts.Steering {
+		stream.enableInput()
+	}
+	var ring *eventRing
+	if t.options.EventOverflowPolicy == EventOverflowDropOldest {
+		ring = newEventRing(t.options.EventBufferSize)
+		go ring.forward(events, stream.closing)
+	}
 
-	currentThreadID := t.ID()
+	lifecycle := &lifecycleTracker{
+		hook:      t.options.LifecycleHook,
+		threadID:  t.ID,
+		label:     turnOpts.Label,
+		metadata:  maps.Clone(turnOpts.Metadata),
+		tempBytes: budget.bytes(),
+	}
 
 	go func() {
-		defer close(events)
+		if ring != nil {
+			defer ring.close()
+		} else {
+			defer close(events)
+		}
 		defer stream.finish()
 		defer schemaCleanup()
 		defer prepared.cleanup()
-		var threadErr error
-		args := codexexec.Args{
-			Input:            prepared.prompt,
-			BaseURL:          t.options.BaseURL,
-			APIKey:           t.options.APIKey,
-			ThreadID:         currentThreadID,
-			Model:            t.threadOptions.Model,
-			SandboxMode:      string(t.threadOptions.SandboxMode),
-			WorkingDirectory: t.threadOptions.WorkingDirectory,
-			SkipGitRepoCheck: t.threadOptions.SkipGitRepoCheck,
-			OutputSchemaPath: schemaPath,
-			Images:           prepared.images,
-			ConfigOverrides:  t.options.ConfigOverrides,
+		var threadErr, turnErr, callbackErr error
+		// Items completed before turn.started may be history the CLI replays on resume; they
+		// are held back until it is clear whether the turn reports a start.
+		var turnStarted bool
+		var preStartItems []ThreadItem
+		args := t.execArgs(prepared, schemaPath, currentThreadID, turnOpts)
+		if callbacks != nil {
+			args.OnStderr = callbacks.OnStderr
 		}
+		if turnOpts.Steering {
+			args.KeepStdinOpen = true
+			args.OnStdinReady = stream.attachInput
+		}
+		args.OnInterruptReady = stream.attachInterrupt
 
+		callbackState := newCallbackState()
+		decoder := newEventDecoder(t.options)
+		lifecycle.start()
 		err := t.exec.Run(ctx, args, func(line []byte) error {
-			event, decodeErr := decodeThreadEvent(line)
+			event, decodeErr := decoder.decodeThreadEvent(line)
 			if decodeErr != nil {
 				return fmt.Errorf("parse event: %w", decodeErr)
 			}
+			if t.options.SanitizeText {
+				event = sanitizeEvent(event)
+			}
 
 			if started, ok := event.(ThreadStartedEvent); ok {
-				t.setID(started.ThreadID)
+				if keep, err := t.adoptID(ctx, started.ThreadID); !keep {
+					return err
+				}
 			}
 			if errEvent, ok := event.(ThreadErrorEvent); ok {
 				threadErr = &ThreadStreamError{ThreadError: ThreadError{Message: errEvent.Message}}
 			}
+			if failed, ok := event.(TurnFailedEvent); ok && turnErr == nil {
+				turnErr = &TurnFailedError{ThreadError: failed.Error}
+			}
+			if _, ok := event.(TurnStartedEvent); ok && !turnStarted {
+				turnStarted = true
+				t.recordReplayedItems(preStartItems)
+				preStartItems = nil
+			}
+			if completed, ok := event.(ItemCompletedEvent); ok && completed.Item != nil {
+				if turnStarted {
+					t.recordItems(completed.Item)
+				} else {
+					preStartItems = append(preStartItems, completed.Item)
+				}
+			}
+			if completed, ok := event.(TurnCompletedEvent); ok {
+				t.recordUsage(completed.Usage)
+			}
 
+			stream.turn.observe(event)
+			lifecycle.observe(event)
 			if callbacks != nil {
-				callbacks.handle(event)
+				if err := callbacks.handle(event, callbackState); err != nil {
+					callbackErr = err
+					cancel()
+					return err
+				}
 			}
 
+			if ring != nil {
+				ring.push(event)
+				return nil
+			}
 			select {
 			case events <- event:
 				return nil
@@ -166,17 +527,96 @@ func (t *Thread) runStreamed(ctx context.Context, baseInput string, segments []I
 			}
 		})
 
-		if threadErr != nil {
-			stream.setErr(threadErr)
-		} else {
-			stream.setErr(err)
+		t.recordReplayedItems(preStartItems)
+
+		// The error reported by the CLI explains the failure better than the exec error it
+		// usually causes (a non-zero exit), so a thread.error wins, then a turn.failed. A
+		// callback that aborted the turn caused the failure itself and wins over both.
+		switch {
+		case callbackErr != nil:
+			err = callbackErr
+		case threadErr != nil:
+			err = threadErr
+		case turnErr != nil:
+			err = turnErr
+		case errors.Is(err, context.DeadlineExceeded) && errors.Is(context.Cause(ctx), ErrTurnTimeout):
+			err = ErrTurnTimeout
 		}
+		// Callbacks ran inside the line handler above, so by now they have all returned;
+		// setErr must stay after exec.Run to keep that ordering for Wait.
+		lifecycle.finish(err)
+		// Release the thread before Wait unblocks so a follow-up turn can start right away.
+		t.busy.Store(false)
+		stream.setErr(err)
 	}()
+	started = true
 
 	return RunStreamedResult{stream: stream}, nil
 }
 
+// model resolves the model for the thread's turns: ThreadOptions.Model, then
+// CodexOptions.DefaultModel, then $GODEX_DEFAULT_MODEL. An empty result leaves the choice to
+// the CLI.
+func (t *Thread) model() string {
+	if t.threadOptions.Model != "" {
+		return t.threadOptions.Model
+	}
+	if model := strings.TrimSpace(t.options.DefaultModel); model != "" {
+		return model
+	}
+	return strings.TrimSpace(os.Getenv("GODEX_DEFAULT_MODEL"))
+}
+
+// execArgs assembles the CLI invocation for a turn from the thread's options.
+func (t *Thread) execArgs(prepared normalizedInput, schemaPath, threadID string, turnOpts TurnOptions) codexexec.Args {
+	return codexexec.Args{
+		Input:              prepared.prompt,
+		BaseURL:            t.options.BaseURL,
+		APIKey:             t.options.APIKey,
+		ThreadID:           threadID,
+		Model:              t.model(),
+		SandboxMode:        string(t.threadOptions.SandboxMode),
+		WorkingDirectory:   t.threadOptions.WorkingDirectory,
+		SkipGitRepoCheck:   t.threadOptions.SkipGitRepoCheck,
+		OutputSchemaPath:   schemaPath,
+		EventFlushInterval: t.threadOptions.EventFlushInterval,
+		ResumeFlag:         t.options.ResumeStyle == ResumeStyleFlag,
+		ResumeContextFile:  t.threadOptions.ResumeContextFile,
+		Images:             prepared.images,
+		ConfigOverrides:    t.options.ConfigOverrides,
+		ExtraArgs:          append(slices.Clip(t.options.ExtraArgs), turnOpts.ExtraArgs...),
+		ExtraEnv:           t.options.ExtraEnv,
+		DisableOriginator:  t.options.DisableOriginator,
+		PreferLogin:        t.options.AuthPriority == AuthPriorityLogin,
+		OnStdin:            t.options.OnStdin,
+	}
+}
+
+// DryRunArgs returns the arguments the SDK would pass to the codex binary for a turn with
+// input and turnOptions, without starting the CLI. The prompt itself is written to stdin and is
+// not part of the result. An inline TurnOptions.OutputSchema is written to a temporary file
+// that is removed before DryRunArgs returns, so its path only shows where the flag goes.
+func (t *Thread) DryRunArgs(input string, turnOptions *TurnOptions) ([]string, error) {
+	var turnOpts TurnOptions
+	if turnOptions != nil {
+		turnOpts = *turnOptions
+	}
+	if err := t.threadOptions.validate(); err != nil {
+		return nil, err
+	}
+	schemaPath, schemaCleanup, err := resolveOutputSchemaPath(turnOpts, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer schemaCleanup()
+
+	prepared := normalizedInput{prompt: input}
+	return codexexec.CommandArgs(t.execArgs(prepared, schemaPath, t.ID(), turnOpts)), nil
+}
+
 // Run submits the input to the agent and waits for the turn to finish, returning the final response.
+// When the turn fails, the returned error is accompanied by a partial Turn holding the items
+// completed so far and any usage the CLI reported for the failed turn.
 func (t *Thread) Run(ctx context.Context, input string, turnOptions *TurnOptions) (RunResult, error) {
 	return t.run(ctx, input, nil, turnOptions)
 }
@@ -186,58 +626,195 @@ func (t *Thread) RunInputs(ctx context.Context, segments []InputSegment, turnOpt
 	return t.run(ctx, "", segments, turnOptions)
 }
 
+// RunScript runs each input as a separate turn on the thread, in order, and returns the
+// completed turns. It stops at the first failing turn, returning the turns completed before it
+// alongside the error.
+func (t *Thread) RunScript(ctx context.Context, inputs []string, turnOptions *TurnOptions) ([]Turn, error) {
+	turns := make([]Turn, 0, len(inputs))
+	for i, input := range inputs {
+		turn, err := t.run(ctx, input, nil, turnOptions)
+		if err != nil {
+			return turns, fmt.Errorf("script turn %d: %w", i+1, err)
+		}
+		turns = append(turns, turn)
+	}
+	return turns, nil
+}
+
+// RunUntilComplete runs input and then keeps issuing continuation turns until a turn is
+// quiescent (it neither runs commands nor changes files), RunUntilOptions.IsComplete returns
+// true, or MaxTurns turns have run. On error it returns the turns aggregated so far.
+func (t *Thread) RunUntilComplete(ctx context.Context, input string, opts *RunUntilOptions) (RunUntilResult, error) {
+	var options RunUntilOptions
+	if opts != nil {
+		options = *opts
+	}
+	maxTurns := options.MaxTurns
+	if maxTurns <= 0 {
+		maxTurns = defaultRunUntilMaxTurns
+	}
+	prompt := options.ContinuePrompt
+	if prompt == "" {
+		prompt = defaultRunUntilPrompt
+	}
+
+	var result RunUntilResult
+	for result.TurnsExecuted < maxTurns {
+		if result.TurnsExecuted > 0 {
+			input = prompt
+		}
+		turn, err := t.run(ctx, input, nil, options.Turn)
+		result.TurnsExecuted++
+		result.Items = append(result.Items, turn.Items...)
+		result.Usage = addUsage(result.Usage, turn.Usage)
+		if err != nil {
+			err = fmt.Errorf("turn %d: %w", result.TurnsExecuted, err)
+			result.Turn = result.Turn.withErr(err)
+			return result, err
+		}
+		result.FinalResponse = turn.FinalResponse
+		result.StructuredResponse = turn.StructuredResponse
+		result.FinishReason = turn.FinishReason
+
+		if options.IsComplete != nil && options.IsComplete(turn) {
+			result.StopReason = RunUntilCompleted
+			return result, nil
+		}
+		if !madeProgress(turn) {
+			result.StopReason = RunUntilQuiescent
+			return result, nil
+		}
+	}
+	result.StopReason = RunUntilMaxTurns
+	return result, nil
+}
+
+// madeProgress reports whether turn ran a command or changed a file.
+func madeProgress(turn Turn) bool {
+	for _, item := range turn.Items {
+		switch item.(type) {
+		case CommandExecutionItem, FileChangeItem:
+			return true
+		}
+	}
+	return false
+}
+
 func (t *Thread) run(ctx context.Context, baseInput string, segments []InputSegment, turnOptions *TurnOptions) (RunResult, error) {
-	result, err := t.runStreamed(ctx, baseInput, segments, turnOptions)
-	if err != nil {
-		return RunResult{}, err
+	cache := t.options.ResponseCache
+	if cache == nil {
+		turn, err := t.runContinued(ctx, baseInput, segments, turnOptions)
+		return turn.withErr(err), err
 	}
-	defer result.Close()
 
-	var (
-		items        []ThreadItem
-		finalMessage string
-		varUsage     *Usage
-		turnFailure  *ThreadError
-	)
-
-	for event := range result.Events() {
-		switch e := event.(type) {
-		case ItemCompletedEvent:
-			items = append(items, e.Item)
-			if message, ok := e.Item.(AgentMessageItem); ok {
-				finalMessage = message.Text
-			}
-		case TurnCompletedEvent:
-			usageCopy := e.Usage
-			varUsage = &usageCopy
-		case TurnFailedEvent:
-			turnFailure = &e.Error
-		case ThreadErrorEvent:
-			return RunResult{}, &ThreadStreamError{ThreadError: ThreadError{Message: e.Message}}
+	key, ok := t.responseCacheKey(baseInput, segments, turnOptions)
+	if ok {
+		if turn, hit := cache.Get(key); hit {
+			t.recordItems(turn.Items...)
+			return turn, nil
+		}
+	}
+	turn, err := t.runContinued(ctx, baseInput, segments, turnOptions)
+	if err == nil && ok {
+		cache.Set(key, turn)
+	}
+	return turn.withErr(err), err
+}
+
+// runContinued runs a turn, issuing follow-up turns when TurnOptions.AutoContinue is set.
+func (t *Thread) runContinued(ctx context.Context, baseInput string, segments []InputSegment, turnOptions *TurnOptions) (RunResult, error) {
+	turn, err := t.runTurn(ctx, baseInput, segments, turnOptions)
+	if err != nil || turnOptions == nil || !turnOptions.AutoContinue {
+		return turn, err
+	}
+
+	maxContinuations := turnOptions.MaxContinuations
+	if maxContinuations <= 0 {
+		maxContinuations = defaultMaxContinuations
+	}
+
+	for i := 0; i < maxContinuations && turn.FinishReason == FinishReasonLength; i++ {
+		next, err := t.runTurn(ctx, continuePrompt, nil, turnOptions)
+		turn.Items = append(turn.Items, next.Items...)
+		turn.Usage = addUsage(turn.Usage, next.Usage)
+		if err != nil {
+			return turn, err
+		}
+		turn.FinalResponse += next.FinalResponse
+		if next.StructuredResponse != nil {
+			turn.StructuredResponse = next.StructuredResponse
 		}
+		turn.FinishReason = next.FinishReason
+	}
+
+	return turn, nil
+}
 
-		if turnFailure != nil {
-			break
+// addUsage sums two usage reports, treating nil as zero.
+func addUsage(a, b *Usage) *Usage {
+	if a == nil && b == nil {
+		return nil
+	}
+	var total Usage
+	for _, usage := range []*Usage{a, b} {
+		if usage == nil {
+			continue
 		}
+		total = total.add(*usage)
 	}
+	return &total
+}
 
-	if err := result.Wait(); err != nil {
+func (t *Thread) runTurn(ctx context.Context, baseInput string, segments []InputSegment, turnOptions *TurnOptions) (RunResult, error) {
+	result, err := t.runStreamed(ctx, baseInput, segments, turnOptions)
+	if err != nil {
 		return RunResult{}, err
 	}
+	defer result.Close()
+	// Nothing can steer a blocking run, so let a steering-mode CLI see EOF right away.
+	_ = result.CloseInput()
 
-	if turnFailure != nil {
-		return RunResult{}, fmt.Errorf(turnFailure.Message)
+	return result.Result()
+}
+
+// validate rejects thread options that cannot be forwarded to the CLI.
+func (o ThreadOptions) validate() error {
+	if o.EventFlushInterval < 0 {
+		return fmt.Errorf("ThreadOptions.EventFlushInterval must not be negative, got %s", o.EventFlushInterval)
 	}
+	return nil
+}
 
-	return RunResult{
-		Items:         items,
-		FinalResponse: finalMessage,
-		Usage:         varUsage,
-	}, nil
+func validateResumeContextFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("resume context file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("resume context file %q is not a regular file", path)
+	}
+	return nil
 }
 
-func (t *Thread) setID(id string) {
+// adoptID records id from a thread.started event. The first ID wins: a conflicting ID is
+// rejected according to CodexOptions.DuplicateThreadStarted. keep reports whether the event
+// should still be delivered; when it is false, a non-nil err aborts the turn.
+func (t *Thread) adoptID(ctx context.Context, id string) (keep bool, err error) {
 	t.mu.Lock()
-	defer t.mu.Unlock()
-	t.id = id
+	current := t.id
+	if current == "" || current == id {
+		t.id = id
+		t.mu.Unlock()
+		return true, nil
+	}
+	t.mu.Unlock()
+
+	if t.options.DuplicateThreadStarted == DuplicateStartedError {
+		return false, fmt.Errorf("%w: got %q, thread is %q", ErrDuplicateThreadStarted, id, current)
+	}
+	if logger := t.options.Logger; logger != nil {
+		logger.WarnContext(ctx, "ignoring thread.started event with a conflicting thread ID",
+			"thread_id", current, "event_thread_id", id)
+	}
+	return false, nil
 }
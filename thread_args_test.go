@@ -1,9 +1,19 @@
 package godex
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/activadee/godex/internal/codexexec"
 )
 
 func TestThreadRunForwardsThreadOptions(t *testing.T) {
@@ -123,3 +133,694 @@ func TestThreadRunStreamedCleansOutputSchemaFile(t *testing.T) {
 		t.Fatalf("expected schema file to be cleaned up, stat error: %v", statErr)
 	}
 }
+
+func TestThreadRunStreamedForwardsOutputSchemaPath(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(schemaPath, []byte(`{"type":"object"}`), 0o600); err != nil {
+		t.Fatalf("write schema: %v", err)
+	}
+
+	result, err := thread.RunStreamed(context.Background(), "structured", &TurnOptions{OutputSchemaPath: schemaPath})
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+
+	for range result.Events() {
+		// drain events
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("result.Wait returned error: %v", err)
+	}
+
+	call := runner.lastCall()
+	if call.OutputSchemaPath != schemaPath {
+		t.Fatalf("expected OutputSchemaPath %q, got %q", schemaPath, call.OutputSchemaPath)
+	}
+	if _, statErr := os.Stat(schemaPath); statErr != nil {
+		t.Fatalf("expected caller-owned schema file to remain, stat error: %v", statErr)
+	}
+}
+
+func TestThreadRunRejectsOutputSchemaAndPath(t *testing.T) {
+	runner := &fakeRunner{t: t}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	_, err := thread.Run(context.Background(), "structured", &TurnOptions{
+		OutputSchema:     map[string]any{"type": "object"},
+		OutputSchemaPath: "/tmp/schema.json",
+	})
+	if err == nil {
+		t.Fatal("expected error when both OutputSchema and OutputSchemaPath are set")
+	}
+	if !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runner.calls) != 0 {
+		t.Fatalf("expected runner not to be invoked, got %d calls", len(runner.calls))
+	}
+}
+
+func TestThreadRunValidatesResumeContextFileOnResume(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.jsonl")
+
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+	fresh := newThread(runner, CodexOptions{}, ThreadOptions{ResumeContextFile: missing}, "")
+	if _, err := fresh.Run(context.Background(), "hello", nil); err != nil {
+		t.Fatalf("expected new thread to ignore resume context file, got %v", err)
+	}
+
+	resumed := newThread(runner, CodexOptions{}, ThreadOptions{ResumeContextFile: missing}, "thread_1")
+	if _, err := resumed.Run(context.Background(), "hello", nil); err == nil {
+		t.Fatal("expected error for missing resume context file")
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("expected runner to be skipped for invalid resume file, got %d calls", len(runner.calls))
+	}
+}
+
+func TestThreadRunPropagatesFinishReason(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "Partial"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}, "finish_reason": "length"},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}, {events: successEvents(t)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	turn, err := thread.Run(context.Background(), "hello", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.FinishReason != FinishReasonLength {
+		t.Fatalf("expected finish reason %q, got %q", FinishReasonLength, turn.FinishReason)
+	}
+
+	turn, err = thread.Run(context.Background(), "again", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.FinishReason != "" {
+		t.Fatalf("expected empty finish reason when omitted, got %q", turn.FinishReason)
+	}
+}
+
+func TestThreadRunAutoContinueConcatenatesTruncatedResponses(t *testing.T) {
+	truncated := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "Once upon "}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 10, "cached_input_tokens": 2, "output_tokens": 5}, "finish_reason": "length"},
+	})
+	completed := marshalEvents(t, []map[string]any{
+		{"type": "item.completed", "item": map[string]any{"id": "item_2", "type": "agent_message", "text": "a time."}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 20, "cached_input_tokens": 3, "output_tokens": 4}, "finish_reason": "stop"},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: truncated}, {events: completed}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	turn, err := thread.Run(context.Background(), "tell a story", &TurnOptions{AutoContinue: true})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.FinalResponse != "Once upon a time." {
+		t.Fatalf("unexpected concatenated response %q", turn.FinalResponse)
+	}
+	if turn.FinishReason != FinishReasonStop {
+		t.Fatalf("expected final finish reason %q, got %q", FinishReasonStop, turn.FinishReason)
+	}
+	if len(turn.Items) != 2 {
+		t.Fatalf("expected items from both turns, got %d", len(turn.Items))
+	}
+	want := Usage{InputTokens: 30, CachedInputTokens: 5, OutputTokens: 9}
+	if turn.Usage == nil || *turn.Usage != want {
+		t.Fatalf("expected summed usage %+v, got %+v", want, turn.Usage)
+	}
+
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected one continuation turn, got %d calls", len(runner.calls))
+	}
+	continuation := runner.callAt(1)
+	if continuation.Input != continuePrompt {
+		t.Fatalf("expected continuation prompt, got %q", continuation.Input)
+	}
+	if continuation.ThreadID != "thread_1" {
+		t.Fatalf("expected continuation to resume thread_1, got %q", continuation.ThreadID)
+	}
+}
+
+func TestThreadRunAutoContinueStopsAtMaxContinuations(t *testing.T) {
+	truncated := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "more"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}, "finish_reason": "length"},
+	})
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: truncated}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	turn, err := thread.Run(context.Background(), "go", &TurnOptions{AutoContinue: true, MaxContinuations: 2})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if len(runner.calls) != 3 {
+		t.Fatalf("expected initial turn plus two continuations, got %d calls", len(runner.calls))
+	}
+	if turn.FinalResponse != "moremoremore" {
+		t.Fatalf("unexpected concatenated response %q", turn.FinalResponse)
+	}
+	if turn.FinishReason != FinishReasonLength {
+		t.Fatalf("expected truncated finish reason to be reported, got %q", turn.FinishReason)
+	}
+}
+
+func TestThreadRunRejectsOversizedSchemaBeforeStartingCLI(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	huge := map[string]any{"type": "object", "description": strings.Repeat("x", defaultMaxSchemaBytes)}
+	_, err := thread.Run(context.Background(), "hello", &TurnOptions{OutputSchema: huge})
+	if !errors.Is(err, ErrSchemaTooLarge) {
+		t.Fatalf("expected ErrSchemaTooLarge, got %v", err)
+	}
+	if len(runner.calls) != 0 {
+		t.Fatalf("expected CLI not to run, got %d calls", len(runner.calls))
+	}
+}
+
+func TestThreadRunScriptRunsInputsSequentially(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	inputs := []string{"step one", "step two", "step three"}
+	turns, err := thread.RunScript(context.Background(), inputs, nil)
+	if err != nil {
+		t.Fatalf("RunScript returned error: %v", err)
+	}
+	if len(turns) != len(inputs) {
+		t.Fatalf("expected %d turns, got %d", len(inputs), len(turns))
+	}
+
+	for i, input := range inputs {
+		call := runner.callAt(i)
+		if call.Input != input {
+			t.Fatalf("call %d: expected input %q, got %q", i, input, call.Input)
+		}
+		wantThreadID := "thread_1"
+		if i == 0 {
+			wantThreadID = ""
+		}
+		if call.ThreadID != wantThreadID {
+			t.Fatalf("call %d: expected thread id %q, got %q", i, wantThreadID, call.ThreadID)
+		}
+	}
+}
+
+func TestThreadRunScriptStopsOnFirstError(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{
+		{events: successEvents(t)},
+		{events: threadErrorEvents(t)},
+		{events: successEvents(t)},
+	}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	turns, err := thread.RunScript(context.Background(), []string{"one", "two", "three"}, nil)
+	var streamErr *ThreadStreamError
+	if !errors.As(err, &streamErr) {
+		t.Fatalf("expected ThreadStreamError, got %v", err)
+	}
+	if len(turns) != 1 {
+		t.Fatalf("expected the completed turn before the failure, got %d", len(turns))
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected script to stop after the failing turn, got %d calls", len(runner.calls))
+	}
+}
+
+func TestThreadRunForwardsResumeStyle(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+
+	positional := newThread(runner, CodexOptions{}, ThreadOptions{}, "thread_1")
+	if _, err := positional.Run(context.Background(), "hello", nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if runner.lastCall().ResumeFlag {
+		t.Fatal("expected positional resume by default")
+	}
+
+	flag := newThread(runner, CodexOptions{ResumeStyle: ResumeStyleFlag}, ThreadOptions{}, "thread_1")
+	if _, err := flag.Run(context.Background(), "hello", nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if !runner.lastCall().ResumeFlag {
+		t.Fatal("expected flag-style resume when ResumeStyleFlag is set")
+	}
+}
+
+func TestThreadRunForwardsExtraArgs(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+	options := CodexOptions{ExtraArgs: []string{"--global-flag"}}
+	thread := newThread(runner, options, ThreadOptions{}, "")
+
+	if _, err := thread.Run(context.Background(), "hello", &TurnOptions{ExtraArgs: []string{"--turn-flag", "1"}}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	expected := []string{"--global-flag", "--turn-flag", "1"}
+	if got := runner.lastCall().ExtraArgs; !slices.Equal(got, expected) {
+		t.Fatalf("expected extra args %v, got %v", expected, got)
+	}
+
+	if _, err := thread.Run(context.Background(), "again", nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := runner.lastCall().ExtraArgs; !slices.Equal(got, []string{"--global-flag"}) {
+		t.Fatalf("expected turn extra args not to leak into later turns, got %v", got)
+	}
+	if len(options.ExtraArgs) != 1 {
+		t.Fatalf("expected CodexOptions.ExtraArgs to be left untouched, got %v", options.ExtraArgs)
+	}
+}
+
+func TestThreadItemsAccumulatesAcrossTurns(t *testing.T) {
+	turnEvents := func(text string) [][]byte {
+		return marshalEvents(t, []map[string]any{
+			{"type": "thread.started", "thread_id": "thread_1"},
+			{"type": "item.completed", "item": map[string]any{"id": text, "type": "agent_message", "text": text}},
+			{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+		})
+	}
+	runner := &fakeRunner{t: t, batches: []fakeRun{
+		{events: turnEvents("first answer")},
+		{events: turnEvents("second answer")},
+	}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	for _, input := range []string{"one", "two"} {
+		if _, err := thread.Run(context.Background(), input, nil); err != nil {
+			t.Fatalf("Run(%q) returned error: %v", input, err)
+		}
+	}
+
+	var texts []string
+	for _, item := range thread.Items() {
+		message, ok := item.(AgentMessageItem)
+		if !ok {
+			t.Fatalf("unexpected item %T", item)
+		}
+		texts = append(texts, message.Text)
+	}
+	if expected := []string{"first answer", "second answer"}; !slices.Equal(texts, expected) {
+		t.Fatalf("expected items %v, got %v", expected, texts)
+	}
+}
+
+func TestThreadTotalUsageSumsCompletedTurns(t *testing.T) {
+	turnEvents := func(input, cached, output int) [][]byte {
+		return marshalEvents(t, []map[string]any{
+			{"type": "thread.started", "thread_id": "thread_1"},
+			{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "ok"}},
+			{"type": "turn.completed", "usage": map[string]any{"input_tokens": input, "cached_input_tokens": cached, "output_tokens": output}},
+		})
+	}
+	runner := &fakeRunner{t: t, batches: []fakeRun{
+		{events: turnEvents(100, 40, 10)},
+		{events: turnEvents(250, 200, 30)},
+	}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	if usage := thread.TotalUsage(); usage != (Usage{}) {
+		t.Fatalf("expected zero usage before any turn, got %+v", usage)
+	}
+	for _, input := range []string{"one", "two"} {
+		if _, err := thread.Run(context.Background(), input, nil); err != nil {
+			t.Fatalf("Run(%q) returned error: %v", input, err)
+		}
+	}
+
+	expected := Usage{InputTokens: 350, CachedInputTokens: 240, OutputTokens: 40}
+	if usage := thread.TotalUsage(); usage != expected {
+		t.Fatalf("expected total usage %+v, got %+v", expected, usage)
+	}
+}
+
+func TestThreadRunCapturesStructuredResponse(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "Deployed 3 services."}},
+		{"type": "item.completed", "item": map[string]any{"id": "item_2", "type": "structured_output", "data": map[string]any{"deployed": 3}}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}, {events: successEvents(t)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	turn, err := thread.Run(context.Background(), "deploy", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if turn.FinalResponse != "Deployed 3 services." {
+		t.Fatalf("unexpected final response %q", turn.FinalResponse)
+	}
+	if string(turn.StructuredResponse) != `{"deployed":3}` {
+		t.Fatalf("unexpected structured response %s", turn.StructuredResponse)
+	}
+
+	plain, err := thread.Run(context.Background(), "again", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if plain.StructuredResponse != nil {
+		t.Fatalf("expected nil structured response without a structured item, got %s", plain.StructuredResponse)
+	}
+}
+
+func TestThreadKeepsFirstIDOnDuplicateThreadStarted(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "thread.started", "thread_id": "thread_2"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "Hello"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	t.Run("ignore by default", func(t *testing.T) {
+		var logs bytes.Buffer
+		runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+		thread := newThread(runner, CodexOptions{Logger: slog.New(slog.NewTextHandler(&logs, nil))}, ThreadOptions{}, "")
+
+		result, err := thread.RunStreamed(context.Background(), "hi", nil)
+		if err != nil {
+			t.Fatalf("RunStreamed returned error: %v", err)
+		}
+		var started int
+		for event := range result.Events() {
+			if _, ok := event.(ThreadStartedEvent); ok {
+				started++
+			}
+		}
+		if err := result.Wait(); err != nil {
+			t.Fatalf("Wait returned error: %v", err)
+		}
+		if started != 1 {
+			t.Fatalf("expected the conflicting thread.started to be dropped, saw %d", started)
+		}
+		if thread.ID() != "thread_1" {
+			t.Fatalf("expected first thread ID to be kept, got %q", thread.ID())
+		}
+		if !strings.Contains(logs.String(), "thread_2") {
+			t.Fatalf("expected the ignored event to be logged, got %q", logs.String())
+		}
+	})
+
+	t.Run("error policy", func(t *testing.T) {
+		runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+		thread := newThread(runner, CodexOptions{DuplicateThreadStarted: DuplicateStartedError}, ThreadOptions{}, "")
+
+		_, err := thread.Run(context.Background(), "hi", nil)
+		if !errors.Is(err, ErrDuplicateThreadStarted) {
+			t.Fatalf("expected ErrDuplicateThreadStarted, got %v", err)
+		}
+		if thread.ID() != "thread_1" {
+			t.Fatalf("expected first thread ID to be kept, got %q", thread.ID())
+		}
+	})
+}
+
+func TestThreadRunUntilCompleteStopsWhenQuiescent(t *testing.T) {
+	usage := map[string]any{"input_tokens": 10, "cached_input_tokens": 0, "output_tokens": 2}
+	working := func(id string) [][]byte {
+		return marshalEvents(t, []map[string]any{
+			{"type": "thread.started", "thread_id": "thread_1"},
+			{"type": "item.completed", "item": map[string]any{"id": id, "type": "command_execution", "command": "go test ./...", "aggregated_output": "ok", "status": "completed"}},
+			{"type": "item.completed", "item": map[string]any{"id": id + "_msg", "type": "agent_message", "text": "still working"}},
+			{"type": "turn.completed", "usage": usage},
+		})
+	}
+	quiescent := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "done_msg", "type": "agent_message", "text": "All done."}},
+		{"type": "turn.completed", "usage": usage},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{
+		{events: working("cmd_1")},
+		{events: working("cmd_2")},
+		{events: quiescent},
+		{events: working("cmd_unused")},
+	}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunUntilComplete(context.Background(), "fix the build", &RunUntilOptions{ContinuePrompt: "keep going"})
+	if err != nil {
+		t.Fatalf("RunUntilComplete returned error: %v", err)
+	}
+	if result.TurnsExecuted != 3 || result.StopReason != RunUntilQuiescent {
+		t.Fatalf("expected 3 turns ending quiescent, got %d (%s)", result.TurnsExecuted, result.StopReason)
+	}
+	if len(result.Items) != 5 {
+		t.Fatalf("expected 5 aggregated items, got %d", len(result.Items))
+	}
+	if result.FinalResponse != "All done." {
+		t.Fatalf("unexpected final response %q", result.FinalResponse)
+	}
+	if result.Usage == nil || result.Usage.InputTokens != 30 || result.Usage.OutputTokens != 6 {
+		t.Fatalf("expected summed usage, got %+v", result.Usage)
+	}
+
+	var inputs []string
+	for _, call := range runner.calls {
+		inputs = append(inputs, call.Input)
+	}
+	if expected := []string{"fix the build", "keep going", "keep going"}; !slices.Equal(inputs, expected) {
+		t.Fatalf("expected inputs %v, got %v", expected, inputs)
+	}
+}
+
+func TestThreadRunUntilCompleteHonoursMaxTurnsAndIsComplete(t *testing.T) {
+	working := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "fc_1", "type": "file_change", "changes": []map[string]any{{"path": "a.go", "kind": "update"}}, "status": "completed"}},
+		{"type": "item.completed", "item": map[string]any{"id": "msg_1", "type": "agent_message", "text": "progress"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: working}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+	result, err := thread.RunUntilComplete(context.Background(), "go", &RunUntilOptions{MaxTurns: 2})
+	if err != nil {
+		t.Fatalf("RunUntilComplete returned error: %v", err)
+	}
+	if result.TurnsExecuted != 2 || result.StopReason != RunUntilMaxTurns {
+		t.Fatalf("expected the cap of 2 turns, got %d (%s)", result.TurnsExecuted, result.StopReason)
+	}
+
+	runner = &fakeRunner{t: t, defaults: fakeRun{events: working}}
+	thread = newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+	result, err = thread.RunUntilComplete(context.Background(), "go", &RunUntilOptions{
+		IsComplete: func(turn Turn) bool { return turn.FinalResponse == "progress" },
+	})
+	if err != nil {
+		t.Fatalf("RunUntilComplete returned error: %v", err)
+	}
+	if result.TurnsExecuted != 1 || result.StopReason != RunUntilCompleted {
+		t.Fatalf("expected completion after 1 turn, got %d (%s)", result.TurnsExecuted, result.StopReason)
+	}
+}
+
+func TestThreadDryRunArgsMatchesCLIInvocation(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+	options := CodexOptions{ConfigOverrides: map[string]any{
+		"profile":         "ci",
+		"model_verbosity": "low",
+		"approval_policy": "never",
+	}}
+	thread := newThread(runner, options, ThreadOptions{Model: "gpt-test-1", SandboxMode: SandboxModeReadOnly}, "thread_9")
+	turnOpts := &TurnOptions{ExtraArgs: []string{"--json-extra"}}
+
+	args, err := thread.DryRunArgs("inspect", turnOpts)
+	if err != nil {
+		t.Fatalf("DryRunArgs returned error: %v", err)
+	}
+	expected := []string{
+		"exec", "--experimental-json",
+		"--profile", "ci",
+		"-c", "approval_policy=never",
+		"-c", "model_verbosity=low",
+		"--model", "gpt-test-1",
+		"--sandbox", "read-only",
+		"--json-extra",
+		"resume", "thread_9",
+	}
+	if !slices.Equal(args, expected) {
+		t.Fatalf("DryRunArgs = %v, want %v", args, expected)
+	}
+	if len(runner.calls) != 0 {
+		t.Fatalf("DryRunArgs must not start the CLI, got %d runs", len(runner.calls))
+	}
+
+	if _, err := thread.Run(context.Background(), "inspect", turnOpts); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if actual := codexexec.CommandArgs(runner.lastCall()); !slices.Equal(actual, expected) {
+		t.Fatalf("Run used %v, DryRunArgs reported %v", actual, expected)
+	}
+}
+
+func TestThreadForwardsEventFlushInterval(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{EventFlushInterval: 20 * time.Millisecond}, "")
+
+	if _, err := thread.Run(context.Background(), "hello", nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := runner.lastCall().EventFlushInterval; got != 20*time.Millisecond {
+		t.Fatalf("expected flush interval to be forwarded, got %s", got)
+	}
+
+	invalid := newThread(runner, CodexOptions{}, ThreadOptions{EventFlushInterval: -time.Millisecond}, "")
+	if _, err := invalid.Run(context.Background(), "hello", nil); err == nil {
+		t.Fatal("expected a negative flush interval to be rejected")
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("expected the invalid turn not to start the CLI, got %d runs", len(runner.calls))
+	}
+}
+
+func TestThreadCloneBranchesIndependently(t *testing.T) {
+	turnEvents := func(text string) [][]byte {
+		return marshalEvents(t, []map[string]any{
+			{"type": "thread.started", "thread_id": "thread_1"},
+			{"type": "item.completed", "item": map[string]any{"id": text, "type": "agent_message", "text": text}},
+			{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+		})
+	}
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: turnEvents("root")}}, defaults: fakeRun{events: turnEvents("branch")}}
+	original := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+	if _, err := original.Run(context.Background(), "start", nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	clone := original.Clone()
+	if clone.ID() != "thread_1" {
+		t.Fatalf("expected clone to carry the thread ID, got %q", clone.ID())
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, thread := range []*Thread{original, clone} {
+		wg.Add(1)
+		go func(i int, thread *Thread) {
+			defer wg.Done()
+			_, errs[i] = thread.Run(context.Background(), "follow up", nil)
+		}(i, thread)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("branch %d returned error: %v", i, err)
+		}
+	}
+
+	for _, call := range runner.calls[1:] {
+		if call.ThreadID != "thread_1" {
+			t.Fatalf("expected both branches to resume thread_1, got %q", call.ThreadID)
+		}
+	}
+	for name, thread := range map[string]*Thread{"original": original, "clone": clone} {
+		if items := thread.Items(); len(items) != 2 {
+			t.Fatalf("%s: expected its own 2-item history, got %d items", name, len(items))
+		}
+		if usage := thread.TotalUsage(); usage.InputTokens != 2 {
+			t.Fatalf("%s: expected independent usage totals, got %+v", name, usage)
+		}
+	}
+}
+
+func TestThreadModelFallsBackToDefaultAndEnv(t *testing.T) {
+	t.Setenv("GODEX_DEFAULT_MODEL", "env-model")
+
+	cases := []struct {
+		name     string
+		options  CodexOptions
+		thread   ThreadOptions
+		expected string
+	}{
+		{name: "explicit model wins", options: CodexOptions{DefaultModel: "default-model"}, thread: ThreadOptions{Model: "explicit-model"}, expected: "explicit-model"},
+		{name: "default model before env", options: CodexOptions{DefaultModel: "default-model"}, expected: "default-model"},
+		{name: "env fallback", expected: "env-model"},
+	}
+	for _, tc := range cases {
+		runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+		thread := newThread(runner, tc.options, tc.thread, "")
+		if _, err := thread.Run(context.Background(), "hello", nil); err != nil {
+			t.Fatalf("%s: Run returned error: %v", tc.name, err)
+		}
+		if got := runner.lastCall().Model; got != tc.expected {
+			t.Fatalf("%s: expected model %q, got %q", tc.name, tc.expected, got)
+		}
+	}
+
+	t.Setenv("GODEX_DEFAULT_MODEL", "")
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+	if _, err := thread.Run(context.Background(), "hello", nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if got := runner.lastCall().Model; got != "" {
+		t.Fatalf("expected the CLI to pick the model, got %q", got)
+	}
+}
+
+func TestResumedThreadSkipsReplayedItems(t *testing.T) {
+	history := []ThreadItem{
+		AgentMessageItem{ID: "item_0", Type: string(ThreadItemTypeAgentMessage), Text: "first answer"},
+		CommandExecutionItem{ID: "item_1", Type: string(ThreadItemTypeCommandExecution), Command: "ls", Status: CommandExecutionStatusCompleted},
+	}
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_0", "type": "agent_message", "text": "first answer"}},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "command_execution", "command": "ls", "aggregated_output": "", "status": "completed"}},
+		{"type": "turn.started"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_0", "type": "agent_message", "text": "second answer"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "thread_1")
+	thread.RestoreItems(history...)
+
+	if _, err := thread.Run(context.Background(), "continue", nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	items := thread.Items()
+	if len(items) != 3 {
+		t.Fatalf("expected the 2 restored items plus 1 new item, got %d: %+v", len(items), items)
+	}
+	if message, ok := items[2].(AgentMessageItem); !ok || message.Text != "second answer" {
+		t.Fatalf("expected the new answer last, got %+v", items[2])
+	}
+}
+
+func TestResumedThreadSkipsReplayedItemsWithoutTurnStarted(t *testing.T) {
+	history := []ThreadItem{
+		AgentMessageItem{ID: "item_0", Type: string(ThreadItemTypeAgentMessage), Text: "first answer"},
+	}
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_0", "type": "agent_message", "text": "first answer"}},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "second answer"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "thread_1")
+	thread.RestoreItems(history...)
+
+	if _, err := thread.Run(context.Background(), "continue", nil); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	items := thread.Items()
+	if len(items) != 2 {
+		t.Fatalf("expected the restored item plus 1 new item, got %d: %+v", len(items), items)
+	}
+	if message, ok := items[1].(AgentMessageItem); !ok || message.Text != "second answer" {
+		t.Fatalf("expected the new answer last, got %+v", items[1])
+	}
+}
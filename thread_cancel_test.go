@@ -62,6 +62,64 @@ func TestThreadRunStreamedCancellationTerminatesProcess(t *testing.T) {
 	waitForProcessExit(t, pid)
 }
 
+func TestThreadRunStreamedInterruptEndsTurnGracefully(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupt integration test relies on unix signals")
+	}
+
+	fakeBinary := buildFakeCodexBinary(t)
+
+	runner, err := codexexec.New(codexexec.RunnerOptions{PathOverride: fakeBinary})
+	if err != nil {
+		t.Fatalf("codexexec.New returned error: %v", err)
+	}
+
+	dir := t.TempDir()
+	signalFile := filepath.Join(dir, "signal")
+	t.Setenv("CODEX_FAKE_PID_FILE", filepath.Join(dir, "fake-codex.pid"))
+	t.Setenv("CODEX_FAKE_SIGNAL_FILE", signalFile)
+
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := thread.RunStreamed(ctx, "long running turn", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	defer result.Close()
+
+	var failed *TurnFailedEvent
+	for event := range result.Events() {
+		switch e := event.(type) {
+		case ThreadStartedEvent:
+			if err := result.Interrupt(); err != nil {
+				t.Fatalf("Interrupt returned error: %v", err)
+			}
+		case TurnFailedEvent:
+			failed = &e
+		}
+	}
+	if failed == nil || failed.Error.Message != "turn interrupted" {
+		t.Fatalf("expected the CLI to report the interrupted turn, got %+v", failed)
+	}
+	var turnErr *TurnFailedError
+	if err := result.Wait(); !errors.As(err, &turnErr) {
+		t.Fatalf("result.Wait error = %v, want the reported *TurnFailedError", err)
+	}
+	data, err := os.ReadFile(signalFile)
+	if err != nil {
+		t.Fatalf("reading signal file: %v", err)
+	}
+	if got := string(data); got != os.Interrupt.String() {
+		t.Fatalf("fake CLI received %q, want %q", got, os.Interrupt.String())
+	}
+	if thread.ID() != "thread_interrupt" {
+		t.Fatalf("expected thread ID to survive the interrupt, got %q", thread.ID())
+	}
+}
+
 func buildFakeCodexBinary(t *testing.T) string {
 	t.Helper()
 
@@ -121,3 +179,82 @@ func waitForProcessExit(t *testing.T, pid int) {
 
 	t.Fatalf("process %d still running after cancellation", pid)
 }
+
+func TestThreadRunTimeoutReturnsErrTurnTimeout(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{
+		events:        marshalEvents(t, []map[string]any{{"type": "thread.started", "thread_id": "thread_1"}}),
+		waitForCancel: true,
+	}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	schema := map[string]any{"type": "object"}
+	_, err := thread.Run(context.Background(), "slow turn", &TurnOptions{Timeout: 50 * time.Millisecond, OutputSchema: schema})
+	if !errors.Is(err, ErrTurnTimeout) {
+		t.Fatalf("expected ErrTurnTimeout, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected ErrTurnTimeout to wrap context.DeadlineExceeded, got %v", err)
+	}
+
+	schemaPath := runner.lastCall().OutputSchemaPath
+	if _, statErr := os.Stat(schemaPath); !os.IsNotExist(statErr) {
+		t.Fatalf("expected schema file to be cleaned up, stat error: %v", statErr)
+	}
+}
+
+func TestThreadRunCallerDeadlineIsNotTurnTimeout(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{waitForCancel: true}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := thread.Run(ctx, "slow turn", &TurnOptions{Timeout: time.Minute})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if errors.Is(err, ErrTurnTimeout) {
+		t.Fatal("caller deadline should not be reported as ErrTurnTimeout")
+	}
+}
+
+func TestThreadRunStreamedTimeoutTerminatesProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("timeout integration test relies on unix signals")
+	}
+
+	fakeBinary := buildFakeCodexBinary(t)
+
+	runner, err := codexexec.New(codexexec.RunnerOptions{PathOverride: fakeBinary})
+	if err != nil {
+		t.Fatalf("codexexec.New returned error: %v", err)
+	}
+
+	pidFile := filepath.Join(t.TempDir(), "fake-codex.pid")
+	t.Setenv("CODEX_FAKE_PID_FILE", pidFile)
+
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "long running turn", &TurnOptions{Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	defer result.Close()
+
+	eventsDrained := make(chan struct{})
+	go func() {
+		for range result.Events() {
+			// drain events until stream closes
+		}
+		close(eventsDrained)
+	}()
+	defer func() { <-eventsDrained }()
+
+	pid := waitForPIDFile(t, pidFile)
+
+	if err := result.Wait(); !errors.Is(err, ErrTurnTimeout) {
+		t.Fatalf("result.Wait error = %v, want ErrTurnTimeout", err)
+	}
+
+	waitForProcessExit(t, pid)
+}
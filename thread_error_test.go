@@ -3,7 +3,10 @@ package godex
 import (
 	"context"
 	"errors"
+	"slices"
 	"testing"
+
+	"github.com/activadee/godex/internal/codexexec"
 )
 
 func TestThreadRunReturnsThreadStreamError(t *testing.T) {
@@ -47,3 +50,197 @@ func TestRunStreamedResultWaitReturnsThreadStreamError(t *testing.T) {
 		}
 	}
 }
+
+func TestRunStreamedResultWaitPrefersThreadErrorOverExecError(t *testing.T) {
+	execErr := &codexexec.ExecError{ExitCode: 1, Stderr: "fatal"}
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: threadErrorEvents(t), err: execErr}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "trigger error", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	defer result.Close()
+	for range result.Events() {
+	}
+
+	err = result.Wait()
+	var streamErr *ThreadStreamError
+	if !errors.As(err, &streamErr) {
+		t.Fatalf("expected *ThreadStreamError, got %T (%v)", err, err)
+	}
+	if streamErr.Message != "boom" {
+		t.Fatalf("unexpected stream error message %q", streamErr.Message)
+	}
+}
+
+func TestThreadRunSurfacesUsageOnTurnFailure(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "cmd_1", "type": "command_execution", "command": "ls", "aggregated_output": "", "status": "completed"}},
+		{"type": "turn.failed", "error": map[string]any{"message": "model overloaded"}, "usage": map[string]any{
+			"input_tokens": 120, "cached_input_tokens": 20, "output_tokens": 7,
+		}},
+	})
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	turn, err := thread.Run(context.Background(), "fail please", nil)
+	if err == nil {
+		t.Fatal("expected turn failure error")
+	}
+	if turn.Usage == nil {
+		t.Fatal("expected usage to be surfaced for failed turn")
+	}
+	if want := (Usage{InputTokens: 120, CachedInputTokens: 20, OutputTokens: 7}); *turn.Usage != want {
+		t.Fatalf("unexpected usage %+v", *turn.Usage)
+	}
+	if len(turn.Items) != 1 {
+		t.Fatalf("expected partial items to be returned, got %d", len(turn.Items))
+	}
+}
+
+func TestTurnFailureReturnsTurnFailedError(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "turn.failed", "error": map[string]any{"message": "model overloaded"}},
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+		thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+		_, err := thread.Run(context.Background(), "fail please", nil)
+		var turnErr *TurnFailedError
+		if !errors.As(err, &turnErr) {
+			t.Fatalf("expected *TurnFailedError, got %T (%v)", err, err)
+		}
+		if turnErr.Message != "model overloaded" {
+			t.Fatalf("unexpected failure message %q", turnErr.Message)
+		}
+	})
+
+	t.Run("RunStreamed", func(t *testing.T) {
+		runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+		thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+		result, err := thread.RunStreamed(context.Background(), "fail please", nil)
+		if err != nil {
+			t.Fatalf("RunStreamed returned error: %v", err)
+		}
+		for range result.Events() {
+		}
+		var turnErr *TurnFailedError
+		if err := result.Wait(); !errors.As(err, &turnErr) {
+			t.Fatalf("expected *TurnFailedError from Wait, got %T (%v)", err, err)
+		}
+		if turnErr.Message != "model overloaded" {
+			t.Fatalf("unexpected failure message %q", turnErr.Message)
+		}
+	})
+}
+
+func TestTurnErrMatchesReturnedError(t *testing.T) {
+	failed := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "message_1", "type": "agent_message", "text": "partial"}},
+		{"type": "turn.failed", "error": map[string]any{"message": "model overloaded"}},
+	})
+	variants := []struct {
+		name string
+		run  func(*Thread) (Turn, error)
+	}{
+		{name: "Run", run: func(thread *Thread) (Turn, error) {
+			return thread.Run(context.Background(), "hello", nil)
+		}},
+		{name: "RunStreamed", run: func(thread *Thread) (Turn, error) {
+			result, err := thread.RunStreamed(context.Background(), "hello", nil)
+			if err != nil {
+				return Turn{}, err
+			}
+			return result.Result()
+		}},
+		{name: "RunUntilComplete", run: func(thread *Thread) (Turn, error) {
+			result, err := thread.RunUntilComplete(context.Background(), "hello", nil)
+			return result.Turn, err
+		}},
+	}
+
+	for _, variant := range variants {
+		t.Run(variant.name, func(t *testing.T) {
+			for _, tc := range []struct {
+				name   string
+				events [][]byte
+			}{
+				{name: "turn failed", events: failed},
+				{name: "thread error", events: threadErrorEvents(t)},
+			} {
+				runner := &fakeRunner{t: t, batches: []fakeRun{{events: tc.events}}}
+				turn, err := variant.run(newThread(runner, CodexOptions{}, ThreadOptions{}, ""))
+				if err == nil {
+					t.Fatalf("%s: expected an error", tc.name)
+				}
+				if turn.Err() != err {
+					t.Fatalf("%s: expected Turn.Err to return %v, got %v", tc.name, err, turn.Err())
+				}
+			}
+
+			runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+			turn, err := variant.run(newThread(runner, CodexOptions{}, ThreadOptions{}, ""))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if turn.Err() != nil {
+				t.Fatalf("expected nil Turn.Err on success, got %v", turn.Err())
+			}
+		})
+	}
+}
+
+func TestThreadRunKeepsTurnFailureMessageVerbatim(t *testing.T) {
+	const message = "rate limited: 100% of quota used (%s %d %!)"
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "turn.failed", "error": map[string]any{"message": message}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	_, err := thread.Run(context.Background(), "fail please", nil)
+	if err == nil {
+		t.Fatal("expected turn failure error")
+	}
+	if err.Error() != message {
+		t.Fatalf("error = %q, want %q", err.Error(), message)
+	}
+}
+
+func TestThreadRunReturnsCodexExecError(t *testing.T) {
+	fakeBinary := buildFakeCodexBinary(t)
+
+	runner, err := codexexec.New(codexexec.RunnerOptions{PathOverride: fakeBinary})
+	if err != nil {
+		t.Fatalf("codexexec.New returned error: %v", err)
+	}
+
+	t.Setenv("CODEX_FAKE_EXIT_CODE", "127")
+	t.Setenv("CODEX_FAKE_STDERR", "codex: command not found")
+
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{Model: "gpt-test"}, "")
+
+	_, err = thread.Run(context.Background(), "hello", nil)
+	var execErr *CodexExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("expected CodexExecError, got %T (%v)", err, err)
+	}
+	if execErr.ExitCode != 127 {
+		t.Fatalf("expected exit code 127, got %d", execErr.ExitCode)
+	}
+	if execErr.Stderr != "codex: command not found" {
+		t.Fatalf("unexpected stderr %q", execErr.Stderr)
+	}
+	if !slices.Contains(execErr.Args, "gpt-test") {
+		t.Fatalf("expected args to include model, got %v", execErr.Args)
+	}
+}
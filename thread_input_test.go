@@ -3,6 +3,8 @@ package godex
 import (
 	"context"
 	"testing"
+
+	"github.com/activadee/godex/internal/codexexec"
 )
 
 func TestThreadRunInputsForwardsImages(t *testing.T) {
@@ -31,3 +33,28 @@ func TestThreadRunInputsForwardsImages(t *testing.T) {
 		t.Fatalf("unexpected images slice: %v", call.Images)
 	}
 }
+
+func TestThreadRunReportsStdinBytes(t *testing.T) {
+	fakeBinary := buildFakeCodexBinary(t)
+
+	runner, err := codexexec.New(codexexec.RunnerOptions{PathOverride: fakeBinary})
+	if err != nil {
+		t.Fatalf("codexexec.New returned error: %v", err)
+	}
+	t.Setenv("CODEX_FAKE_EXIT_CODE", "0")
+
+	var captured []string
+	options := CodexOptions{OnStdin: func(data []byte) { captured = append(captured, string(data)) }}
+	thread := newThread(runner, options, ThreadOptions{}, "")
+
+	if _, err := thread.RunInputs(context.Background(), []InputSegment{
+		TextSegment("first"),
+		TextSegment("second"),
+	}, nil); err != nil {
+		t.Fatalf("RunInputs returned error: %v", err)
+	}
+
+	if len(captured) != 1 || captured[0] != "first\n\nsecond" {
+		t.Fatalf("expected stdin callback to receive the prompt once, got %q", captured)
+	}
+}
@@ -2,8 +2,17 @@ package godex
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/activadee/godex/internal/codexexec"
 )
 
 func TestThreadRunStreamedReturnsEvents(t *testing.T) {
@@ -200,3 +209,810 @@ func TestStreamCallbacksDispatchTypedItems(t *testing.T) {
 		t.Fatalf("unexpected web search callback payload: %+v", webSearches[0])
 	}
 }
+
+func TestStreamCallbacksOnItemDoneFiresOncePerItem(t *testing.T) {
+	command := func(status string) map[string]any {
+		return map[string]any{"id": "command_1", "type": "command_execution", "command": "make", "status": status}
+	}
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.started", "item": command("in_progress")},
+		{"type": "item.updated", "item": command("in_progress")},
+		{"type": "item.completed", "item": command("completed")},
+		{"type": "item.updated", "item": map[string]any{"id": "message_1", "type": "agent_message", "text": "partial"}},
+		{"type": "item.completed", "item": map[string]any{"id": "message_1", "type": "agent_message", "text": "done"}},
+		{"type": "item.completed", "item": map[string]any{"id": "message_1", "type": "agent_message", "text": "done"}},
+		{"type": "item.completed", "item": map[string]any{"id": "search_1", "type": "web_search", "query": "godex"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var done []string
+	_, err := thread.Run(context.Background(), "hello", &TurnOptions{Callbacks: &StreamCallbacks{
+		OnItemDone: func(item ThreadItem) {
+			done = append(done, item.itemID())
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	expected := []string{"command_1", "message_1", "search_1"}
+	if !slices.Equal(done, expected) {
+		t.Fatalf("expected OnItemDone for %v, got %v", expected, done)
+	}
+}
+
+func TestStreamCallbacksCarryReasoningKind(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{
+			"id":   "reasoning_1",
+			"type": "reasoning",
+			"text": "Checking the build",
+			"kind": "summary",
+		}},
+		{"type": "item.completed", "item": map[string]any{
+			"id":   "reasoning_2",
+			"type": "reasoning",
+			"text": "step by step...",
+			"kind": "raw",
+		}},
+		{"type": "item.completed", "item": map[string]any{
+			"id":   "reasoning_3",
+			"type": "reasoning",
+			"text": "no kind reported",
+		}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var kinds []ReasoningKind
+	callbacks := &StreamCallbacks{
+		OnReasoning: func(evt StreamReasoningEvent) {
+			kinds = append(kinds, evt.Reasoning.Kind)
+		},
+	}
+
+	if _, err := thread.Run(context.Background(), "reason", &TurnOptions{Callbacks: callbacks}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	expected := []ReasoningKind{ReasoningKindSummary, ReasoningKindRaw, ""}
+	if len(kinds) != len(expected) {
+		t.Fatalf("expected %d reasoning callbacks, got %d", len(expected), len(kinds))
+	}
+	for i, kind := range expected {
+		if kinds[i] != kind {
+			t.Fatalf("reasoning %d: expected kind %q, got %q", i, kind, kinds[i])
+		}
+	}
+}
+
+func TestStreamCallbacksReasoningDeltas(t *testing.T) {
+	reasoning := func(text string) map[string]any {
+		return map[string]any{"id": "reasoning_1", "type": "reasoning", "text": text, "summary": "Inspecting tests"}
+	}
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.updated", "item": reasoning("Looking at")},
+		{"type": "item.updated", "item": reasoning("Looking at the failing test")},
+		{"type": "item.completed", "item": reasoning("Looking at the failing test first.")},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var got []StreamReasoningEvent
+	callbacks := &StreamCallbacks{
+		OnReasoning: func(evt StreamReasoningEvent) {
+			got = append(got, evt)
+		},
+	}
+	if _, err := thread.Run(context.Background(), "reason", &TurnOptions{Callbacks: callbacks}); err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	expected := []struct {
+		stage StreamItemStage
+		delta string
+	}{
+		{StreamItemStageUpdated, "Looking at"},
+		{StreamItemStageUpdated, " the failing test"},
+		{StreamItemStageCompleted, ""},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d reasoning callbacks, got %d", len(expected), len(got))
+	}
+	for i, want := range expected {
+		if got[i].Stage != want.stage || got[i].Delta != want.delta {
+			t.Fatalf("callback %d: expected %s/%q, got %s/%q", i, want.stage, want.delta, got[i].Stage, got[i].Delta)
+		}
+	}
+	if final := got[2].Reasoning; final.Text != "Looking at the failing test first." || final.Summary != "Inspecting tests" {
+		t.Fatalf("expected the completed callback to carry the full text and summary, got %+v", final)
+	}
+}
+
+func TestRunStreamedResultUntilCommandReturnsMatch(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.started", "item": map[string]any{"id": "cmd_1", "type": "command_execution", "command": "ls -la", "aggregated_output": "", "status": "in_progress"}},
+		{"type": "item.completed", "item": map[string]any{"id": "cmd_1", "type": "command_execution", "command": "ls -la", "aggregated_output": "ok", "status": "completed"}},
+		{"type": "item.started", "item": map[string]any{"id": "cmd_2", "type": "command_execution", "command": "rm -rf build", "aggregated_output": "", "status": "in_progress"}},
+		{"type": "item.started", "item": map[string]any{"id": "cmd_3", "type": "command_execution", "command": "go test ./...", "aggregated_output": "", "status": "in_progress"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "clean up", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	defer result.Close()
+
+	command, err := result.UntilCommand(context.Background(), func(c CommandExecutionItem) bool {
+		return strings.HasPrefix(c.Command, "rm -rf")
+	})
+	if err != nil {
+		t.Fatalf("UntilCommand returned error: %v", err)
+	}
+	if command.ID != "cmd_2" {
+		t.Fatalf("expected cmd_2, got %+v", command)
+	}
+
+	var replayed int
+	for range result.Events() {
+		replayed++
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("result.Wait returned error: %v", err)
+	}
+	if replayed != len(events) {
+		t.Fatalf("expected %d events including buffered ones, got %d", len(events), replayed)
+	}
+}
+
+func TestRunStreamedResultUntilCommandReportsNoMatch(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "hello", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	defer result.Close()
+
+	_, err = result.UntilCommand(context.Background(), func(CommandExecutionItem) bool { return true })
+	if !errors.Is(err, ErrCommandNotFound) {
+		t.Fatalf("expected ErrCommandNotFound, got %v", err)
+	}
+}
+
+func TestStreamCallbacksOnStderrReceivesCLILines(t *testing.T) {
+	fakeBinary := buildFakeCodexBinary(t)
+
+	runner, err := codexexec.New(codexexec.RunnerOptions{PathOverride: fakeBinary})
+	if err != nil {
+		t.Fatalf("codexexec.New returned error: %v", err)
+	}
+
+	t.Setenv("CODEX_FAKE_EXIT_CODE", "0")
+	t.Setenv("CODEX_FAKE_STDERR", "starting session\nwarning: slow network\n")
+
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+	result, err := thread.RunStreamed(context.Background(), "hello", &TurnOptions{
+		Callbacks: &StreamCallbacks{
+			OnStderr: func(line []byte) {
+				mu.Lock()
+				defer mu.Unlock()
+				lines = append(lines, string(line))
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	defer result.Close()
+
+	for range result.Events() {
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"starting session", "warning: slow network"}
+	if !slices.Equal(lines, expected) {
+		t.Fatalf("expected stderr lines %v, got %v", expected, lines)
+	}
+}
+
+func TestRunStreamedResultSendSteersRunningTurn(t *testing.T) {
+	fakeBinary := buildFakeCodexBinary(t)
+
+	runner, err := codexexec.New(codexexec.RunnerOptions{PathOverride: fakeBinary})
+	if err != nil {
+		t.Fatalf("codexexec.New returned error: %v", err)
+	}
+
+	t.Setenv("CODEX_FAKE_ECHO_STDIN", "1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+	result, err := thread.RunStreamed(ctx, "start", &TurnOptions{Steering: true})
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	defer result.Close()
+
+	var messages []string
+	pending := []string{"nudge", "stop"}
+	for event := range result.Events() {
+		completed, ok := event.(ItemCompletedEvent)
+		if !ok {
+			continue
+		}
+		message, ok := completed.Item.(AgentMessageItem)
+		if !ok {
+			continue
+		}
+		messages = append(messages, message.Text)
+		if len(pending) > 0 {
+			if err := result.Send(pending[0]); err != nil {
+				t.Fatalf("Send(%q) returned error: %v", pending[0], err)
+			}
+			pending = pending[1:]
+		}
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	expected := []string{"echo: start", "echo: nudge", "echo: stop"}
+	if !slices.Equal(messages, expected) {
+		t.Fatalf("expected messages %v, got %v", expected, messages)
+	}
+	if err := result.Send("late"); !errors.Is(err, ErrInputClosed) {
+		t.Fatalf("expected ErrInputClosed after the turn finished, got %v", err)
+	}
+}
+
+func TestRunStreamedResultSendRequiresSteering(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: successEvents(t)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "hello", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	defer result.Close()
+
+	if err := result.Send("nudge"); !errors.Is(err, ErrSteeringDisabled) {
+		t.Fatalf("expected ErrSteeringDisabled, got %v", err)
+	}
+	for range result.Events() {
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+}
+
+func TestThreadRejectsConcurrentTurns(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{
+		{events: successEvents(t), waitForCancel: true},
+		{events: successEvents(t)},
+	}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "first", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	if _, err := thread.Run(context.Background(), "second", nil); !errors.Is(err, ErrThreadBusy) {
+		t.Fatalf("expected ErrThreadBusy while a turn is running, got %v", err)
+	}
+
+	_ = result.Close()
+	if _, err := thread.Run(context.Background(), "third", nil); err != nil {
+		t.Fatalf("expected Run to succeed after the first turn finished, got %v", err)
+	}
+}
+
+func TestThreadReleasesBusyFlagOnEarlyError(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: successEvents(t)}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	_, err := thread.RunStreamed(context.Background(), "hello", &TurnOptions{
+		OutputSchema:     map[string]any{"type": "object"},
+		OutputSchemaPath: "schema.json",
+	})
+	if err == nil {
+		t.Fatal("expected RunStreamed to reject conflicting schema options")
+	}
+	if _, err := thread.Run(context.Background(), "hello", nil); err != nil {
+		t.Fatalf("expected Run to succeed after an early error, got %v", err)
+	}
+}
+
+func TestStreamCallbacksFinishBeforeWaitReturns(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "turn.started"},
+		{"type": "turn.failed", "error": map[string]any{"message": "model overloaded"}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var failed atomic.Bool
+	callbacks := &StreamCallbacks{
+		OnTurnFailed: func(TurnFailedEvent) {
+			// A slow callback would lose the race against Wait if ordering were not enforced.
+			time.Sleep(20 * time.Millisecond)
+			failed.Store(true)
+		},
+	}
+	result, err := thread.RunStreamed(context.Background(), "fail please", &TurnOptions{Callbacks: callbacks})
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	go func() {
+		for range result.Events() {
+		}
+	}()
+
+	_ = result.Wait()
+	if !failed.Load() {
+		t.Fatal("expected OnTurnFailed to run before Wait returned")
+	}
+}
+
+func TestRunStreamedResultMatchesRun(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "turn.started"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "reasoning", "text": "thinking"}},
+		{"type": "item.completed", "item": map[string]any{"id": "item_2", "type": "agent_message", "text": "Hello"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 5, "cached_input_tokens": 1, "output_tokens": 2}, "finish_reason": "stop"},
+	})
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: events}}
+
+	expected, err := newThread(runner, CodexOptions{}, ThreadOptions{}, "").Run(context.Background(), "hi", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	result, err := newThread(runner, CodexOptions{}, ThreadOptions{}, "").RunStreamed(context.Background(), "hi", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	var streamed int
+	for range result.Events() {
+		streamed++
+	}
+	if streamed != len(events) {
+		t.Fatalf("expected %d streamed events, got %d", len(events), streamed)
+	}
+	turn, err := result.Result()
+	if err != nil {
+		t.Fatalf("Result returned error: %v", err)
+	}
+	if !reflect.DeepEqual(turn, expected) {
+		t.Fatalf("Result() = %+v, want %+v", turn, expected)
+	}
+}
+
+func TestRunStreamedResultReturnsPartialTurnOnFailure(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "partial"}},
+		{"type": "turn.failed", "error": map[string]any{"message": "model overloaded"}},
+	})
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: events}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "hi", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	// Result drains events the caller never read.
+	turn, err := result.Result()
+	if err == nil || !strings.Contains(err.Error(), "model overloaded") {
+		t.Fatalf("expected turn failure, got %v", err)
+	}
+	if len(turn.Items) != 1 {
+		t.Fatalf("expected the partial item, got %d items", len(turn.Items))
+	}
+}
+
+func TestRunStreamedResultReturnsPartialTurnOnStreamError(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "partial"}},
+		{"type": "error", "message": "boom"},
+	})
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: events}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "hi", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	turn, err := result.Result()
+	var streamErr *ThreadStreamError
+	if !errors.As(err, &streamErr) {
+		t.Fatalf("expected *ThreadStreamError, got %T (%v)", err, err)
+	}
+	if len(turn.Items) != 1 {
+		t.Fatalf("expected the partial item, got %d items", len(turn.Items))
+	}
+	if turn.Err() != err {
+		t.Fatalf("expected Turn.Err to return %v, got %v", err, turn.Err())
+	}
+}
+
+func TestRunStreamedResultReturnsPartialTurnOnTimeout(t *testing.T) {
+	runner := &fakeRunner{t: t, defaults: fakeRun{
+		events: marshalEvents(t, []map[string]any{
+			{"type": "thread.started", "thread_id": "thread_1"},
+			{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "partial"}},
+		}),
+		waitForCancel: true,
+	}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "hi", &TurnOptions{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	turn, err := result.Result()
+	if !errors.Is(err, ErrTurnTimeout) {
+		t.Fatalf("expected ErrTurnTimeout, got %v", err)
+	}
+	if len(turn.Items) != 1 {
+		t.Fatalf("expected the partial item, got %d items", len(turn.Items))
+	}
+	if turn.Err() != err {
+		t.Fatalf("expected Turn.Err to return %v, got %v", err, turn.Err())
+	}
+}
+
+func TestRunStreamedResultCloseIsIdempotentAfterWait(t *testing.T) {
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: threadErrorEvents(t)}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "hello", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	for range result.Events() {
+	}
+
+	waitErr := result.Wait()
+	if waitErr == nil {
+		t.Fatal("expected Wait to report the stream error")
+	}
+	for i := 0; i < 2; i++ {
+		if err := result.Close(); err != waitErr {
+			t.Fatalf("Close #%d returned %v, want %v", i+1, err, waitErr)
+		}
+	}
+	if _, ok := <-result.Events(); ok {
+		t.Fatal("expected Events to stay closed after Close")
+	}
+	if err := result.Interrupt(); err != nil {
+		t.Fatalf("Interrupt after completion returned error: %v", err)
+	}
+}
+
+func TestThreadRunStreamedDeliversUnknownEvents(t *testing.T) {
+	events := successEvents(t)
+	delta := []byte(`{"type":"turn.delta","delta":{"text":"Hel"}}`)
+	events = append(events[:1:1], append([][]byte{delta}, events[1:]...)...)
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "hello", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	var unknown []UnknownEvent
+	var total int
+	for event := range result.Events() {
+		total++
+		if e, ok := event.(UnknownEvent); ok {
+			unknown = append(unknown, e)
+		}
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+	if total != len(events) {
+		t.Fatalf("expected all %d events, got %d", len(events), total)
+	}
+	if len(unknown) != 1 || string(unknown[0].Payload) != string(delta) {
+		t.Fatalf("expected the raw turn.delta event, got %+v", unknown)
+	}
+}
+
+func TestRunStreamedResultCompletedItemsSkipsUpdates(t *testing.T) {
+	command := func(status string) map[string]any {
+		return map[string]any{"id": "command_1", "type": "command_execution", "command": "make", "status": status}
+	}
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.started", "item": command("in_progress")},
+		{"type": "item.updated", "item": command("in_progress")},
+		{"type": "item.completed", "item": command("completed")},
+		{"type": "item.updated", "item": map[string]any{"id": "message_1", "type": "agent_message", "text": "partial"}},
+		{"type": "item.completed", "item": map[string]any{"id": "message_1", "type": "agent_message", "text": "done"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "hello", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	var items []ThreadItem
+	for item := range result.CompletedItems() {
+		items = append(items, item)
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("expected 2 completed items, got %d: %+v", len(items), items)
+	}
+	if command, ok := items[0].(CommandExecutionItem); !ok || command.Status != CommandExecutionStatusCompleted {
+		t.Fatalf("expected completed command first, got %+v", items[0])
+	}
+	if message, ok := items[1].(AgentMessageItem); !ok || message.Text != "done" {
+		t.Fatalf("expected final agent message, got %+v", items[1])
+	}
+}
+
+func TestThreadRunStreamedYieldsUnknownItems(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.completed", "item": map[string]any{"id": "image_1", "type": "image_generation", "prompt": "a cat"}},
+		{"type": "item.completed", "item": map[string]any{"id": "message_1", "type": "agent_message", "text": "done"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var hooked []StreamUnknownItemEvent
+	result, err := thread.RunStreamed(context.Background(), "hello", &TurnOptions{Callbacks: &StreamCallbacks{
+		OnUnknownItem: func(event StreamUnknownItemEvent) {
+			hooked = append(hooked, event)
+		},
+	}})
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	var items []ThreadItem
+	for item := range result.CompletedItems() {
+		items = append(items, item)
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("expected the stream to continue past the unknown item, got %+v", items)
+	}
+	unknown, ok := items[0].(UnknownItem)
+	if !ok {
+		t.Fatalf("expected UnknownItem, got %T", items[0])
+	}
+	if unknown.ID != "image_1" || unknown.Type != "image_generation" || !strings.Contains(string(unknown.Payload), `"prompt":"a cat"`) {
+		t.Fatalf("unexpected unknown item: %+v", unknown)
+	}
+	if len(hooked) != 1 || hooked[0].Stage != StreamItemStageCompleted || hooked[0].Item.ID != "image_1" {
+		t.Fatalf("expected OnUnknownItem for the completed item, got %+v", hooked)
+	}
+}
+
+func TestStreamCallbacksCarryWebSearchResults(t *testing.T) {
+	search := map[string]any{"id": "search_1", "type": "web_search", "query": "godex sdk"}
+	completed := map[string]any{"id": "search_1", "type": "web_search", "query": "godex sdk", "results": []map[string]any{
+		{"title": "godex on GitHub", "url": "https://github.com/activadee/godex", "snippet": "Go SDK for the Codex CLI"},
+		{"title": "Codex CLI", "url": "https://github.com/openai/codex"},
+	}}
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.started", "item": search},
+		{"type": "item.completed", "item": completed},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var searches []StreamWebSearchEvent
+	turn, err := thread.Run(context.Background(), "search", &TurnOptions{Callbacks: &StreamCallbacks{
+		OnWebSearch: func(event StreamWebSearchEvent) {
+			searches = append(searches, event)
+		},
+	}})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	if len(searches) != 2 {
+		t.Fatalf("expected started and completed callbacks, got %d", len(searches))
+	}
+	if searches[0].Search.Results != nil {
+		t.Fatalf("expected no results on item.started, got %+v", searches[0].Search.Results)
+	}
+	expected := []WebSearchResult{
+		{Title: "godex on GitHub", URL: "https://github.com/activadee/godex", Snippet: "Go SDK for the Codex CLI"},
+		{Title: "Codex CLI", URL: "https://github.com/openai/codex"},
+	}
+	if got := searches[1].Search.Results; !slices.Equal(got, expected) {
+		t.Fatalf("expected results %+v, got %+v", expected, got)
+	}
+	if item, ok := turn.Items[0].(WebSearchItem); !ok || len(item.Results) != 2 {
+		t.Fatalf("expected the turn to keep the results, got %+v", turn.Items)
+	}
+}
+
+func TestEventOverflowDropOldestKeepsTerminalEvents(t *testing.T) {
+	raw := []map[string]any{{"type": "thread.started", "thread_id": "thread_1"}}
+	for i := 1; i <= 10; i++ {
+		raw = append(raw, map[string]any{"type": "item.updated", "item": map[string]any{
+			"id": "message_1", "type": "agent_message", "text": fmt.Sprintf("update %d", i),
+		}})
+	}
+	raw = append(raw, map[string]any{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}})
+	events := marshalEvents(t, raw)
+
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{EventOverflowPolicy: EventOverflowDropOldest, EventBufferSize: 3}, ThreadOptions{}, "")
+
+	result, err := thread.RunStreamed(context.Background(), "hello", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	// The consumer lags until the CLI has finished; the ring must not stall it.
+	if err := result.Wait(); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	var texts []string
+	var received []ThreadEvent
+	for event := range result.Events() {
+		received = append(received, event)
+		if updated, ok := event.(ItemUpdatedEvent); ok {
+			texts = append(texts, updated.Item.(AgentMessageItem).Text)
+		}
+	}
+	if len(received) >= len(events) {
+		t.Fatalf("expected intermediate events to be dropped, got all %d", len(received))
+	}
+	if _, ok := received[len(received)-1].(TurnCompletedEvent); !ok {
+		t.Fatalf("expected turn.completed to survive, got %T last", received[len(received)-1])
+	}
+	if len(texts) == 0 || texts[len(texts)-1] != "update 10" || slices.Contains(texts, "update 1") {
+		t.Fatalf("expected only the newest updates, got %v", texts)
+	}
+}
+
+func TestStreamCallbacksOnRateLimit(t *testing.T) {
+	events := successEvents(t)
+	notice := []byte(`{"type":"rate_limit","message":"Rate limit reached, retrying","retry_after_ms":2500,"attempt":1}`)
+	events = append(events[:1:1], append([][]byte{notice}, events[1:]...)...)
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var hooked []RateLimitEvent
+	result, err := thread.RunStreamed(context.Background(), "hello", &TurnOptions{Callbacks: &StreamCallbacks{
+		OnRateLimit: func(event RateLimitEvent) {
+			hooked = append(hooked, event)
+		},
+	}})
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	var streamed []RateLimitEvent
+	for event := range result.Events() {
+		if e, ok := event.(RateLimitEvent); ok {
+			streamed = append(streamed, e)
+		}
+	}
+	if err := result.Wait(); err != nil {
+		t.Fatalf("Wait returned error: %v", err)
+	}
+
+	if len(hooked) != 1 || len(streamed) != 1 {
+		t.Fatalf("expected one rate limit event via callback and stream, got %d and %d", len(hooked), len(streamed))
+	}
+	if got := hooked[0].RetryAfter(); got != 2500*time.Millisecond {
+		t.Fatalf("expected a 2.5s retry-after, got %v", got)
+	}
+	if hooked[0].Message != "Rate limit reached, retrying" || hooked[0].Attempt != 1 {
+		t.Fatalf("unexpected rate limit event %+v", hooked[0])
+	}
+}
+
+func TestStreamCallbacksOnUsage(t *testing.T) {
+	events := successEvents(t)
+	events[len(events)-1] = []byte(`{"type":"turn.completed","usage":{"input_tokens":120,"cached_input_tokens":40,"output_tokens":30,"reasoning_output_tokens":10}}`)
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	var usages []Usage
+	var completed int
+	_, err := thread.Run(context.Background(), "hello", &TurnOptions{Callbacks: &StreamCallbacks{
+		OnUsage:         func(usage Usage) { usages = append(usages, usage) },
+		OnTurnCompleted: func(TurnCompletedEvent) { completed++ },
+	}})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	want := Usage{InputTokens: 120, CachedInputTokens: 40, OutputTokens: 30, ReasoningTokens: 10}
+	if len(usages) != 1 || usages[0] != want {
+		t.Fatalf("expected OnUsage once with %+v, got %+v", want, usages)
+	}
+	if completed != 1 {
+		t.Fatalf("expected OnTurnCompleted to fire once, got %d", completed)
+	}
+}
+
+func TestStreamCallbacksOnCommandErrAbortsTurn(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "item.started", "item": map[string]any{"id": "cmd_1", "type": "command_execution", "command": "rm -rf /", "aggregated_output": "", "status": "in_progress"}},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "Hello"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 1, "cached_input_tokens": 0, "output_tokens": 1}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	thread := newThread(runner, CodexOptions{}, ThreadOptions{}, "")
+
+	errForbidden := errors.New("forbidden command")
+	var seen []ThreadEventType
+	result, err := thread.RunStreamed(context.Background(), "hello", &TurnOptions{Callbacks: &StreamCallbacks{
+		OnEventErr: func(event ThreadEvent) error {
+			seen = append(seen, event.EventType())
+			return nil
+		},
+		OnCommandErr: func(event StreamCommandEvent) error {
+			if strings.HasPrefix(event.Command.Command, "rm ") {
+				return errForbidden
+			}
+			return nil
+		},
+	}})
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	for range result.Events() {
+	}
+
+	if err := result.Wait(); !errors.Is(err, errForbidden) {
+		t.Fatalf("expected Wait to return the callback error, got %v", err)
+	}
+	if !errors.Is(runner.abortCtxErr, context.Canceled) {
+		t.Fatalf("expected the run context to be canceled, got %v", runner.abortCtxErr)
+	}
+	if len(seen) != 2 {
+		t.Fatalf("expected no events after the abort, saw %v", seen)
+	}
+}
@@ -12,6 +12,9 @@ import (
 type fakeRun struct {
 	events [][]byte
 	err    error
+	// waitForCancel blocks after delivering events until ctx is done, mimicking a CLI
+	// process that keeps running.
+	waitForCancel bool
 }
 
 type fakeRunner struct {
@@ -20,11 +23,12 @@ type fakeRunner struct {
 	calls    []codexexec.Args
 	batches  []fakeRun
 	defaults fakeRun
+	// abortCtxErr records ctx.Err() when a line handler fails, as the real runner would
+	// then kill the process.
+	abortCtxErr error
 }
 
 func (f *fakeRunner) Run(ctx context.Context, args codexexec.Args, handleLine func([]byte) error) error {
-	_ = ctx
-
 	f.mu.Lock()
 	f.calls = append(f.calls, args)
 	var batch fakeRun
@@ -38,9 +42,16 @@ func (f *fakeRunner) Run(ctx context.Context, args codexexec.Args, handleLine fu
 
 	for _, event := range batch.events {
 		if err := handleLine(event); err != nil {
+			f.mu.Lock()
+			f.abortCtxErr = ctx.Err()
+			f.mu.Unlock()
 			return err
 		}
 	}
+	if batch.waitForCancel {
+		<-ctx.Done()
+		return ctx.Err()
+	}
 	return batch.err
 }
 
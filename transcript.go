@@ -0,0 +1,145 @@
+package godex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/activadee/godex/internal/codexexec"
+)
+
+// WriteJSONL writes each event to w as one line of JSON, in the same shape the Codex CLI
+// emits, so the output can be decoded again like a CLI transcript. Events decoded from the CLI
+// are written as their original line (see ThreadEvent.Raw); others are encoded.
+func WriteJSONL(w io.Writer, events ...ThreadEvent) error {
+	encoder := json.NewEncoder(w)
+	for _, event := range events {
+		if raw := event.Raw(); len(raw) > 0 {
+			if _, err := w.Write(append(raw[:len(raw):len(raw)], '\n')); err != nil {
+				return fmt.Errorf("write %s event: %w", event.EventType(), err)
+			}
+			continue
+		}
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("write %s event: %w", event.EventType(), err)
+		}
+	}
+	return nil
+}
+
+// SaveTranscript consumes the stream, writes every event to path as JSONL and returns the
+// final Turn as Result would. The file is written to a temporary file in the same directory
+// and renamed into place once the stream ends, so path never holds a partial transcript. A
+// failed turn is still saved; its error is returned alongside the partial Turn. If the
+// transcript cannot be written, the stream is drained anyway and the write error returned.
+func (r RunStreamedResult) SaveTranscript(path string) (Turn, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		_, _ = r.Result()
+		err = codexexec.CheckDiskFull(path, fmt.Errorf("create transcript: %w", err))
+		return Turn{}.withErr(err), err
+	}
+	tmpPath := file.Name()
+
+	var writeErr error
+	for event := range r.Events() {
+		if writeErr == nil {
+			writeErr = WriteJSONL(file, event)
+		}
+	}
+	writeErr = errors.Join(writeErr, file.Close())
+	if writeErr == nil {
+		writeErr = os.Rename(tmpPath, path)
+	}
+	if writeErr != nil {
+		_ = os.Remove(tmpPath)
+		_, _ = r.Result()
+		err := codexexec.CheckDiskFull(path, fmt.Errorf("save transcript: %w", writeErr))
+		return Turn{}.withErr(err), err
+	}
+	return r.Result()
+}
+
+// LogEvents consumes the stream, passes logf one line per event (the event type and a brief
+// summary) and returns the final Turn as Result would. It is meant for tests of code built on
+// the SDK: pass t.Logf-style output, for example func(s string) { t.Log(s) }, to see the full
+// event flow when a test fails.
+func (r RunStreamedResult) LogEvents(logf func(string)) (Turn, error) {
+	for event := range r.Events() {
+		logf(summarizeEvent(event))
+	}
+	return r.Result()
+}
+
+// summaryTextLimit caps the number of runes of free text quoted in an event summary.
+const summaryTextLimit = 60
+
+// summarizeEvent describes event in a single line.
+func summarizeEvent(event ThreadEvent) string {
+	switch e := event.(type) {
+	case ThreadStartedEvent:
+		return fmt.Sprintf("%s %s", e.EventType(), e.ThreadID)
+	case TurnCompletedEvent:
+		return fmt.Sprintf("%s %s", e.EventType(), e.Usage)
+	case TurnFailedEvent:
+		return fmt.Sprintf("%s: %s", e.EventType(), e.Error.Message)
+	case ThreadErrorEvent:
+		return fmt.Sprintf("%s: %s", e.EventType(), e.Message)
+	case RateLimitEvent:
+		return fmt.Sprintf("%s: retry after %s (attempt %d)", e.EventType(), e.RetryAfter(), e.Attempt)
+	case ItemStartedEvent:
+		return fmt.Sprintf("%s %s", e.EventType(), summarizeItem(e.Item))
+	case ItemUpdatedEvent:
+		return fmt.Sprintf("%s %s", e.EventType(), summarizeItem(e.Item))
+	case ItemCompletedEvent:
+		return fmt.Sprintf("%s %s", e.EventType(), summarizeItem(e.Item))
+	default:
+		return string(event.EventType())
+	}
+}
+
+// summarizeItem describes item as its type, ID and the detail most useful when reading a log.
+func summarizeItem(item ThreadItem) string {
+	if item == nil {
+		return "<nil item>"
+	}
+	prefix := fmt.Sprintf("%s %s", item.itemType(), item.itemID())
+	var detail string
+	switch v := item.(type) {
+	case AgentMessageItem:
+		detail = fmt.Sprintf("%q", truncateRunes(v.Text, summaryTextLimit))
+	case ReasoningItem:
+		detail = fmt.Sprintf("%q", truncateRunes(v.Text, summaryTextLimit))
+	case CommandExecutionItem:
+		detail = fmt.Sprintf("%q %s", truncateRunes(v.Command, summaryTextLimit), v.Status)
+		if v.ExitCode != nil {
+			detail += fmt.Sprintf(" (exit %d)", *v.ExitCode)
+		}
+	case FileChangeItem:
+		detail = fmt.Sprintf("%d change(s) %s", len(v.Changes), v.Status)
+	case McpToolCallItem:
+		detail = fmt.Sprintf("%s/%s %s", v.Server, v.Tool, v.Status)
+	case WebSearchItem:
+		detail = fmt.Sprintf("%q", truncateRunes(v.Query, summaryTextLimit))
+	case TodoListItem:
+		detail = fmt.Sprintf("%d todo(s)", len(v.Items))
+	case ErrorItem:
+		detail = v.Message
+	}
+	if detail == "" {
+		return prefix
+	}
+	return prefix + ": " + detail
+}
+
+// truncateRunes shortens s to at most limit runes, marking a cut with an ellipsis.
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit]) + "…"
+}
@@ -0,0 +1,112 @@
+package godex
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRunStreamedResultSaveTranscript(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "turn.started"},
+		{"type": "item.completed", "item": map[string]any{"id": "cmd_1", "type": "command_execution", "command": "ls", "aggregated_output": "go.mod\n", "status": "completed"}},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "Hello"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 5, "cached_input_tokens": 1, "output_tokens": 2}},
+	})
+	runner := &fakeRunner{t: t, defaults: fakeRun{events: events}}
+
+	expected, err := newThread(runner, CodexOptions{}, ThreadOptions{}, "").Run(context.Background(), "hi", nil)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+
+	result, err := newThread(runner, CodexOptions{}, ThreadOptions{}, "").RunStreamed(context.Background(), "hi", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	turn, err := result.SaveTranscript(path)
+	if err != nil {
+		t.Fatalf("SaveTranscript returned error: %v", err)
+	}
+	if !reflect.DeepEqual(turn, expected) {
+		t.Fatalf("SaveTranscript turn = %+v, want %+v", turn, expected)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open transcript: %v", err)
+	}
+	defer file.Close()
+	var saved []ThreadEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		event, err := decodeThreadEvent(scanner.Bytes())
+		if err != nil {
+			t.Fatalf("decode transcript line %q: %v", scanner.Text(), err)
+		}
+		saved = append(saved, event)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read transcript: %v", err)
+	}
+
+	var original []ThreadEvent
+	for _, line := range events {
+		event, err := decodeThreadEvent(line)
+		if err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		original = append(original, event)
+	}
+	if !reflect.DeepEqual(saved, original) {
+		t.Fatalf("transcript events = %+v, want %+v", saved, original)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("read transcript dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the transcript to remain, found %d entries", len(entries))
+	}
+}
+
+func TestRunStreamedResultLogEvents(t *testing.T) {
+	events := marshalEvents(t, []map[string]any{
+		{"type": "thread.started", "thread_id": "thread_1"},
+		{"type": "turn.started"},
+		{"type": "item.completed", "item": map[string]any{"id": "cmd_1", "type": "command_execution", "command": "ls", "aggregated_output": "go.mod\n", "exit_code": 0, "status": "completed"}},
+		{"type": "item.completed", "item": map[string]any{"id": "item_1", "type": "agent_message", "text": "Hello"}},
+		{"type": "turn.completed", "usage": map[string]any{"input_tokens": 5, "cached_input_tokens": 1, "output_tokens": 2}},
+	})
+	runner := &fakeRunner{t: t, batches: []fakeRun{{events: events}}}
+	result, err := newThread(runner, CodexOptions{}, ThreadOptions{}, "").RunStreamed(context.Background(), "hi", nil)
+	if err != nil {
+		t.Fatalf("RunStreamed returned error: %v", err)
+	}
+
+	var lines []string
+	turn, err := result.LogEvents(func(line string) { lines = append(lines, line) })
+	if err != nil {
+		t.Fatalf("LogEvents returned error: %v", err)
+	}
+	if turn.FinalResponse != "Hello" {
+		t.Fatalf("unexpected final response %q", turn.FinalResponse)
+	}
+
+	want := []string{
+		"thread.started thread_1",
+		"turn.started",
+		`item.completed command_execution cmd_1: "ls" completed (exit 0)`,
+		`item.completed agent_message item_1: "Hello"`,
+		"turn.completed 7 tokens (5 input, 1 cached; 2 output)",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("LogEvents lines = %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,57 @@
+package godex
+
+import "encoding/json"
+
+// turnBuilder assembles a Turn from the events of a single streamed turn.
+type turnBuilder struct {
+	items        []ThreadItem
+	finalMessage string
+	structured   json.RawMessage
+	usage        *Usage
+	finishReason string
+	failure      *ThreadError
+}
+
+// observe records event. Events after a turn failure are ignored.
+func (b *turnBuilder) observe(event ThreadEvent) {
+	if b.failure != nil {
+		return
+	}
+	switch e := event.(type) {
+	case ItemCompletedEvent:
+		b.items = append(b.items, e.Item)
+		switch item := e.Item.(type) {
+		case AgentMessageItem:
+			b.finalMessage = item.Text
+		case StructuredOutputItem:
+			b.structured = item.Data
+		}
+	case TurnCompletedEvent:
+		usageCopy := e.Usage
+		b.usage = &usageCopy
+		b.finishReason = e.FinishReason
+	case TurnFailedEvent:
+		failure := e.Error
+		b.failure = &failure
+		if e.Usage != nil {
+			usageCopy := *e.Usage
+			b.usage = &usageCopy
+		}
+	}
+}
+
+// result returns the assembled turn. A failed turn yields the items and usage observed so far
+// alongside the failure.
+func (b *turnBuilder) result() (Turn, error) {
+	if b.failure != nil {
+		err := &TurnFailedError{ThreadError: *b.failure}
+		return Turn{Items: b.items, Usage: b.usage, err: err}, err
+	}
+	return Turn{
+		Items:              b.items,
+		FinalResponse:      b.finalMessage,
+		StructuredResponse: b.structured,
+		Usage:              b.usage,
+		FinishReason:       b.finishReason,
+	}, nil
+}